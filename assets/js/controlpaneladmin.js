@@ -3,7 +3,7 @@ document.addEventListener('DOMContentLoaded', () => {
     const pollId = window.location.pathname.split('/').filter(Boolean).pop();
     console.log('Poll ID:', pollId);
 
-    const ws = new WebSocket(`ws://${window.location.host}/ws/${pollId}?role=admin`);
+    const ws = new WebSocket(`ws://${window.location.host}/ws/${pollId}?role=admin`, ['livepolls.v2']);
 
     const currentStatusText = document.getElementById('currentStatusText');
     const questionSection = document.getElementById('questionSection');
@@ -12,6 +12,8 @@ document.addEventListener('DOMContentLoaded', () => {
     const totalVotesSpan = document.getElementById('totalVotes');
     const finalResultsDiv = document.getElementById('finalResults');
     const allPollResultsDiv = document.getElementById('allPollResults');
+    const joinPinText = document.getElementById('joinPin');
+    const joinPinValue = document.getElementById('joinPinValue');
 
     const startButton = document.getElementById('startButton');
     const nextButton = document.getElementById('nextButton');
@@ -28,13 +30,18 @@ document.addEventListener('DOMContentLoaded', () => {
         console.log('Message from server:', message);
 
         switch (message.type) {
-            case 'poll_state_update':
+            case 'welcome':
+                console.log(`Using protocol version ${message.protocolVersion}`);
+                break;
+            case 'poll_state':
                 ws.currentPollState = message
                 updatePollState(message);
                 break;
-            case 'admin_results_update':
+            case 'results_update':
                 updateRealtimeResults(message);
                 break;
+            case 'ack':
+                break;
             case 'error':
                 alert(`Error: ${message.message}`); // Using alert for simplicity
                 break;
@@ -60,6 +67,14 @@ document.addEventListener('DOMContentLoaded', () => {
     function updatePollState(message) {
         currentStatusText.textContent = `Status: ${message.status.toUpperCase()}`;
 
+        // The join code only exists while the poll is live
+        if (message.joinPin) {
+            joinPinValue.textContent = message.joinPin;
+            joinPinText.classList.remove('hidden');
+        } else {
+            joinPinText.classList.add('hidden');
+        }
+
         // Hide all dynamic sections initially
         questionSection.classList.add('hidden');
         finalResultsDiv.classList.add('hidden');
@@ -97,7 +112,7 @@ document.addEventListener('DOMContentLoaded', () => {
                 break;
             case 'finished':
                 finalResultsDiv.classList.remove('hidden');
-                displayFinalResults(message.results, message.allQuestions); // Pass current question for context
+                displayFinalResults(message.pollId, message.allQuestions);
                 currentStatusText.textContent = 'Poll has finished. Final results are displayed.';
                 break;
         }
@@ -108,7 +123,7 @@ document.addEventListener('DOMContentLoaded', () => {
         console.log(message)
         
         if (!message.votes || !message.questionId) {
-            console.warn("Invalid admin_results_update message:", message);
+            console.warn("Invalid results_update message:", message);
             return;
         }
 
@@ -121,7 +136,7 @@ document.addEventListener('DOMContentLoaded', () => {
         if (ws.currentPollState && ws.currentPollState.currentQuestion) {
 
             ws.currentPollState.currentQuestion.options.forEach(opt => {
-                currentQuestionOptionsMap.set(opt.ID, opt.text);
+                currentQuestionOptionsMap.set(opt.id, opt.text);
             });
         }
 
@@ -152,20 +167,19 @@ document.addEventListener('DOMContentLoaded', () => {
         totalVotesSpan.textContent = totalVotes;
     }
 
-    function displayFinalResults(allResults, allQuestions) {
+    function displayFinalResults(pollDbId, allQuestions) {
         allPollResultsDiv.innerHTML = '';
 
         // Iterate through each question's results
-        for (const questionId in allResults) {
-            const questionResults = allResults[questionId];
+        for (const currentQ of allQuestions) {
+            const questionResults = currentQ.votes || {};
             const questionBlock = document.createElement('div');
             questionBlock.classList.add('border', 'border-purple-300', 'p-4', 'rounded-lg', 'bg-purple-50', 'mb-4');
 
-            currentQ = allQuestions.find(el=>el.ID == questionId)
             let questionText = currentQ.text
             let questionOptionsMap = new Map();
 
-            currentQ.options.forEach(opt => questionOptionsMap.set(opt.ID, opt.text));
+            currentQ.options.forEach(opt => questionOptionsMap.set(opt.id, opt.text));
 
 
             questionBlock.innerHTML = `<h3>${questionText}</h3>`;
@@ -199,6 +213,15 @@ document.addEventListener('DOMContentLoaded', () => {
                 resultsList.appendChild(resultItem);
             });
             questionBlock.appendChild(resultsList);
+
+            // Charts for pasting into slides
+            const chartUrl = `/api/v1/polls/${pollDbId}/questions/${currentQ.id}/chart`;
+            const chartLinks = document.createElement('small');
+            chartLinks.innerHTML = `Chart: <a href="${chartUrl}?format=svg" target="_blank">SVG</a> ·
+                <a href="${chartUrl}?format=png" target="_blank">PNG</a> ·
+                <a href="${chartUrl}?format=png&type=pie&values=percent" target="_blank">Pie (PNG)</a> ·
+                <a href="${chartUrl}?format=png&theme=dark" target="_blank">Dark (PNG)</a>`;
+            questionBlock.appendChild(chartLinks);
             allPollResultsDiv.appendChild(questionBlock);
         }
     }
@@ -207,7 +230,7 @@ document.addEventListener('DOMContentLoaded', () => {
         if (ws.readyState === WebSocket.OPEN) {
             ws.send(JSON.stringify({
                 type: 'admin_action',
-                pollId: pollId,
+                correlationId: Date.now().toString(36) + Math.random().toString(36).substring(2),
                 action: action
             }));
         } else {
@@ -4,7 +4,7 @@
 document.addEventListener('DOMContentLoaded', () => {
     const pollId = window.location.pathname.split('/').filter(Boolean).pop();
     console.log('Poll ID:', pollId);
-    const ws = new WebSocket(`ws://${window.location.host}/ws/${pollId}`);
+    const ws = new WebSocket(`ws://${window.location.host}/ws/${pollId}`, ['livepolls.v2']);
 
     const statusMessageDiv = document.getElementById('statusMessage');
     const currentStatusText = document.getElementById('currentStatusText');
@@ -17,8 +17,49 @@ document.addEventListener('DOMContentLoaded', () => {
     const finalResultsSection = document.getElementById('finalResultsSection');
     const allPollResultsDiv = document.getElementById('allPollResults');
     const pollFinishedSection = document.getElementById('pollFinishedSection');
+    const yourAnswer = document.getElementById('yourAnswer');
 
     let currentQuestionData = null; // To store the current question's details
+    let pendingVote = null; // The submit_vote message waiting for vote_accepted or vote_rejected
+    let pendingVoteTimer = null;
+    const voteRetryDelay = 5000; // Resend an unanswered vote after this many milliseconds
+
+    // The server generates a voter ID on the first vote; keeping it lets the server
+    // replace earlier answers and tell us our current selection.
+    const voterIdKey = `livepolls.voterId.${pollId}`;
+    let voterId = localStorage.getItem(voterIdKey) || '';
+
+    function newCorrelationId() {
+        return Date.now().toString(36) + Math.random().toString(36).substring(2);
+    }
+
+    // Sends the pending vote, and again with the same idempotency key until it is answered,
+    // so a vote lost on a flaky network is retried without being counted twice.
+    function sendPendingVote() {
+        if (!pendingVote || ws.readyState !== WebSocket.OPEN) {
+            return;
+        }
+        ws.send(JSON.stringify(pendingVote));
+        clearTimeout(pendingVoteTimer);
+        pendingVoteTimer = setTimeout(sendPendingVote, voteRetryDelay);
+    }
+
+    function clearPendingVote() {
+        clearTimeout(pendingVoteTimer);
+        pendingVote = null;
+    }
+
+    function showYourAnswer(optionIds) {
+        if (!currentQuestionData || !optionIds || optionIds.length === 0) {
+            yourAnswer.classList.add('hidden');
+            return;
+        }
+        const texts = currentQuestionData.options
+            .filter(opt => optionIds.includes(opt.id))
+            .map(opt => opt.text);
+        yourAnswer.textContent = `Your answer: ${texts.join(', ')}`;
+        yourAnswer.classList.remove('hidden');
+    }
 
     ws.onopen = (event) => {
         console.log('WebSocket connection opened:', event);
@@ -32,9 +73,36 @@ document.addEventListener('DOMContentLoaded', () => {
 
 
         switch (message.type) {
-            case 'poll_state_update':
+            case 'welcome':
+                console.log(`Using protocol version ${message.protocolVersion}`);
+                break;
+            case 'poll_state':
                 updatePollState(message);
                 break;
+            case 'results_update':
+                // Only sent to participants when the question shows its results live
+                if (currentQuestionData && message.questionId === currentQuestionData.id) {
+                    resultsSection.classList.remove('hidden');
+                    displayCurrentQuestionResults(message);
+                }
+                break;
+            case 'vote_accepted':
+                if (pendingVote && message.idempotencyKey === pendingVote.idempotencyKey) {
+                    clearPendingVote();
+                }
+                voterId = message.voterId;
+                localStorage.setItem(voterIdKey, voterId);
+                if (currentQuestionData && message.questionId === currentQuestionData.id) {
+                    showYourAnswer(message.optionIds);
+                }
+                break;
+            case 'vote_rejected':
+                if (pendingVote && message.idempotencyKey === pendingVote.idempotencyKey) {
+                    clearPendingVote();
+                    submitVoteButton.disabled = false; // Let the voter try again
+                }
+                alert(`Vote not counted: ${message.message}`); // Using alert for simplicity
+                break;
             case 'error':
                 alert(`Error: ${message.message}`); // Using alert for simplicity
                 break;
@@ -77,6 +145,10 @@ document.addEventListener('DOMContentLoaded', () => {
                 questionSection.classList.remove('hidden');
                 currentQuestionData = message.currentQuestion;
                 renderQuestion(currentQuestionData);
+                if (currentQuestionData.votes) { // The question shows its results live
+                    resultsSection.classList.remove('hidden');
+                    displayCurrentQuestionResults(currentQuestionData);
+                }
                 break;
             case 'results':
                 questionSection.classList.remove('hidden'); // Still show question text
@@ -87,13 +159,17 @@ document.addEventListener('DOMContentLoaded', () => {
             case 'finished':
                 finalResultsSection.classList.remove('hidden');
                 pollFinishedSection.classList.remove('hidden');
-                displayFinalResults(message.results,message.allQuestions);
+                displayFinalResults(message.allQuestions);
                 break;
         }
     }
 
     function renderQuestion(question) {
         console.log(question)
+        if (pendingVote && pendingVote.questionId !== question.id) {
+            clearPendingVote(); // Voting on that question has closed
+        }
+        yourAnswer.classList.add('hidden');
         currentQuestionText.textContent = question.text;
         questionOptionsDiv.innerHTML = ''; // Clear previous options
 
@@ -104,9 +180,9 @@ document.addEventListener('DOMContentLoaded', () => {
             const inputName = question.type === 'single-select' ? 'option' : 'options';
 
             optionDiv.innerHTML = `
-                <input type="${inputType}" id="option-${option.ID}" name="${inputName}" value="${option.ID}"
+                <input type="${inputType}" id="option-${option.id}" name="${inputName}" value="${option.id}"
                        class="form-${inputType} h-4 w-4 text-blue-600 border-gray-300 focus:ring-blue-500 rounded">
-                <label for="option-${option.ID}" class="ml-2 text-gray-700 text-lg">${option.text}</label>
+                <label for="option-${option.id}" class="ml-2 text-gray-700 text-lg">${option.text}</label>
             `;
             questionOptionsDiv.appendChild(optionDiv);
         });
@@ -124,7 +200,7 @@ document.addEventListener('DOMContentLoaded', () => {
         const inputs = questionOptionsDiv.querySelectorAll('input[name="option"], input[name="options"]');
         inputs.forEach(input => {
             if (input.checked) {
-                selectedOptions.push(input.value);
+                selectedOptions.push(parseInt(input.value));
             }
         });
 
@@ -133,20 +209,23 @@ document.addEventListener('DOMContentLoaded', () => {
             return;
         }
 
-        if (currentQuestionData.Type === 'single-select' && selectedOptions.length > 1) {
+        if (currentQuestionData.type === 'single-select' && selectedOptions.length > 1) {
             alert("Please select only one option for this question."); // Using alert
             return;
         }
 
         if (ws.readyState === WebSocket.OPEN) {
-            ws.send(JSON.stringify({
+            const key = newCorrelationId();
+            pendingVote = {
                 type: 'submit_vote',
-                pollId: pollId,
-                questionId: currentQuestionData.ID.toString(),
-                selectedOptions: selectedOptions
-            }));
+                correlationId: key,
+                idempotencyKey: key,
+                questionId: currentQuestionData.id,
+                optionIds: selectedOptions,
+                voterId: voterId
+            };
+            sendPendingVote();
             submitVoteButton.disabled = true; // Disable button after submitting vote
-            alert("Vote submitted!"); // Using alert
         } else {
             alert('WebSocket not connected. Please refresh the page.'); // Using alert
         }
@@ -157,6 +236,7 @@ document.addEventListener('DOMContentLoaded', () => {
         console.log(message)
         
         if (!message.votes) {
+            currentQuestionResultsDiv.textContent = 'The results of this question are not shown.';
             return;
         }
 
@@ -170,7 +250,7 @@ document.addEventListener('DOMContentLoaded', () => {
         if (ws.currentPollState && ws.currentPollState.currentQuestion) {
 
             ws.currentPollState.currentQuestion.options.forEach(opt => {
-                currentQuestionOptionsMap.set(opt.ID, opt.text);
+                currentQuestionOptionsMap.set(opt.id, opt.text);
             });
         }
 
@@ -205,7 +285,7 @@ document.addEventListener('DOMContentLoaded', () => {
         //totalVotesSpan.textContent = totalVotes;
     }
 
-    function displayFinalResults(allResults, allQuestions) {
+    function displayFinalResults(allQuestions) {
         allPollResultsDiv.innerHTML = '';
 
         // This client-side code assumes it has access to the full poll structure
@@ -213,18 +293,24 @@ document.addEventListener('DOMContentLoaded', () => {
         // For this example, we'll use a simplified approach for demonstration.
         // In a real app, the poll structure would be loaded initially or sent with updates.
 
-        for (const questionId in allResults) {
-            const questionResults = allResults[questionId];
+        for (const currentQ of allQuestions) {
+            const questionResults = currentQ.votes || {};
             const questionBlock = document.createElement('div');
             questionBlock.classList.add('border', 'border-purple-300', 'p-4', 'rounded-lg', 'bg-purple-50', 'mb-4');
 
-            currentQ = allQuestions.find(el=>el.ID == questionId)
             let questionText = currentQ.text
             let questionOptionsMap = new Map();
 
-            currentQ.options.forEach(opt => questionOptionsMap.set(opt.ID, opt.text));
+            currentQ.options.forEach(opt => questionOptionsMap.set(opt.id, opt.text));
 
             questionBlock.innerHTML = `<h3>${questionText}</h3>`;
+            if (!currentQ.votes) {
+                const hidden = document.createElement('p');
+                hidden.textContent = 'The results of this question are not shown.';
+                questionBlock.appendChild(hidden);
+                allPollResultsDiv.appendChild(questionBlock);
+                continue;
+            }
             const resultsList = document.createElement('div');
             resultsList.classList.add('space-y-2');
 
@@ -6,11 +6,13 @@ function addQuestion(questionFromDatabase) {
     let databaseId = 0;
     let questionText = "";
     let questionType = "single-select";
+    let resultsVisibility = "";
     console.log(questionFromDatabase)
     if (questionFromDatabase) {
         databaseId = questionFromDatabase.ID;
         questionText = questionFromDatabase.text;
         questionType = questionFromDatabase.type;
+        resultsVisibility = questionFromDatabase.resultsVisibility || "";
     }
     const questionsContainer = document.getElementById('questionsContainer');
     const questionDiv = document.createElement('div');
@@ -32,6 +34,15 @@ function addQuestion(questionFromDatabase) {
                 <option value="multi-select" ${questionType == "multi-select" ? 'selected': ''}>Multi Select</option>
             </select>
         </div>
+        <div class="mb-4">
+            <label for="questionResultsVisibility-${questionCounter}" >Participants see the results:</label>
+            <select id="questionResultsVisibility-${questionCounter}" name="questionResultsVisibility">
+                <option value="" ${resultsVisibility == "" ? 'selected': ''}>Like the rest of the poll</option>
+                <option value="after_reveal" ${resultsVisibility == "after_reveal" ? 'selected': ''}>After the results are shown</option>
+                <option value="live" ${resultsVisibility == "live" ? 'selected': ''}>Live, while voting</option>
+                <option value="never" ${resultsVisibility == "never" ? 'selected': ''}>Never</option>
+            </select>
+        </div>
         <div id="optionsContainer-${questionCounter}" >
             <h4 >Options:</h4>
             <!-- Options will be added here by JavaScript -->
@@ -87,6 +98,7 @@ async function savePoll(pollDatabaseId) {
     document.querySelectorAll('.question-block').forEach(qDiv => {
         const questionText = qDiv.querySelector('input[name="questionText"]').value;
         const questionType = qDiv.querySelector('select[name="questionType"]').value;
+        const questionResultsVisibility = qDiv.querySelector('select[name="questionResultsVisibility"]').value;
         const options = [];
         qDiv.querySelectorAll('input[name="optionText"]').forEach(optInput => {
             if (optInput.value.trim() !== '') {
@@ -110,6 +122,7 @@ async function savePoll(pollDatabaseId) {
                 databaseId: qid|| 0,  
                 text: questionText,
                 type: questionType,
+                resultsVisibility: questionResultsVisibility,
                 options: options
             });
         }
@@ -124,10 +137,12 @@ async function savePoll(pollDatabaseId) {
         const response = await fetch('/admin/polls/save', {
             method: 'POST',
             headers: {
-                'Content-Type': 'application/json'
+                'Content-Type': 'application/json',
+                'X-CSRF-Token': document.querySelector('meta[name="csrf-token"]').content
             },
             body: JSON.stringify({
                 title: pollTitle,
+                resultsVisibility: document.getElementById('pollResultsVisibility').value,
                 questions: questions,
                 databaseId: pollDatabaseId // Passing the database ID if editing an existing poll
             })
@@ -138,7 +153,7 @@ async function savePoll(pollDatabaseId) {
             alert(`Poll created successfully! Poll ID: ${result.pollId}`); // Using alert
             window.location.href = `/admin/polls/edit/${result.pollId}`;
         } else {
-            alert(`Error creating poll: ${result.message || response.statusText}`); // Using alert
+            alert(`Error creating poll: ${result.error || result.message || response.statusText}`); // Using alert
         }
     } catch (error) {
         console.error('Error:', error);
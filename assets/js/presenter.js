@@ -0,0 +1,135 @@
+document.addEventListener('DOMContentLoaded', () => {
+    // The presenter view is read-only: it only listens to the poll and never sends messages
+    const pollId = document.body.dataset.inviteId;
+    const token = document.body.dataset.token;
+
+    const ws = new WebSocket(`ws://${window.location.host}/ws/${pollId}?role=presenter&token=${encodeURIComponent(token)}`, ['livepolls.v2']);
+
+    const connectionText = document.getElementById('connectionText');
+    const joinSection = document.getElementById('join');
+    const joinPin = document.getElementById('joinPin');
+    const joinPinValue = document.getElementById('joinPinValue');
+    const joinFooter = document.getElementById('joinFooter');
+    const joinFooterPin = document.getElementById('joinFooterPin');
+    const questionSection = document.getElementById('questionSection');
+    const questionText = document.getElementById('questionText');
+    const questionResults = document.getElementById('questionResults');
+    const totalVotesText = document.getElementById('totalVotesText');
+    const finishedSection = document.getElementById('finishedSection');
+    const finalResults = document.getElementById('finalResults');
+
+    let currentPollState = null;
+
+    ws.onopen = () => {
+        connectionText.textContent = 'Connected';
+    };
+
+    ws.onmessage = (event) => {
+        const message = JSON.parse(event.data);
+
+        switch (message.type) {
+            case 'welcome':
+                break;
+            case 'poll_state':
+                currentPollState = message;
+                updatePollState(message);
+                break;
+            case 'results_update':
+                if (currentPollState && currentPollState.currentQuestion && currentPollState.currentQuestion.id === message.questionId) {
+                    renderResults(questionResults, currentPollState.currentQuestion.options, message.votes);
+                    totalVotesText.textContent = `${message.totalVotes} votes`;
+                }
+                break;
+            case 'error':
+                connectionText.textContent = message.message;
+                break;
+            default:
+                console.warn('Unknown message type:', message.type);
+        }
+    };
+
+    ws.onclose = () => {
+        connectionText.textContent = 'Disconnected. Please refresh.';
+    };
+
+    function updatePollState(message) {
+        // The join code only exists while the poll is live
+        if (message.joinPin) {
+            joinPinValue.firstElementChild.textContent = message.joinPin;
+            joinFooterPin.textContent = ` · code ${message.joinPin}`;
+            joinPin.classList.remove('hidden');
+        } else {
+            joinFooterPin.textContent = '';
+            joinPin.classList.add('hidden');
+        }
+
+        joinSection.classList.add('hidden');
+        joinFooter.classList.add('hidden');
+        questionSection.classList.add('hidden');
+        finishedSection.classList.add('hidden');
+
+        switch (message.status) {
+            case 'setup':
+                joinSection.classList.remove('hidden');
+                break;
+            case 'active':
+            case 'results':
+                questionSection.classList.remove('hidden');
+                joinFooter.classList.remove('hidden');
+                if (message.currentQuestion) {
+                    questionText.textContent = message.currentQuestion.text;
+                    const votes = message.currentQuestion.votes || {};
+                    renderResults(questionResults, message.currentQuestion.options, votes);
+                    totalVotesText.textContent = `${sumVotes(votes)} votes`;
+                }
+                break;
+            case 'finished':
+                finishedSection.classList.remove('hidden');
+                finalResults.innerHTML = '';
+                for (const q of message.allQuestions || []) {
+                    const block = document.createElement('article');
+                    const heading = document.createElement('h3');
+                    heading.textContent = q.text;
+                    const results = document.createElement('div');
+                    block.append(heading, results);
+                    renderResults(results, q.options, q.votes || {});
+                    finalResults.appendChild(block);
+                }
+                break;
+        }
+    }
+
+    function sumVotes(votes) {
+        return Object.values(votes).reduce((sum, count) => sum + count, 0);
+    }
+
+    // renderResults draws one large bar per option, in the order the options were written
+    function renderResults(container, options, votes) {
+        const total = sumVotes(votes);
+        container.innerHTML = '';
+        for (const opt of options) {
+            const count = votes[String(opt.id)] || 0;
+            const percentage = total > 0 ? Math.round(count / total * 100) : 0;
+
+            const item = document.createElement('div');
+            item.classList.add('presenter-option');
+            const labels = document.createElement('div');
+            labels.classList.add('votes-flex-container');
+            const text = document.createElement('span');
+            text.textContent = opt.text;
+            const counts = document.createElement('span');
+            counts.textContent = `${count} (${percentage}%)`;
+            labels.append(text, counts);
+
+            const barContainer = document.createElement('div');
+            barContainer.classList.add('vote-bar-container');
+            const bar = document.createElement('div');
+            bar.classList.add('vote-bar');
+            bar.style.width = `${percentage}%`;
+            barContainer.appendChild(bar);
+
+            item.append(labels, barContainer);
+            container.appendChild(item);
+        }
+    }
+});
@@ -1,22 +1,159 @@
 package main
 
 import (
-	"net/url"
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
 
+	"github.com/aspcodenet/systementorlivepolls/data"
 	"github.com/aspcodenet/systementorlivepolls/pages"
+	"github.com/aspcodenet/systementorlivepolls/utils"
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
 )
 
+// Headers of an API request signed with an access/secret key pair, see utils.SignRequest.
+const (
+	APIKeyHeader       = "X-Api-Key"
+	APITimestampHeader = "X-Api-Timestamp"
+	APINonceHeader     = "X-Api-Nonce"
+	APISignatureHeader = "X-Api-Signature"
+
+	// apiSignatureMaxAge is how far the request timestamp may differ from the server clock.
+	apiSignatureMaxAge = 5 * time.Minute
+
+	// maxSignedBodySize limits the body read to check a signature: room for a 1 MB poll definition
+	// upload with its multipart framing.
+	maxSignedBodySize = 2 << 20
+)
+
+// errBodyTooLarge is returned by verifySignedRequest for bodies over maxSignedBodySize.
+var errBodyTooLarge = errors.New("Request body is too large.")
+
+// usedNonces remembers the nonces of signed requests within apiSignatureMaxAge so a captured
+// request cannot be replayed.
+var usedNonces = &nonceCache{seen: make(map[string]time.Time)}
+
+type nonceCache struct {
+	mu        sync.Mutex
+	seen      map[string]time.Time // Nonce to when it may be forgotten
+	nextSweep time.Time
+}
+
+// add records a nonce and returns false if it has already been used. Expired nonces are swept
+// at most once per apiSignatureMaxAge, so a request does not scan the whole cache.
+func (n *nonceCache) add(nonce string, now time.Time) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if now.After(n.nextSweep) {
+		for k, expires := range n.seen {
+			if now.After(expires) {
+				delete(n.seen, k)
+			}
+		}
+		n.nextSweep = now.Add(apiSignatureMaxAge)
+	}
+	if expires, ok := n.seen[nonce]; ok && !now.After(expires) {
+		return false
+	}
+	n.seen[nonce] = now.Add(2 * apiSignatureMaxAge)
+	return true
+}
+
+// WebPageAuthRequired sends visitors who are not logged in to the login page. The page they asked
+// for is kept in the session as a path, so they return to it whatever host or scheme a proxy
+// in front of us uses. Only GET requests are returned to, a form cannot be resubmitted.
 func WebPageAuthRequired(c *gin.Context) {
 	session := sessions.Default(c)
 	user := session.Get(pages.Userkey)
-	var redirectUrl = url.QueryEscape("http://" + c.Request.Host + c.Request.RequestURI)
 	if user == nil {
-		c.Redirect(302, "/loginv1?redirect_uri="+redirectUrl)
-		// Abort the request with the appropriate error code
+		if c.Request.Method == http.MethodGet {
+			pages.RememberLoginRedirect(c, c.Request.URL.RequestURI())
+		}
+		c.Redirect(302, "/loginv1")
+		c.Abort()
 		return
 	}
 	// Continue down the chain to handler etc
 	c.Next()
 }
+
+// APIAuthRequired is WebPageAuthRequired for /api routes: it answers with a JSON 401 instead of
+// redirecting to the login page. Requests signed with an API key are accepted without a session,
+// requests that use the session cookie must send its CSRF token to change anything.
+func APIAuthRequired(c *gin.Context) {
+	if c.GetHeader(APIKeyHeader) != "" {
+		email, err := verifySignedRequest(c.Request, time.Now())
+		if errors.Is(err, errBodyTooLarge) {
+			c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, pages.APIError{Error: err.Error()})
+			return
+		}
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, pages.APIError{Error: err.Error()})
+			return
+		}
+		c.Set(pages.Userkey, email)
+		c.Next()
+		return
+	}
+
+	session := sessions.Default(c)
+	user := session.Get(pages.Userkey)
+	if user == nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, pages.APIError{Error: "Authentication required."})
+		return
+	}
+	if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead && !pages.ValidCSRFToken(c) {
+		c.AbortWithStatusJSON(http.StatusForbidden, pages.APIError{Error: "Missing or invalid " + pages.CSRFHeader + " header."})
+		return
+	}
+	c.Next()
+}
+
+// verifySignedRequest checks the API key headers of a request and returns the email of the key owner.
+func verifySignedRequest(r *http.Request, now time.Time) (string, error) {
+	accessKey := r.Header.Get(APIKeyHeader)
+	timestamp := r.Header.Get(APITimestampHeader)
+	nonce := r.Header.Get(APINonceHeader)
+	signature := r.Header.Get(APISignatureHeader)
+	if timestamp == "" || nonce == "" || signature == "" {
+		return "", errors.New("Signed requests need " + APITimestampHeader + ", " + APINonceHeader + " and " + APISignatureHeader + " headers.")
+	}
+
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return "", errors.New("Invalid timestamp.")
+	}
+	age := now.Sub(time.Unix(unix, 0))
+	if age > apiSignatureMaxAge || age < -apiSignatureMaxAge {
+		return "", errors.New("Request timestamp is too old or in the future.")
+	}
+
+	adminUser, secret, err := data.FindAdminUserByAccessKey(accessKey)
+	if err != nil {
+		return "", errors.New("Invalid API key.")
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(nil, r.Body, maxSignedBodySize))
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return "", errBodyTooLarge
+	}
+	if err != nil {
+		return "", errors.New("Failed to read request body.")
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body)) // Let the handler read the body again
+
+	expected := utils.SignRequest(secret, r.Method, r.URL.RequestURI(), timestamp, nonce, body)
+	if !utils.VerifySignature(expected, signature) {
+		return "", errors.New("Invalid signature.")
+	}
+	if !usedNonces.add(accessKey+":"+nonce, now) {
+		return "", errors.New("Request has already been used.")
+	}
+	return adminUser.Email, nil
+}
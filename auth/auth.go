@@ -0,0 +1,110 @@
+// Package auth logs admins in with an external identity provider. Every provider implements
+// Provider; the login pages keep one Flow per login attempt in the session and hand it back to
+// the provider when the browser returns with a code.
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/aspcodenet/systementorlivepolls/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// ErrNoEmail is returned when a provider does not tell us the user's email address.
+var ErrNoEmail = errors.New("the identity provider did not return an email address")
+
+// Identity is the user a provider vouches for.
+type Identity struct {
+	Email         string
+	EmailVerified bool   // The provider vouches that the user owns Email; only then does it find an existing account
+	Subject       string // The provider's stable ID of the user, if it has one
+	Name          string
+}
+
+// Provider is a way to log in.
+type Provider interface {
+	// Name identifies the provider in URLs, like "github".
+	Name() string
+	// DisplayName is shown on the login page, like "GitHub".
+	DisplayName() string
+	// AuthCodeURL returns the URL the browser is sent to, to log in with the provider.
+	AuthCodeURL(ctx context.Context, flow *Flow) (string, error)
+	// Exchange trades the code the provider sent back for the identity of the user.
+	Exchange(ctx context.Context, flow *Flow, code string) (*Identity, error)
+}
+
+// Flow is one login attempt. It is kept in the session between AuthCodeURL and Exchange.
+type Flow struct {
+	Provider    string `json:"provider"`
+	State       string `json:"state"`    // Returned by the provider, checked against CSRF
+	Nonce       string `json:"nonce"`    // Put in OpenID Connect ID tokens, checked against replays
+	Verifier    string `json:"verifier"` // PKCE code verifier
+	RedirectURL string `json:"redirectUrl"`
+}
+
+// NewFlow starts a login with a provider. redirectURL is the absolute URL of the callback.
+func NewFlow(provider, redirectURL string) (*Flow, error) {
+	flow := &Flow{Provider: provider, RedirectURL: redirectURL}
+	for _, value := range []*string{&flow.State, &flow.Nonce, &flow.Verifier} {
+		random, err := utils.RandString(32)
+		if err != nil {
+			return nil, err
+		}
+		*value = random
+	}
+	return flow, nil
+}
+
+// ProvidersFromEnv returns the providers configured in the environment, in the order they are
+// shown on the login page:
+//
+//	ADMIN_SSO_CLIENTID, ADMIN_SSO_CLIENTSECRET  GitHub OAuth app
+//	OIDC_ISSUER, OIDC_CLIENT_ID, OIDC_CLIENT_SECRET  OpenID Connect, e.g. Microsoft Entra ID or Keycloak
+//	OIDC_NAME      Button text for OpenID Connect, "Single sign-on" by default
+//	OIDC_SCOPES    Space separated scopes, "openid email profile" by default
+//	DEV_LOGIN      "true" adds a login without password, refused when gin runs in release mode
+//	DEV_LOGIN_EMAIL  Who the development login logs in as, DefaultDevEmail by default
+func ProvidersFromEnv() []Provider {
+	var providers []Provider
+	if os.Getenv("DEV_LOGIN") == "true" {
+		if gin.Mode() == gin.ReleaseMode {
+			log.Println("DEV_LOGIN is set but gin runs in release mode - the development login is disabled")
+		} else {
+			log.Println("DEV_LOGIN is set - anyone can log in as an admin without a password")
+			providers = append(providers, &Dev{Email: os.Getenv("DEV_LOGIN_EMAIL")})
+		}
+	}
+	if id := os.Getenv("ADMIN_SSO_CLIENTID"); id != "" {
+		providers = append(providers, &GitHub{ClientID: id, ClientSecret: os.Getenv("ADMIN_SSO_CLIENTSECRET")})
+	}
+	if issuer := os.Getenv("OIDC_ISSUER"); issuer != "" {
+		p := &OIDC{
+			Label:        os.Getenv("OIDC_NAME"),
+			Issuer:       issuer,
+			ClientID:     os.Getenv("OIDC_CLIENT_ID"),
+			ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+			Scopes:       strings.Fields(os.Getenv("OIDC_SCOPES")),
+		}
+		if p.ClientID == "" {
+			log.Println("OIDC_ISSUER is set but OIDC_CLIENT_ID is not - OpenID Connect login is disabled")
+		} else {
+			providers = append(providers, p)
+		}
+	}
+	return providers
+}
+
+// Find returns the provider with the given name.
+func Find(providers []Provider, name string) (Provider, error) {
+	for _, p := range providers {
+		if p.Name() == name {
+			return p, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown login provider %q", name)
+}
@@ -0,0 +1,143 @@
+package authtest
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// GitHubEmail is an entry of GitHub's GET /user/emails.
+type GitHubEmail struct {
+	Email      string  `json:"email"`
+	Primary    bool    `json:"primary"`
+	Verified   bool    `json:"verified"`
+	Visibility *string `json:"visibility"`
+}
+
+// GitHubServer is a fake of GitHub's OAuth app endpoints and the user emails API. Like
+// OIDCServer it approves every authorization request at once. GitHub sends the browser back to
+// the callback URL registered with the app, so set CallbackURL before logging in.
+// Use the server's URL as both auth.GitHub.BaseURL and auth.GitHub.APIURL.
+type GitHubServer struct {
+	*httptest.Server
+	ClientID     string
+	ClientSecret string
+	CallbackURL  string
+	UserID       int64
+	Login        string
+	Emails       []GitHubEmail
+	// APIStatus makes the REST API answer with this status instead, e.g. 500
+	APIStatus int
+
+	mu     sync.Mutex
+	codes  map[string]bool
+	tokens map[string]bool
+}
+
+// NewGitHubServer starts a fake GitHub whose user octocat, ID 1, has the verified primary email
+// user@example.com. Close it when the test is done.
+func NewGitHubServer(clientID, clientSecret string) *GitHubServer {
+	s := &GitHubServer{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		UserID:       1,
+		Login:        "octocat",
+		Emails:       []GitHubEmail{{Email: "user@example.com", Primary: true, Verified: true}},
+		codes:        make(map[string]bool),
+		tokens:       make(map[string]bool),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login/oauth/authorize", s.authorize)
+	mux.HandleFunc("/login/oauth/access_token", s.accessToken)
+	mux.HandleFunc("/user", s.user)
+	mux.HandleFunc("/user/emails", s.userEmails)
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+func (s *GitHubServer) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("client_id") != s.ClientID {
+		http.Error(w, "The client_id is not registered", http.StatusNotFound)
+		return
+	}
+	target, err := url.Parse(s.CallbackURL)
+	if err != nil || s.CallbackURL == "" {
+		http.Error(w, "no callback URL registered", http.StatusBadRequest)
+		return
+	}
+	code := base64.RawURLEncoding.EncodeToString(randomBytes(16))
+	s.mu.Lock()
+	s.codes[code] = true
+	s.mu.Unlock()
+
+	values := target.Query()
+	values.Set("code", code)
+	values.Set("state", q.Get("state"))
+	target.RawQuery = values.Encode()
+	http.Redirect(w, r, target.String(), http.StatusFound)
+}
+
+// accessToken answers errors with 200 like GitHub does.
+func (s *GitHubServer) accessToken(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ClientID     string `json:"client_id"`
+		ClientSecret string `json:"client_secret"`
+		Code         string `json:"code"`
+	}
+	if r.Method != http.MethodPost || json.NewDecoder(r.Body).Decode(&req) != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	if req.ClientID != s.ClientID || req.ClientSecret != s.ClientSecret {
+		writeJSON(w, http.StatusOK, map[string]string{"error": "incorrect_client_credentials"})
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.codes[req.Code] {
+		writeJSON(w, http.StatusOK, map[string]string{"error": "bad_verification_code"})
+		return
+	}
+	delete(s.codes, req.Code) // Codes can only be used once
+	token := "gho_" + base64.RawURLEncoding.EncodeToString(randomBytes(16))
+	s.tokens[token] = true
+	writeJSON(w, http.StatusOK, map[string]string{
+		"access_token": token,
+		"token_type":   "bearer",
+		"scope":        "user:email",
+	})
+}
+
+// authorized checks the access token of an API request, and answers it if it fails.
+func (s *GitHubServer) authorized(w http.ResponseWriter, r *http.Request) bool {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	s.mu.Lock()
+	ok := s.tokens[token]
+	s.mu.Unlock()
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"message": "Bad credentials"})
+		return false
+	}
+	if s.APIStatus != 0 {
+		writeJSON(w, s.APIStatus, map[string]string{"message": http.StatusText(s.APIStatus)})
+		return false
+	}
+	return true
+}
+
+func (s *GitHubServer) user(w http.ResponseWriter, r *http.Request) {
+	if s.authorized(w, r) {
+		writeJSON(w, http.StatusOK, map[string]interface{}{"id": s.UserID, "login": s.Login, "name": nil})
+	}
+}
+
+func (s *GitHubServer) userEmails(w http.ResponseWriter, r *http.Request) {
+	if s.authorized(w, r) {
+		writeJSON(w, http.StatusOK, s.Emails)
+	}
+}
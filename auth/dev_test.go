@@ -0,0 +1,53 @@
+package auth
+
+import (
+	"context"
+	"net/url"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDevLogin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	p := &Dev{}
+	flow, err := NewFlow(p.Name(), "http://localhost:8080/login/oauth2/code/dev")
+	require.NoError(t, err)
+
+	authURL, err := p.AuthCodeURL(context.Background(), flow)
+	require.NoError(t, err)
+	callback, err := url.Parse(authURL)
+	require.NoError(t, err)
+	assert.Equal(t, "localhost:8080", callback.Host)
+	assert.Equal(t, flow.State, callback.Query().Get("state"))
+
+	_, err = p.Exchange(context.Background(), flow, "guessed")
+	assert.Error(t, err)
+	identity, err := p.Exchange(context.Background(), flow, callback.Query().Get("code"))
+	require.NoError(t, err)
+	assert.Equal(t, DefaultDevEmail, identity.Email)
+}
+
+func TestDevLoginRefusedInRelease(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	defer gin.SetMode(gin.TestMode)
+	t.Setenv("ADMIN_SSO_CLIENTID", "")
+	t.Setenv("OIDC_ISSUER", "")
+	t.Setenv("DEV_LOGIN", "true")
+	assert.Empty(t, ProvidersFromEnv())
+
+	p := &Dev{Email: "me@example.com"}
+	flow := &Flow{Provider: p.Name(), State: "state", Nonce: "nonce"}
+	_, err := p.AuthCodeURL(context.Background(), flow)
+	assert.ErrorIs(t, err, ErrDevLoginInRelease)
+	_, err = p.Exchange(context.Background(), flow, "nonce")
+	assert.ErrorIs(t, err, ErrDevLoginInRelease)
+
+	gin.SetMode(gin.DebugMode)
+	t.Setenv("DEV_LOGIN_EMAIL", "me@example.com")
+	providers := ProvidersFromEnv()
+	require.Len(t, providers, 1)
+	assert.Equal(t, "Development login (me@example.com)", providers[0].DisplayName())
+}
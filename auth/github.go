@@ -0,0 +1,163 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// GitHub's endpoints, used when GitHub.BaseURL and GitHub.APIURL are empty.
+const (
+	GitHubBaseURL = "https://github.com"
+	GitHubAPIURL  = "https://api.github.com"
+)
+
+// GitHub logs in with a GitHub OAuth app. The callback URL registered with the app is used, so
+// Flow.RedirectURL is not sent to GitHub.
+type GitHub struct {
+	ClientID     string
+	ClientSecret string
+	BaseURL      string       // For the authorize and token endpoints, GitHubBaseURL when empty
+	APIURL       string       // For the REST API, GitHubAPIURL when empty
+	HTTPClient   *http.Client // http.DefaultClient when nil
+}
+
+func (g *GitHub) baseURL() string {
+	if g.BaseURL == "" {
+		return GitHubBaseURL
+	}
+	return strings.TrimRight(g.BaseURL, "/")
+}
+
+func (g *GitHub) apiURL() string {
+	if g.APIURL == "" {
+		return GitHubAPIURL
+	}
+	return strings.TrimRight(g.APIURL, "/")
+}
+
+func (g *GitHub) client() *http.Client {
+	if g.HTTPClient == nil {
+		return http.DefaultClient
+	}
+	return g.HTTPClient
+}
+
+func (g *GitHub) Name() string        { return "github" }
+func (g *GitHub) DisplayName() string { return "GitHub" }
+
+// AuthCodeURL asks for the user:email scope, which is all we need to know who logged in.
+func (g *GitHub) AuthCodeURL(ctx context.Context, flow *Flow) (string, error) {
+	query := url.Values{
+		"scope":     {"user:email"},
+		"client_id": {g.ClientID},
+		"state":     {flow.State},
+	}
+	return g.baseURL() + "/login/oauth/authorize?" + query.Encode(), nil
+}
+
+// Exchange trades the code for an access token and uses it to look up the user's ID and verified
+// primary email address. The token is only used for this and never logged or stored.
+func (g *GitHub) Exchange(ctx context.Context, flow *Flow, code string) (*Identity, error) {
+	requestJSON, err := json.Marshal(map[string]string{
+		"client_id":     g.ClientID,
+		"client_secret": g.ClientSecret,
+		"code":          code,
+	})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", g.baseURL()+"/login/oauth/access_token", bytes.NewBuffer(requestJSON))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := g.client().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to connect to access_token endpoint: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Represents the response received from Github. It answers 200 with an error, e.g.
+	// bad_verification_code, when the code is refused.
+	var ghresp struct {
+		AccessToken      string `json:"access_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("access_token endpoint returned %s", resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&ghresp); err != nil {
+		return nil, fmt.Errorf("invalid response from access_token endpoint: %w", err)
+	}
+	if ghresp.Error != "" {
+		return nil, fmt.Errorf("GitHub refused the code: %s %s", ghresp.Error, ghresp.ErrorDescription)
+	}
+	if ghresp.AccessToken == "" {
+		return nil, errors.New("no access token from GitHub")
+	}
+
+	var user struct {
+		ID    int64  `json:"id"`
+		Login string `json:"login"`
+		Name  string `json:"name"`
+	}
+	if err := g.getAPI(ctx, ghresp.AccessToken, "/user", &user); err != nil {
+		return nil, err
+	}
+	if user.ID == 0 {
+		return nil, errors.New("GitHub did not return a user ID")
+	}
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	if err := g.getAPI(ctx, ghresp.AccessToken, "/user/emails", &emails); err != nil {
+		return nil, err
+	}
+
+	identity := &Identity{Subject: strconv.FormatInt(user.ID, 10), Name: user.Name}
+	if identity.Name == "" {
+		identity.Name = user.Login
+	}
+	for _, e := range emails {
+		if e.Primary && e.Verified && e.Email != "" {
+			identity.Email, identity.EmailVerified = e.Email, true
+			return identity, nil
+		}
+	}
+	return nil, ErrNoEmail
+}
+
+// getAPI reads a GitHub REST API resource as JSON into v.
+func (g *GitHub) getAPI(ctx context.Context, accessToken, path string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", g.apiURL()+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	resp, err := g.client().Do(req)
+	if err != nil {
+		return fmt.Errorf("unable to connect to GitHub API: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GitHub API %s returned %s", path, resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("invalid response from GitHub API %s: %w", path, err)
+	}
+	return nil
+}
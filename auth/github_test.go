@@ -0,0 +1,98 @@
+package auth
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aspcodenet/systementorlivepolls/auth/authtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const gitHubCallback = "http://polls.example.com/login/oauth2/code/github"
+
+func newTestGitHub(t *testing.T) (*GitHub, *authtest.GitHubServer) {
+	server := authtest.NewGitHubServer("client", "secret")
+	t.Cleanup(server.Close)
+	server.CallbackURL = gitHubCallback
+	return &GitHub{ClientID: "client", ClientSecret: "secret", BaseURL: server.URL, APIURL: server.URL, HTTPClient: server.Client()}, server
+}
+
+func TestGitHubLogin(t *testing.T) {
+	p, server := newTestGitHub(t)
+	server.Emails = []authtest.GitHubEmail{{Email: "teacher@school.example", Primary: true, Verified: true}}
+	flow, err := NewFlow(p.Name(), gitHubCallback)
+	require.NoError(t, err)
+
+	identity, err := p.Exchange(context.Background(), flow, login(t, p, flow))
+	require.NoError(t, err)
+	assert.Equal(t, "teacher@school.example", identity.Email)
+	assert.Equal(t, "1", identity.Subject)
+	assert.Equal(t, "octocat", identity.Name)
+}
+
+func TestGitHubChoosesVerifiedPrimaryEmail(t *testing.T) {
+	cases := []struct {
+		name   string
+		emails []authtest.GitHubEmail
+		want   string
+	}{
+		{"primary after others", []authtest.GitHubEmail{
+			{Email: "old@example.com", Verified: true},
+			{Email: "noreply@users.github.com", Verified: true},
+			{Email: "main@example.com", Primary: true, Verified: true},
+		}, "main@example.com"},
+		{"unverified primary", []authtest.GitHubEmail{
+			{Email: "main@example.com", Primary: true},
+			{Email: "other@example.com", Verified: true},
+		}, ""},
+		{"no emails", []authtest.GitHubEmail{}, ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			p, server := newTestGitHub(t)
+			server.Emails = tc.emails
+			flow, err := NewFlow(p.Name(), gitHubCallback)
+			require.NoError(t, err)
+
+			identity, err := p.Exchange(context.Background(), flow, login(t, p, flow))
+			if tc.want == "" {
+				assert.ErrorIs(t, err, ErrNoEmail)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, identity.Email)
+		})
+	}
+}
+
+func TestGitHubAPIFailure(t *testing.T) {
+	p, server := newTestGitHub(t)
+	server.APIStatus = 502
+	flow, err := NewFlow(p.Name(), gitHubCallback)
+	require.NoError(t, err)
+
+	_, err = p.Exchange(context.Background(), flow, login(t, p, flow))
+	assert.ErrorContains(t, err, "502")
+	assert.NotContains(t, err.Error(), "gho_", "the access token must not end up in logs")
+}
+
+func TestGitHubRejects(t *testing.T) {
+	p, _ := newTestGitHub(t)
+	flow, err := NewFlow(p.Name(), gitHubCallback)
+	require.NoError(t, err)
+	code := login(t, p, flow)
+
+	_, err = p.Exchange(context.Background(), flow, "made-up")
+	assert.ErrorContains(t, err, "bad_verification_code")
+
+	wrongSecret := *p
+	wrongSecret.ClientSecret = "guessed"
+	_, err = wrongSecret.Exchange(context.Background(), flow, code)
+	assert.ErrorContains(t, err, "incorrect_client_credentials")
+
+	_, err = p.Exchange(context.Background(), flow, code)
+	require.NoError(t, err)
+	_, err = p.Exchange(context.Background(), flow, code)
+	assert.Error(t, err, "codes can only be used once")
+}
@@ -0,0 +1,131 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+// OIDC logs in with any OpenID Connect provider, like Microsoft Entra ID, Keycloak or Google.
+// The endpoints are found with discovery from the issuer URL the first time they are needed,
+// so the server starts even when the provider cannot be reached. Logins use PKCE and a nonce,
+// and the ID token is validated against the provider's keys.
+type OIDC struct {
+	ID           string // Name in URLs, "oidc" when empty
+	Label        string // Button text, "Single sign-on" when empty
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string     // "openid email profile" when empty
+	HTTPClient   *http.Client // http.DefaultClient when nil
+
+	mu       sync.Mutex
+	provider *oidc.Provider
+}
+
+func (o *OIDC) Name() string {
+	if o.ID == "" {
+		return "oidc"
+	}
+	return o.ID
+}
+
+func (o *OIDC) DisplayName() string {
+	if o.Label == "" {
+		return "Single sign-on"
+	}
+	return o.Label
+}
+
+func (o *OIDC) context(ctx context.Context) context.Context {
+	if o.HTTPClient != nil {
+		return oidc.ClientContext(ctx, o.HTTPClient)
+	}
+	return ctx
+}
+
+// discover returns the provider's endpoints, fetching them on first use.
+func (o *OIDC) discover(ctx context.Context) (*oidc.Provider, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.provider == nil {
+		// The key set fetches keys with the context it was created with, so it must outlive the request
+		provider, err := oidc.NewProvider(o.context(context.WithoutCancel(ctx)), o.Issuer)
+		if err != nil {
+			return nil, fmt.Errorf("OpenID Connect discovery for %s failed: %w", o.Issuer, err)
+		}
+		o.provider = provider
+	}
+	return o.provider, nil
+}
+
+func (o *OIDC) config(provider *oidc.Provider, flow *Flow) *oauth2.Config {
+	scopes := o.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{oidc.ScopeOpenID, "email", "profile"}
+	}
+	return &oauth2.Config{
+		ClientID:     o.ClientID,
+		ClientSecret: o.ClientSecret,
+		Endpoint:     provider.Endpoint(),
+		RedirectURL:  flow.RedirectURL,
+		Scopes:       scopes,
+	}
+}
+
+func (o *OIDC) AuthCodeURL(ctx context.Context, flow *Flow) (string, error) {
+	provider, err := o.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	return o.config(provider, flow).AuthCodeURL(flow.State, oauth2.S256ChallengeOption(flow.Verifier), oidc.Nonce(flow.Nonce)), nil
+}
+
+// Exchange redeems the code and validates the ID token: its signature, issuer, audience, expiry
+// and nonce. Users whose provider says their email is not verified are refused.
+func (o *OIDC) Exchange(ctx context.Context, flow *Flow, code string) (*Identity, error) {
+	provider, err := o.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ctx = o.context(ctx)
+	token, err := o.config(provider, flow).Exchange(ctx, code, oauth2.VerifierOption(flow.Verifier))
+	if err != nil {
+		return nil, fmt.Errorf("failed to redeem the login code: %w", err)
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, errors.New("the token response has no ID token")
+	}
+	idToken, err := provider.Verifier(&oidc.Config{ClientID: o.ClientID}).Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ID token: %w", err)
+	}
+	if idToken.Nonce != flow.Nonce {
+		return nil, errors.New("the ID token nonce does not match the login")
+	}
+
+	var claims struct {
+		Email             string `json:"email"`
+		EmailVerified     *bool  `json:"email_verified"` // Not sent by every provider, e.g. Entra ID
+		Name              string `json:"name"`
+		PreferredUsername string `json:"preferred_username"`
+	}
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("invalid ID token claims: %w", err)
+	}
+	if claims.Email == "" {
+		return nil, ErrNoEmail
+	}
+	if claims.EmailVerified != nil && !*claims.EmailVerified {
+		return nil, fmt.Errorf("the email address %s is not verified", claims.Email)
+	}
+	// Without the claim the email is only trusted for the account this subject already has
+	verified := claims.EmailVerified != nil && *claims.EmailVerified
+	return &Identity{Email: claims.Email, Subject: idToken.Subject, Name: claims.Name, EmailVerified: verified}, nil
+}
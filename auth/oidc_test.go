@@ -0,0 +1,134 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/aspcodenet/systementorlivepolls/auth/authtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// login runs a flow against the fake provider up to the callback and returns the code it sent.
+func login(t *testing.T, p Provider, flow *Flow) string {
+	t.Helper()
+	authURL, err := p.AuthCodeURL(context.Background(), flow)
+	require.NoError(t, err)
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(authURL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+
+	callback, err := url.Parse(resp.Header.Get("Location"))
+	require.NoError(t, err)
+	assert.Equal(t, flow.State, callback.Query().Get("state"))
+	return callback.Query().Get("code")
+}
+
+func newTestOIDC(t *testing.T) (*OIDC, *authtest.OIDCServer) {
+	server := authtest.NewOIDCServer("client", "secret")
+	t.Cleanup(server.Close)
+	return &OIDC{Issuer: server.URL, ClientID: "client", ClientSecret: "secret"}, server
+}
+
+func TestOIDCLogin(t *testing.T) {
+	p, server := newTestOIDC(t)
+	server.Email = "teacher@university.example"
+	flow, err := NewFlow(p.Name(), "http://polls.example.com/login/oauth2/code/oidc")
+	require.NoError(t, err)
+
+	identity, err := p.Exchange(context.Background(), flow, login(t, p, flow))
+	require.NoError(t, err)
+	assert.Equal(t, "teacher@university.example", identity.Email)
+	assert.Equal(t, "user-1", identity.Subject)
+	assert.Equal(t, "Test User", identity.Name)
+	assert.True(t, identity.EmailVerified)
+}
+
+func TestOIDCWithoutEmailVerifiedClaim(t *testing.T) {
+	p, server := newTestOIDC(t)
+	server.Claims = map[string]interface{}{"email_verified": nil}
+	flow, err := NewFlow(p.Name(), "http://polls.example.com/login/oauth2/code/oidc")
+	require.NoError(t, err)
+
+	identity, err := p.Exchange(context.Background(), flow, login(t, p, flow))
+	require.NoError(t, err)
+	assert.False(t, identity.EmailVerified, "an email the provider does not vouch for is not verified")
+}
+
+func TestOIDCRejects(t *testing.T) {
+	cases := []struct {
+		name   string
+		modify func(server *authtest.OIDCServer, flow *Flow)
+	}{
+		{"wrong PKCE verifier", func(_ *authtest.OIDCServer, flow *Flow) {
+			flow.Verifier = "another-verifier-that-is-long-enough-1234567"
+		}},
+		{"wrong nonce", func(_ *authtest.OIDCServer, flow *Flow) { flow.Nonce = "replayed" }},
+		{"wrong audience", func(server *authtest.OIDCServer, _ *Flow) {
+			server.Claims = map[string]interface{}{"aud": "another-client"}
+		}},
+		{"expired token", func(server *authtest.OIDCServer, _ *Flow) { server.Claims = map[string]interface{}{"exp": 1} }},
+		{"unverified email", func(server *authtest.OIDCServer, _ *Flow) {
+			server.Claims = map[string]interface{}{"email_verified": false}
+		}},
+		{"no email", func(server *authtest.OIDCServer, _ *Flow) { server.Email = "" }},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			p, server := newTestOIDC(t)
+			flow, err := NewFlow(p.Name(), "http://polls.example.com/login/oauth2/code/oidc")
+			require.NoError(t, err)
+			code := login(t, p, flow)
+			tc.modify(server, flow)
+
+			_, err = p.Exchange(context.Background(), flow, code)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestOIDCCodeCanOnlyBeUsedOnce(t *testing.T) {
+	p, _ := newTestOIDC(t)
+	flow, err := NewFlow(p.Name(), "http://polls.example.com/login/oauth2/code/oidc")
+	require.NoError(t, err)
+	code := login(t, p, flow)
+
+	_, err = p.Exchange(context.Background(), flow, code)
+	require.NoError(t, err)
+	_, err = p.Exchange(context.Background(), flow, code)
+	assert.Error(t, err)
+}
+
+func TestOIDCDiscoveryFailure(t *testing.T) {
+	p := &OIDC{Issuer: "http://127.0.0.1:1", ClientID: "client"}
+	_, err := p.AuthCodeURL(context.Background(), &Flow{})
+	assert.ErrorContains(t, err, "discovery")
+}
+
+func TestProvidersFromEnv(t *testing.T) {
+	t.Setenv("ADMIN_SSO_CLIENTID", "")
+	t.Setenv("OIDC_ISSUER", "")
+	t.Setenv("DEV_LOGIN", "")
+	assert.Empty(t, ProvidersFromEnv())
+
+	t.Setenv("ADMIN_SSO_CLIENTID", "gh")
+	t.Setenv("OIDC_ISSUER", "https://login.example.com")
+	t.Setenv("OIDC_CLIENT_ID", "polls")
+	t.Setenv("OIDC_NAME", "University login")
+	t.Setenv("OIDC_SCOPES", "openid email")
+	providers := ProvidersFromEnv()
+	require.Len(t, providers, 2)
+	assert.Equal(t, "github", providers[0].Name())
+	p, err := Find(providers, "oidc")
+	require.NoError(t, err)
+	assert.Equal(t, "University login", p.DisplayName())
+	assert.Equal(t, []string{"openid", "email"}, p.(*OIDC).Scopes)
+
+	_, err = Find(providers, "saml")
+	assert.Error(t, err)
+}
@@ -1,12 +1,20 @@
 package main
 
 import (
+	"io"
 	"net/http"
+	"net/http/cookiejar"
 	"net/http/httptest"
+	"net/url"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/aspcodenet/systementorlivepolls/auth"
+	"github.com/aspcodenet/systementorlivepolls/data"
 	"github.com/aspcodenet/systementorlivepolls/pages" // Assuming pages.Userkey is defined here
+	"github.com/aspcodenet/systementorlivepolls/utils"
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-contrib/sessions/cookie" // Using cookie store for simplicity in tests
 	"github.com/gin-gonic/gin"
@@ -97,3 +105,165 @@ func TestWebPageAuthRequired_UserAuthenticated(t *testing.T) {
 	// Ensure no redirect occurred
 	assert.Empty(t, w.Header().Get("Location"), "Expected no redirect for authenticated user")
 }
+
+func TestWebPageAuthRequired_ReturnsToPageAfterLogin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	setupTestDB(t)
+	saved := pages.LoginProviders
+	defer func() { pages.LoginProviders = saved }()
+	pages.Init([]auth.Provider{&auth.Dev{Email: "dev@example.com"}})
+
+	router := gin.New()
+	router.Use(sessions.Sessions("mysession", cookie.NewStore([]byte("test_secret"))))
+	router.GET("/loginv1", pages.Login)
+	router.GET("/login/:provider", pages.LoginStart)
+	router.GET("/login/oauth2/code/:provider", pages.LoginCallback)
+	reached := false
+	protected := func(c *gin.Context) { reached = true }
+	router.GET("/admin/polls/edit/:id", WebPageAuthRequired, protected)
+	router.POST("/admin/polls/delete/:id", WebPageAuthRequired, protected)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	jar, _ := cookiejar.New(nil)
+	browser := &http.Client{Jar: jar, CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	follow := func(method, path string) string {
+		req, _ := http.NewRequest(method, server.URL+path, nil)
+		req.Header.Set("X-Forwarded-Proto", "https") // As sent by a proxy terminating TLS
+		resp, err := browser.Do(req)
+		assert.NoError(t, err)
+		resp.Body.Close()
+		return resp.Header.Get("Location")
+	}
+
+	// The posted form is not returned to, the page before it is
+	assert.Equal(t, "/loginv1", follow("GET", "/admin/polls/edit/abc?tab=2"))
+	assert.Equal(t, "/loginv1", follow("POST", "/admin/polls/delete/abc"))
+	assert.False(t, reached, "the handler must not run for visitors who are not logged in")
+
+	location := follow("GET", "/loginv1")
+	location = follow("GET", location)
+	callback, err := url.Parse(location)
+	assert.NoError(t, err)
+	assert.Equal(t, "https", callback.Scheme)
+	assert.Equal(t, "/admin/polls/edit/abc?tab=2", follow("GET", callback.RequestURI()))
+}
+
+func newSignedRequest(method, path, body, accessKey, secret string, ts time.Time, nonce string) *http.Request {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	timestamp := strconv.FormatInt(ts.Unix(), 10)
+	req.Header.Set(APIKeyHeader, accessKey)
+	req.Header.Set(APITimestampHeader, timestamp)
+	req.Header.Set(APINonceHeader, nonce)
+	req.Header.Set(APISignatureHeader, utils.SignRequest(secret, method, path, timestamp, nonce, []byte(body)))
+	return req
+}
+
+func TestAPIAuthRequired_SignedRequest(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	setupTestDB(t)
+	adminUser := &data.AdminUser{Email: "api@example.com"}
+	data.DB.Create(adminUser)
+	accessKey, secret, err := data.GenerateAPIKey(adminUser, 2)
+	assert.NoError(t, err)
+
+	router := gin.New()
+	router.Use(sessions.Sessions("mysession", cookie.NewStore([]byte("test_secret"))))
+	router.POST("/api/v1/polls", APIAuthRequired, func(c *gin.Context) {
+		body, _ := io.ReadAll(c.Request.Body)
+		c.String(http.StatusOK, c.GetString(pages.Userkey)+" "+string(body))
+	})
+
+	now := time.Now()
+	cases := []struct {
+		name       string
+		req        *http.Request
+		wantStatus int
+	}{
+		{"valid", newSignedRequest("POST", "/api/v1/polls", `{"title":"x"}`, accessKey, secret, now, "n1"), http.StatusOK},
+		{"replayed nonce", newSignedRequest("POST", "/api/v1/polls", `{"title":"x"}`, accessKey, secret, now, "n1"), http.StatusUnauthorized},
+		{"wrong secret", newSignedRequest("POST", "/api/v1/polls", `{"title":"x"}`, accessKey, "wrong", now, "n2"), http.StatusUnauthorized},
+		{"unknown key", newSignedRequest("POST", "/api/v1/polls", `{"title":"x"}`, "LPKunknown", secret, now, "n3"), http.StatusUnauthorized},
+		{"expired timestamp", newSignedRequest("POST", "/api/v1/polls", `{"title":"x"}`, accessKey, secret, now.Add(-10*time.Minute), "n4"), http.StatusUnauthorized},
+	}
+	for _, tc := range cases {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, tc.req)
+		assert.Equal(t, tc.wantStatus, w.Code, tc.name)
+		if tc.wantStatus == http.StatusOK {
+			assert.Equal(t, `api@example.com {"title":"x"}`, w.Body.String(), "handler should see the user and the body")
+		}
+	}
+
+	// Tampering with the body after signing must be rejected
+	req := newSignedRequest("POST", "/api/v1/polls", `{"title":"x"}`, accessKey, secret, now, "n5")
+	req.Body = io.NopCloser(strings.NewReader(`{"title":"evil"}`))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// Bodies are only read up to maxSignedBodySize
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, newSignedRequest("POST", "/api/v1/polls", strings.Repeat("x", maxSignedBodySize+1), accessKey, secret, now, "n7"))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+
+	// A revoked key stops working
+	assert.NoError(t, data.RevokeAPIKey(adminUser, 2))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, newSignedRequest("POST", "/api/v1/polls", `{}`, accessKey, secret, now, "n6"))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestNonceCacheSweepsExpiredNonces(t *testing.T) {
+	cache := &nonceCache{seen: make(map[string]time.Time)}
+	now := time.Now()
+	assert.True(t, cache.add("a", now))
+	assert.False(t, cache.add("a", now.Add(time.Minute)), "a nonce cannot be reused while it is remembered")
+	assert.True(t, cache.add("b", now.Add(time.Minute)))
+	assert.Len(t, cache.seen, 2, "no sweep before apiSignatureMaxAge has passed")
+
+	later := now.Add(2*apiSignatureMaxAge + time.Second)
+	assert.True(t, cache.add("c", later))
+	assert.NotContains(t, cache.seen, "a", "expired nonces are swept")
+	assert.Contains(t, cache.seen, "b")
+	assert.Contains(t, cache.seen, "c")
+}
+
+func TestAPIAuthRequired_NoCredentials(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(sessions.Sessions("mysession", cookie.NewStore([]byte("test_secret"))))
+	router.GET("/api/v1/polls", APIAuthRequired, func(c *gin.Context) {
+		c.String(http.StatusOK, "should not be reached")
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/polls", nil))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), `"error"`)
+}
+
+func TestAPIAuthRequired_SessionNeedsCSRFToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(sessions.Sessions("mysession", cookie.NewStore([]byte("test_secret"))))
+	router.Use(func(c *gin.Context) {
+		sessions.Default(c).Set(pages.Userkey, "admin@example.com")
+		c.Next()
+	})
+	handler := func(c *gin.Context) { c.String(http.StatusOK, "ok") }
+	router.GET("/api/v1/polls", APIAuthRequired, handler)
+	router.DELETE("/api/v1/polls/1", APIAuthRequired, handler)
+
+	// Reading works with the session cookie alone, changing needs the CSRF token
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/polls", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/v1/polls/1", nil)
+	req.Header.Set(pages.CSRFHeader, "guessed")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), pages.CSRFHeader)
+}
@@ -0,0 +1,362 @@
+// Package charts draws the results of a question as a bar, horizontal bar or pie chart,
+// as SVG or PNG, for pasting into slides.
+//
+// Both formats are drawn from the same layout of rectangles, pie wedges and labels, so an SVG
+// and a PNG of the same question look the same. Text is measured and, for PNG, rendered with
+// the Go fonts; SVG viewers fall back to a similar sans-serif font.
+package charts
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"strconv"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/aspcodenet/systementorlivepolls/data"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+)
+
+// Kind is the type of chart.
+type Kind string
+
+const (
+	Bar           Kind = "bar"
+	HorizontalBar Kind = "hbar" // Leaves room for long option texts
+	Pie           Kind = "pie"
+)
+
+// Kinds lists the chart types.
+var Kinds = []Kind{Bar, HorizontalBar, Pie}
+
+// Theme is the color scheme of a chart.
+type Theme string
+
+const (
+	Dark  Theme = "dark"
+	Light Theme = "light"
+)
+
+// Themes lists the color schemes.
+var Themes = []Theme{Dark, Light}
+
+// Default and largest chart sizes in pixels.
+const (
+	DefaultWidth  = 800
+	DefaultHeight = 500
+	MinSize       = 200
+	MaxSize       = 2000
+)
+
+// Options control how a chart is drawn.
+type Options struct {
+	Kind        Kind  // Empty picks one with ChooseKind
+	Theme       Theme // Empty means Light
+	Percentages bool  // Label values with their share of the votes instead of counts
+	Width       int   // Zero means DefaultWidth
+	Height      int   // Zero means DefaultHeight
+}
+
+// ChooseKind picks a horizontal bar chart when option texts are too long to fit under vertical
+// bars, otherwise a bar chart.
+func ChooseKind(q *data.QuestionResult) Kind {
+	if len(q.Options) > 6 {
+		return HorizontalBar
+	}
+	for _, o := range q.Options {
+		if utf8.RuneCountInString(o.Text) > 16 {
+			return HorizontalBar
+		}
+	}
+	return Bar
+}
+
+type palette struct {
+	background color.RGBA
+	text       color.RGBA
+	muted      color.RGBA
+	grid       color.RGBA
+}
+
+var themes = map[Theme]palette{
+	Dark:  {background: rgb(0x11191f), text: rgb(0xedf0f3), muted: rgb(0x8e9ba6), grid: rgb(0x2d3a44)},
+	Light: {background: rgb(0xffffff), text: rgb(0x1b2832), muted: rgb(0x73828c), grid: rgb(0xdfe3e7)},
+}
+
+// seriesColors are used for the options in turn.
+var seriesColors = []color.RGBA{
+	rgb(0x1095c1), rgb(0xe07a5f), rgb(0x81b29a), rgb(0xf2cc8f),
+	rgb(0x9b5de5), rgb(0xf15bb5), rgb(0x00bbf9), rgb(0x6d7f8c),
+}
+
+func rgb(v uint32) color.RGBA {
+	return color.RGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 0xff}
+}
+
+// A chart is laid out as a list of shapes, drawn in order by the SVG and PNG renderers.
+type rect struct {
+	x, y, w, h float64
+	color      color.RGBA
+}
+
+// wedge is a slice of a pie. Angles are in radians, clockwise from twelve o'clock.
+type wedge struct {
+	cx, cy, r  float64
+	start, end float64
+	color      color.RGBA
+}
+
+type anchor int
+
+const (
+	anchorStart anchor = iota
+	anchorMiddle
+	anchorEnd
+)
+
+// label is a line of text; y is the baseline.
+type label struct {
+	x, y   float64
+	text   string
+	size   float64
+	bold   bool
+	anchor anchor
+	color  color.RGBA
+}
+
+type chart struct {
+	width, height int
+	background    color.RGBA
+	shapes        []interface{}
+}
+
+var (
+	fontsOnce    sync.Once
+	regularFont  *opentype.Font
+	boldFont     *opentype.Font
+	fontsErr     error
+	facesMu      sync.Mutex
+	measureFaces = map[faceKey]font.Face{}
+)
+
+type faceKey struct {
+	size float64
+	bold bool
+}
+
+func loadFonts() error {
+	fontsOnce.Do(func() {
+		regularFont, fontsErr = opentype.Parse(goregular.TTF)
+		if fontsErr == nil {
+			boldFont, fontsErr = opentype.Parse(gobold.TTF)
+		}
+	})
+	return fontsErr
+}
+
+// newFace returns a font face for text of the given pixel size.
+func newFace(size float64, bold bool) (font.Face, error) {
+	if err := loadFonts(); err != nil {
+		return nil, err
+	}
+	f := regularFont
+	if bold {
+		f = boldFont
+	}
+	return opentype.NewFace(f, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingNone})
+}
+
+// textWidth measures text in pixels with the font the PNG renderer uses.
+func textWidth(text string, size float64, bold bool) float64 {
+	facesMu.Lock()
+	defer facesMu.Unlock()
+	key := faceKey{size, bold}
+	face, ok := measureFaces[key]
+	if !ok {
+		var err error
+		if face, err = newFace(size, bold); err != nil {
+			return float64(utf8.RuneCountInString(text)) * size * 0.6 // Rough guess without the font
+		}
+		measureFaces[key] = face
+	}
+	return float64(font.MeasureString(face, text)) / 64
+}
+
+// fit shortens text with an ellipsis until it is at most maxWidth pixels wide.
+func fit(text string, size float64, bold bool, maxWidth float64) string {
+	if textWidth(text, size, bold) <= maxWidth {
+		return text
+	}
+	runes := []rune(text)
+	for len(runes) > 0 {
+		runes = runes[:len(runes)-1]
+		shortened := string(runes) + "…"
+		if textWidth(shortened, size, bold) <= maxWidth {
+			return shortened
+		}
+	}
+	return ""
+}
+
+func formatValue(o data.OptionResult, percentages bool) string {
+	if percentages {
+		return strconv.FormatFloat(math.Round(o.Percentage), 'f', 0, 64) + "%"
+	}
+	return strconv.Itoa(o.Count)
+}
+
+func (o Options) withDefaults(q *data.QuestionResult) Options {
+	if o.Kind == "" {
+		o.Kind = ChooseKind(q)
+	}
+	if _, ok := themes[o.Theme]; !ok {
+		o.Theme = Light
+	}
+	if o.Width == 0 {
+		o.Width = DefaultWidth
+	}
+	if o.Height == 0 {
+		o.Height = DefaultHeight
+	}
+	o.Width = min(max(o.Width, MinSize), MaxSize)
+	o.Height = min(max(o.Height, MinSize), MaxSize)
+	return o
+}
+
+const (
+	padding    = 24.0
+	titleSize  = 20.0
+	textSize   = 14.0
+	smallSize  = 13.0
+	lineHeight = 22.0
+)
+
+// layout places the title, a summary line and the chart itself.
+func layout(q *data.QuestionResult, opts Options) (*chart, error) {
+	opts = opts.withDefaults(q)
+	p := themes[opts.Theme]
+	c := &chart{width: opts.Width, height: opts.Height, background: p.background}
+	w, h := float64(opts.Width), float64(opts.Height)
+
+	titleY := padding + titleSize
+	c.add(label{x: padding, y: titleY, text: fit(q.Text, titleSize, true, w-2*padding), size: titleSize, bold: true, color: p.text})
+	summary := fmt.Sprintf("%d votes", q.TotalVotes)
+	if q.TotalVotes == 1 {
+		summary = "1 vote"
+	}
+	if q.Respondents != q.TotalVotes {
+		summary += fmt.Sprintf(", %d respondents", q.Respondents)
+	}
+	summaryY := titleY + lineHeight
+	c.add(label{x: padding, y: summaryY, text: summary, size: smallSize, color: p.muted})
+
+	area := area{left: padding, top: summaryY + lineHeight, right: w - padding, bottom: h - padding}
+	if len(q.Options) == 0 {
+		c.add(label{x: w / 2, y: area.top + (area.bottom-area.top)/2, text: "No options", size: textSize, anchor: anchorMiddle, color: p.muted})
+		return c, nil
+	}
+	switch opts.Kind {
+	case Bar:
+		c.layoutBars(q, opts, p, area)
+	case HorizontalBar:
+		c.layoutHorizontalBars(q, opts, p, area)
+	case Pie:
+		c.layoutPie(q, opts, p, area)
+	default:
+		return nil, fmt.Errorf("unknown chart type %q", opts.Kind)
+	}
+	return c, nil
+}
+
+// area is the part of the chart left for the bars or pie.
+type area struct {
+	left, top, right, bottom float64
+}
+
+func (c *chart) add(shape interface{}) {
+	c.shapes = append(c.shapes, shape)
+}
+
+func maxCount(q *data.QuestionResult) float64 {
+	highest := 1
+	for _, o := range q.Options {
+		highest = max(highest, o.Count)
+	}
+	return float64(highest)
+}
+
+func (c *chart) layoutBars(q *data.QuestionResult, opts Options, p palette, a area) {
+	plotTop := a.top + lineHeight // Room for the value above the highest bar
+	plotBottom := a.bottom - lineHeight - 8
+	plotHeight := plotBottom - plotTop
+	slot := (a.right - a.left) / float64(len(q.Options))
+	barWidth := min(slot*0.6, 120)
+	highest := maxCount(q)
+
+	c.add(rect{x: a.left, y: plotBottom, w: a.right - a.left, h: 1, color: p.grid})
+	for i, o := range q.Options {
+		center := a.left + slot*float64(i) + slot/2
+		barHeight := plotHeight * float64(o.Count) / highest
+		c.add(rect{x: center - barWidth/2, y: plotBottom - barHeight, w: barWidth, h: barHeight, color: seriesColors[i%len(seriesColors)]})
+		c.add(label{x: center, y: plotBottom - barHeight - 6, text: formatValue(o, opts.Percentages), size: smallSize, bold: true, anchor: anchorMiddle, color: p.text})
+		c.add(label{x: center, y: plotBottom + lineHeight, text: fit(o.Text, smallSize, false, slot-8), size: smallSize, anchor: anchorMiddle, color: p.text})
+	}
+}
+
+func (c *chart) layoutHorizontalBars(q *data.QuestionResult, opts Options, p palette, a area) {
+	rowHeight := min(48, (a.bottom-a.top)/float64(len(q.Options)))
+	barHeight := rowHeight * 0.6
+	labelWidth := (a.right - a.left) * 0.35
+	barLeft := a.left + labelWidth
+	valueWidth := textWidth("100%", smallSize, true) + 12
+	maxBarWidth := a.right - barLeft - valueWidth
+	highest := maxCount(q)
+
+	c.add(rect{x: barLeft, y: a.top, w: 1, h: rowHeight * float64(len(q.Options)), color: p.grid})
+	for i, o := range q.Options {
+		middle := a.top + rowHeight*float64(i) + rowHeight/2
+		barWidth := maxBarWidth * float64(o.Count) / highest
+		c.add(label{x: barLeft - 12, y: middle + textSize/3, text: fit(o.Text, textSize, false, labelWidth-12), size: textSize, anchor: anchorEnd, color: p.text})
+		c.add(rect{x: barLeft, y: middle - barHeight/2, w: barWidth, h: barHeight, color: seriesColors[i%len(seriesColors)]})
+		c.add(label{x: barLeft + barWidth + 8, y: middle + smallSize/3, text: formatValue(o, opts.Percentages), size: smallSize, bold: true, color: p.text})
+	}
+}
+
+func (c *chart) layoutPie(q *data.QuestionResult, opts Options, p palette, a area) {
+	diameter := min(a.bottom-a.top, (a.right-a.left)*0.5)
+	r := diameter / 2
+	cx, cy := a.left+r, a.top+(a.bottom-a.top)/2
+
+	if q.TotalVotes == 0 {
+		c.add(wedge{cx: cx, cy: cy, r: r, start: 0, end: 2 * math.Pi, color: p.grid})
+		c.add(label{x: cx, y: cy + textSize/3, text: "No votes", size: textSize, anchor: anchorMiddle, color: p.muted})
+	} else {
+		angle := 0.0
+		for i, o := range q.Options {
+			if o.Count == 0 {
+				continue
+			}
+			sweep := 2 * math.Pi * float64(o.Count) / float64(q.TotalVotes)
+			c.add(wedge{cx: cx, cy: cy, r: r, start: angle, end: angle + sweep, color: seriesColors[i%len(seriesColors)]})
+			angle += sweep
+		}
+	}
+
+	// Legend
+	legendLeft := a.left + diameter + 32
+	rowHeight := min(28, (a.bottom-a.top)/float64(len(q.Options)))
+	top := cy - rowHeight*float64(len(q.Options))/2
+	for i, o := range q.Options {
+		middle := top + rowHeight*float64(i) + rowHeight/2
+		c.add(rect{x: legendLeft, y: middle - 7, w: 14, h: 14, color: seriesColors[i%len(seriesColors)]})
+		value := formatValue(o, opts.Percentages)
+		valueX := a.right
+		c.add(label{x: valueX, y: middle + textSize/3, text: value, size: textSize, bold: true, anchor: anchorEnd, color: p.text})
+		textMax := valueX - textWidth(value, textSize, true) - 12 - (legendLeft + 22)
+		c.add(label{x: legendLeft + 22, y: middle + textSize/3, text: fit(o.Text, textSize, false, textMax), size: textSize, color: p.text})
+	}
+}
@@ -0,0 +1,100 @@
+package charts
+
+import (
+	"bytes"
+	"encoding/xml"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/aspcodenet/systementorlivepolls/data"
+	"github.com/stretchr/testify/assert"
+)
+
+func sampleResult() *data.QuestionResult {
+	return &data.QuestionResult{
+		Text:        "Best <color>?",
+		Type:        "single-select",
+		TotalVotes:  4,
+		Respondents: 4,
+		Options: []data.OptionResult{
+			{OptionID: 1, Text: "Red", Count: 3, Percentage: 75},
+			{OptionID: 2, Text: "Blue", Count: 1, Percentage: 25},
+			{OptionID: 3, Text: "Green"},
+		},
+	}
+}
+
+func TestChooseKind(t *testing.T) {
+	q := sampleResult()
+	assert.Equal(t, Bar, ChooseKind(q))
+	q.Options[1].Text = "A much longer option text"
+	assert.Equal(t, HorizontalBar, ChooseKind(q))
+}
+
+func TestSVG(t *testing.T) {
+	for _, kind := range Kinds {
+		var buf bytes.Buffer
+		assert.NoError(t, SVG(&buf, sampleResult(), Options{Kind: kind, Theme: Dark}), kind)
+		svg := buf.String()
+
+		var doc struct{ XMLName xml.Name }
+		assert.NoError(t, xml.Unmarshal(buf.Bytes(), &doc), "%s should be well formed", kind)
+		assert.Equal(t, "svg", doc.XMLName.Local)
+		assert.Contains(t, svg, `width="800" height="500"`)
+		assert.Contains(t, svg, "Best &lt;color&gt;?")
+		assert.Contains(t, svg, ">Red<")
+		assert.Contains(t, svg, ">3<", "%s should label counts", kind)
+		assert.Contains(t, svg, `fill="#11191f"`, "dark background")
+	}
+}
+
+func TestSVGPercentages(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, SVG(&buf, sampleResult(), Options{Kind: Bar, Percentages: true}))
+	assert.Contains(t, buf.String(), ">75%<")
+	assert.Contains(t, buf.String(), ">0%<")
+	assert.Contains(t, buf.String(), `fill="#ffffff"`, "light background by default")
+}
+
+func TestPieWithoutVotes(t *testing.T) {
+	q := sampleResult()
+	q.TotalVotes, q.Respondents = 0, 0
+	for i := range q.Options {
+		q.Options[i].Count, q.Options[i].Percentage = 0, 0
+	}
+	var buf bytes.Buffer
+	assert.NoError(t, SVG(&buf, q, Options{Kind: Pie}))
+	assert.Contains(t, buf.String(), "<circle")
+	assert.Contains(t, buf.String(), "No votes")
+}
+
+func TestPNG(t *testing.T) {
+	for _, kind := range Kinds {
+		var buf bytes.Buffer
+		assert.NoError(t, PNG(&buf, sampleResult(), Options{Kind: kind, Width: 400, Height: 300}), kind)
+		img, err := png.Decode(&buf)
+		if assert.NoError(t, err, kind) {
+			assert.Equal(t, 400, img.Bounds().Dx())
+			assert.Equal(t, 300, img.Bounds().Dy())
+		}
+	}
+}
+
+func TestSizeIsClamped(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, SVG(&buf, sampleResult(), Options{Width: 10, Height: 99999}))
+	assert.True(t, strings.HasPrefix(buf.String(), `<svg xmlns="http://www.w3.org/2000/svg" width="200" height="2000"`), buf.String()[:100])
+}
+
+func TestUnknownKind(t *testing.T) {
+	var buf bytes.Buffer
+	assert.Error(t, SVG(&buf, sampleResult(), Options{Kind: "donut"}))
+}
+
+func TestFit(t *testing.T) {
+	assert.Equal(t, "Red", fit("Red", 14, false, 100))
+	short := fit("A very long option text that does not fit", 14, false, 100)
+	assert.True(t, strings.HasSuffix(short, "…"))
+	assert.LessOrEqual(t, textWidth(short, 14, false), 100.0)
+}
@@ -0,0 +1,119 @@
+package charts
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"math"
+
+	"github.com/aspcodenet/systementorlivepolls/data"
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
+)
+
+// supersampleLimit is the largest image, in pixels, that is drawn at twice the size and scaled
+// down to smooth the edges of bars and wedges.
+const supersampleLimit = 1_000_000
+
+// PNG draws the results of a question as a PNG image.
+func PNG(w io.Writer, q *data.QuestionResult, opts Options) error {
+	c, err := layout(q, opts)
+	if err != nil {
+		return err
+	}
+	scale := 1
+	if c.width*c.height <= supersampleLimit {
+		scale = 2
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, c.width*scale, c.height*scale))
+	draw.Draw(img, img.Bounds(), image.NewUniform(c.background), image.Point{}, draw.Src)
+	s := float64(scale)
+	for _, shape := range c.shapes {
+		switch sh := shape.(type) {
+		case rect:
+			r := image.Rect(round(sh.x*s), round(sh.y*s), round((sh.x+sh.w)*s), round((sh.y+sh.h)*s))
+			draw.Draw(img, r, image.NewUniform(sh.color), image.Point{}, draw.Over)
+		case wedge:
+			fillWedge(img, sh, s)
+		case label:
+			if err := drawLabel(img, sh, s); err != nil {
+				return err
+			}
+		}
+	}
+	if scale > 1 {
+		img = downsample(img, scale)
+	}
+	return png.Encode(w, img)
+}
+
+func round(v float64) int {
+	return int(math.Round(v))
+}
+
+// fillWedge colors the pixels whose centers lie inside the wedge.
+func fillWedge(img *image.RGBA, w wedge, scale float64) {
+	cx, cy, r := w.cx*scale, w.cy*scale, w.r*scale
+	full := w.end-w.start >= 2*math.Pi-1e-9
+	bounds := image.Rect(int(cx-r), int(cy-r), int(cx+r)+1, int(cy+r)+1).Intersect(img.Bounds())
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			dx, dy := float64(x)+0.5-cx, float64(y)+0.5-cy
+			if dx*dx+dy*dy > r*r {
+				continue
+			}
+			if !full {
+				angle := math.Atan2(dx, -dy) // Clockwise from twelve o'clock
+				if angle < 0 {
+					angle += 2 * math.Pi
+				}
+				if angle < w.start || angle >= w.end {
+					continue
+				}
+			}
+			img.SetRGBA(x, y, w.color)
+		}
+	}
+}
+
+func drawLabel(img *image.RGBA, l label, scale float64) error {
+	face, err := newFace(l.size*scale, l.bold)
+	if err != nil {
+		return err
+	}
+	defer face.Close()
+	d := &font.Drawer{Dst: img, Src: image.NewUniform(l.color), Face: face}
+	x := l.x * scale
+	switch l.anchor {
+	case anchorMiddle:
+		x -= float64(d.MeasureString(l.text)) / 64 / 2
+	case anchorEnd:
+		x -= float64(d.MeasureString(l.text)) / 64
+	}
+	d.Dot = fixed.Point26_6{X: fixed.Int26_6(x * 64), Y: fixed.Int26_6(l.y * scale * 64)}
+	d.DrawString(l.text)
+	return nil
+}
+
+// downsample shrinks an image by an integer factor, averaging each block of pixels.
+func downsample(src *image.RGBA, factor int) *image.RGBA {
+	b := src.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dx()/factor, b.Dy()/factor))
+	n := uint32(factor * factor)
+	for y := 0; y < dst.Rect.Dy(); y++ {
+		for x := 0; x < dst.Rect.Dx(); x++ {
+			var r, g, bl, a uint32
+			for sy := 0; sy < factor; sy++ {
+				for sx := 0; sx < factor; sx++ {
+					c := src.RGBAAt(x*factor+sx, y*factor+sy)
+					r, g, bl, a = r+uint32(c.R), g+uint32(c.G), bl+uint32(c.B), a+uint32(c.A)
+				}
+			}
+			dst.SetRGBA(x, y, color.RGBA{R: uint8(r / n), G: uint8(g / n), B: uint8(bl / n), A: uint8(a / n)})
+		}
+	}
+	return dst
+}
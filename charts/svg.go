@@ -0,0 +1,76 @@
+package charts
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"image/color"
+	"io"
+	"math"
+
+	"github.com/aspcodenet/systementorlivepolls/data"
+)
+
+// SVG draws the results of a question as an SVG image.
+func SVG(w io.Writer, q *data.QuestionResult, opts Options) error {
+	c, err := layout(q, opts)
+	if err != nil {
+		return err
+	}
+	b := bufio.NewWriter(w)
+	fmt.Fprintf(b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="Go, 'Helvetica Neue', Arial, sans-serif">`+"\n",
+		c.width, c.height, c.width, c.height)
+	fmt.Fprintf(b, `<rect width="100%%" height="100%%" fill="%s"/>`+"\n", hex(c.background))
+	for _, shape := range c.shapes {
+		switch s := shape.(type) {
+		case rect:
+			fmt.Fprintf(b, `<rect x="%s" y="%s" width="%s" height="%s" fill="%s"/>`+"\n", num(s.x), num(s.y), num(s.w), num(s.h), hex(s.color))
+		case wedge:
+			writeWedge(b, s)
+		case label:
+			writeLabel(b, s)
+		}
+	}
+	b.WriteString("</svg>\n")
+	return b.Flush()
+}
+
+func writeWedge(b *bufio.Writer, s wedge) {
+	if s.end-s.start >= 2*math.Pi-1e-9 {
+		fmt.Fprintf(b, `<circle cx="%s" cy="%s" r="%s" fill="%s"/>`+"\n", num(s.cx), num(s.cy), num(s.r), hex(s.color))
+		return
+	}
+	x1, y1 := pointOnCircle(s.cx, s.cy, s.r, s.start)
+	x2, y2 := pointOnCircle(s.cx, s.cy, s.r, s.end)
+	largeArc := 0
+	if s.end-s.start > math.Pi {
+		largeArc = 1
+	}
+	fmt.Fprintf(b, `<path d="M%s %s L%s %s A%s %s 0 %d 1 %s %s Z" fill="%s"/>`+"\n",
+		num(s.cx), num(s.cy), num(x1), num(y1), num(s.r), num(s.r), largeArc, num(x2), num(y2), hex(s.color))
+}
+
+func writeLabel(b *bufio.Writer, s label) {
+	anchors := map[anchor]string{anchorStart: "start", anchorMiddle: "middle", anchorEnd: "end"}
+	weight := "normal"
+	if s.bold {
+		weight = "bold"
+	}
+	fmt.Fprintf(b, `<text x="%s" y="%s" font-size="%s" font-weight="%s" text-anchor="%s" fill="%s">`,
+		num(s.x), num(s.y), num(s.size), weight, anchors[s.anchor], hex(s.color))
+	xml.EscapeText(b, []byte(s.text))
+	b.WriteString("</text>\n")
+}
+
+// pointOnCircle returns the point at angle, clockwise from twelve o'clock.
+func pointOnCircle(cx, cy, r, angle float64) (float64, float64) {
+	return cx + r*math.Sin(angle), cy - r*math.Cos(angle)
+}
+
+func num(v float64) string {
+	return fmt.Sprintf("%.1f", v)
+}
+
+func hex(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
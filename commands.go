@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/aspcodenet/systementorlivepolls/data"
+)
+
+// commandUsage lists the maintenance commands, which are run instead of the server when given
+// on the command line, like "livepolls create-owner teacher@school.example".
+const commandUsage = `Usage: livepolls [command]
+
+Without a command the server is started. Commands:
+
+  create-owner <email>  Make email an owner, who can give others access on /admin/users.
+                        The user does not need to have logged in yet.
+`
+
+// runCommand runs a maintenance command and returns the exit code.
+func runCommand(args []string, out io.Writer) int {
+	switch args[0] {
+	case "create-owner":
+		if len(args) != 2 {
+			fmt.Fprint(out, commandUsage)
+			return 2
+		}
+		owner, err := data.BootstrapOwner(args[1])
+		if err != nil {
+			fmt.Fprintf(out, "Could not create owner: %v\n", err)
+			return 1
+		}
+		fmt.Fprintf(out, "%s is now an owner and can manage administrators on /admin/users\n", owner.Email)
+		return 0
+	default:
+		fmt.Fprint(out, commandUsage)
+		return 2
+	}
+}
+
+// migrateAdmins moves the emails of the ADMINS setting, which was how admins used to be
+// configured, into the database on the first start, and tells how to create the first owner.
+func migrateAdmins() {
+	if n, err := data.MigrateAdminsFromEnv(os.Getenv("ADMINS")); err != nil {
+		log.Printf("Error migrating ADMINS to the database: %v", err)
+	} else if n > 0 {
+		log.Printf("Gave %d users from ADMINS the admin role - admins are now managed on /admin/users and ADMINS can be removed", n)
+	}
+	if hasOwner, err := data.HasOwner(); err == nil && !hasOwner {
+		log.Println("There is no owner to manage administrators yet - run with: create-owner <email>")
+	}
+}
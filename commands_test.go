@@ -0,0 +1,34 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/aspcodenet/systementorlivepolls/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunCommandCreateOwner(t *testing.T) {
+	setupTestDB(t)
+	var out bytes.Buffer
+
+	assert.Equal(t, 0, runCommand([]string{"create-owner", "teacher@school.example"}, &out))
+	assert.Contains(t, out.String(), "teacher@school.example is now an owner")
+	var owner data.AdminUser
+	require.NoError(t, data.DB.First(&owner, "email=?", "teacher@school.example").Error)
+	assert.True(t, owner.IsOwner())
+
+	out.Reset()
+	assert.Equal(t, 1, runCommand([]string{"create-owner", "teacher"}, &out))
+	assert.Contains(t, out.String(), "Could not create owner")
+}
+
+func TestRunCommandUsage(t *testing.T) {
+	var out bytes.Buffer
+	assert.Equal(t, 2, runCommand([]string{"create-owner"}, &out))
+	assert.Contains(t, out.String(), "Usage:")
+	out.Reset()
+	assert.Equal(t, 2, runCommand([]string{"serve"}, &out))
+	assert.Contains(t, out.String(), "create-owner <email>")
+}
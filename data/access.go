@@ -0,0 +1,91 @@
+package data
+
+import (
+	"slices"
+
+	"gorm.io/gorm"
+)
+
+// What an admin may do with a poll, each including the ones before it. Polls are shared with a
+// workspace member or a single admin at one of these levels.
+const (
+	AccessRun   = "run"   // Open the control panel, run the poll and see and export its results
+	AccessEdit  = "edit"  // Also change and copy the poll
+	AccessOwner = "owner" // Also delete, share, move and transfer the poll
+)
+
+// AccessLevels are all access levels, from the least to the most.
+var AccessLevels = []string{AccessRun, AccessEdit, AccessOwner}
+
+func ValidAccess(access string) bool {
+	return slices.Contains(AccessLevels, access)
+}
+
+// HasAccess reports whether access includes need.
+func HasAccess(access, need string) bool {
+	return ValidAccess(need) && slices.Index(AccessLevels, access) >= slices.Index(AccessLevels, need)
+}
+
+// maxAccess returns the greater of two access levels, "" for none.
+func maxAccess(a, b string) string {
+	if slices.Index(AccessLevels, a) >= slices.Index(AccessLevels, b) {
+		return a
+	}
+	return b
+}
+
+// PollAccess returns what user may do with a poll: the owner of a personal poll may do
+// everything, members of its workspace and admins it is shared with what they were given.
+// "" means no access.
+func PollAccess(p *Poll, user *AdminUser) string {
+	if p.WorkspaceID == nil && p.AdminUserID == int(user.ID) {
+		return AccessOwner
+	}
+	access := ""
+	if p.WorkspaceID != nil {
+		access = WorkspaceAccess(*p.WorkspaceID, user.ID)
+	}
+	var share PollShare
+	if err := DB.First(&share, "poll_id = ? AND admin_user_id = ?", p.ID, user.ID).Error; err == nil {
+		access = maxAccess(access, share.Access)
+	}
+	return access
+}
+
+// CanAccessPoll reports whether user may do need with a poll. A nil poll, e.g. one that was not
+// found, cannot be accessed.
+func CanAccessPoll(p *Poll, user *AdminUser, need string) bool {
+	return p != nil && user != nil && HasAccess(PollAccess(p, user), need)
+}
+
+// AccessiblePolls returns a query for the polls user may at least run: their personal polls,
+// the polls of their workspaces and the polls shared with them.
+func AccessiblePolls(user *AdminUser) *gorm.DB {
+	return DB.Model(&Poll{}).Where("(admin_user_id = ? AND workspace_id IS NULL) OR workspace_id IN (?) OR id IN (?)", user.ID,
+		DB.Model(&WorkspaceMember{}).Select("workspace_id").Where("admin_user_id = ?", user.ID),
+		DB.Model(&PollShare{}).Select("poll_id").Where("admin_user_id = ?", user.ID))
+}
+
+// GetAccessiblePolls returns the polls user may do need with, oldest first.
+func GetAccessiblePolls(user *AdminUser, need string) ([]*Poll, error) {
+	polls := []*Poll{}
+	err := AccessiblePolls(user).Order("id").Find(&polls).Error
+	if err != nil {
+		return nil, err
+	}
+	if need == AccessRun {
+		return polls, nil
+	}
+	return slices.DeleteFunc(polls, func(p *Poll) bool { return !CanAccessPoll(p, user, need) }), nil
+}
+
+// CopyPollFor copies a poll for user, who owns the copy. The copy stays in the workspace of the
+// poll only if user may add polls there, otherwise it is a personal poll.
+func CopyPollFor(p *Poll, user *AdminUser) *Poll {
+	pollCopy := p.DeepCopyWithoutID()
+	pollCopy.AdminUserID = int(user.ID)
+	if pollCopy.WorkspaceID != nil && !HasAccess(WorkspaceAccess(*pollCopy.WorkspaceID, user.ID), AccessEdit) {
+		pollCopy.WorkspaceID = nil
+	}
+	return pollCopy
+}
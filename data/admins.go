@@ -0,0 +1,133 @@
+package data
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Roles of an AdminUser. Owners decide who may use the admin pages, admins create and run polls
+// and viewers may look at the admin pages but not change anything.
+const (
+	RoleOwner  = "owner"
+	RoleAdmin  = "admin"
+	RoleViewer = "viewer"
+)
+
+// Roles are all roles, from the most to the least access.
+var Roles = []string{RoleOwner, RoleAdmin, RoleViewer}
+
+// ErrLastOwner is returned when a change would leave no active owner to manage the admins.
+var ErrLastOwner = errors.New("there must be at least one active owner")
+
+// ErrNoSuchAdmin is returned by GetAdminUserByEmail when nobody has logged in with the email address.
+var ErrNoSuchAdmin = errors.New("nobody with that email address has logged in yet")
+
+func ValidRole(role string) bool {
+	return slices.Contains(Roles, role)
+}
+
+// IsAdmin reports whether the user may use the admin pages: the account is active and has a role.
+func (u *AdminUser) IsAdmin() bool {
+	return u.Active && ValidRole(u.Role)
+}
+
+// CanEdit reports whether the user may create and change polls, i.e. is an admin but not a viewer.
+func (u *AdminUser) CanEdit() bool {
+	return u.IsAdmin() && u.Role != RoleViewer
+}
+
+// IsOwner reports whether the user may manage the admins.
+func (u *AdminUser) IsOwner() bool {
+	return u.IsAdmin() && u.Role == RoleOwner
+}
+
+// GetAdminUsers returns all users, whether they have a role or not, by email.
+func GetAdminUsers() ([]AdminUser, error) {
+	var users []AdminUser
+	err := DB.Order("email").Find(&users).Error
+	return users, err
+}
+
+// GetAdminUserByEmail returns the user who logged in with an email address, ErrNoSuchAdmin if nobody did.
+func GetAdminUserByEmail(email string) (*AdminUser, error) {
+	var user AdminUser
+	err := DB.First(&user, "email = ?", strings.TrimSpace(email)).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNoSuchAdmin
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// SetAdminRole changes the role and active flag of a user. An empty role takes the access away.
+// The last active owner cannot be demoted or deactivated, so someone can always manage the admins.
+func SetAdminRole(user *AdminUser, role string, active bool) error {
+	if role != "" && !ValidRole(role) {
+		return fmt.Errorf("invalid role %q", role)
+	}
+	if user.IsOwner() && (role != RoleOwner || !active) {
+		var owners int64
+		if err := DB.Model(&AdminUser{}).Where("role = ? AND active = ? AND id <> ?", RoleOwner, true, user.ID).Count(&owners).Error; err != nil {
+			return err
+		}
+		if owners == 0 {
+			return ErrLastOwner
+		}
+	}
+	user.Role, user.Active = role, active
+	return DB.Model(user).Select("Role", "Active").Updates(user).Error
+}
+
+// BootstrapOwner makes email an active owner, creating the user if they have not logged in yet.
+// It is how the first owner is created, and how access is regained if every owner is locked out.
+func BootstrapOwner(email string) (*AdminUser, error) {
+	email = strings.TrimSpace(email)
+	if !strings.Contains(email, "@") {
+		return nil, fmt.Errorf("%q is not an email address", email)
+	}
+	user := &AdminUser{}
+	if err := DB.Where(AdminUser{Email: email}).FirstOrCreate(user).Error; err != nil {
+		return nil, err
+	}
+	return user, SetAdminRole(user, RoleOwner, true)
+}
+
+// HasOwner reports whether there is an active owner.
+func HasOwner() (bool, error) {
+	var owners int64
+	err := DB.Model(&AdminUser{}).Where("role = ? AND active = ?", RoleOwner, true).Count(&owners).Error
+	return owners > 0, err
+}
+
+// MigrateAdminsFromEnv gives the admin role to the comma separated emails of the old ADMINS
+// setting. It only does so while no user has a role, so later changes on the admin page stick.
+// It returns how many users were given the role.
+func MigrateAdminsFromEnv(adminsCSV string) (int, error) {
+	var withRole int64
+	if err := DB.Model(&AdminUser{}).Where("role <> ''").Count(&withRole).Error; err != nil || withRole > 0 {
+		return 0, err
+	}
+	migrated := 0
+	for _, email := range strings.Split(adminsCSV, ",") {
+		email = strings.TrimSpace(email)
+		if email == "" {
+			continue
+		}
+		// The setting was compared case-insensitively, so match the stored email the same way
+		user := &AdminUser{}
+		if err := DB.Where("LOWER(email) = LOWER(?)", email).Attrs(AdminUser{Email: email}).FirstOrCreate(user).Error; err != nil {
+			return migrated, err
+		}
+		if err := SetAdminRole(user, RoleAdmin, true); err != nil {
+			return migrated, err
+		}
+		migrated++
+	}
+	return migrated, nil
+}
@@ -0,0 +1,113 @@
+package data
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestAdminUserAccess(t *testing.T) {
+	cases := []struct {
+		role                      string
+		active                    bool
+		isAdmin, canEdit, isOwner bool
+	}{
+		{RoleOwner, true, true, true, true},
+		{RoleAdmin, true, true, true, false},
+		{RoleViewer, true, true, false, false},
+		{"", true, false, false, false},
+		{RoleOwner, false, false, false, false},
+		{"superuser", true, false, false, false},
+	}
+	for _, tc := range cases {
+		u := &AdminUser{Role: tc.role, Active: tc.active}
+		if u.IsAdmin() != tc.isAdmin || u.CanEdit() != tc.canEdit || u.IsOwner() != tc.isOwner {
+			t.Errorf("role %q active %v: IsAdmin %v CanEdit %v IsOwner %v", tc.role, tc.active, u.IsAdmin(), u.CanEdit(), u.IsOwner())
+		}
+	}
+}
+
+func TestSetAdminRoleKeepsAnOwner(t *testing.T) {
+	setupTestDB(t)
+	owner, err := BootstrapOwner("owner@example.com")
+	if err != nil || !owner.IsOwner() {
+		t.Fatalf("BootstrapOwner = %+v, %v", owner, err)
+	}
+	if err := SetAdminRole(owner, RoleAdmin, true); !errors.Is(err, ErrLastOwner) {
+		t.Errorf("demoting the last owner: expected ErrLastOwner, got %v", err)
+	}
+	if err := SetAdminRole(owner, RoleOwner, false); !errors.Is(err, ErrLastOwner) {
+		t.Errorf("deactivating the last owner: expected ErrLastOwner, got %v", err)
+	}
+	if err := SetAdminRole(owner, "superuser", true); err == nil {
+		t.Error("an unknown role should be refused")
+	}
+
+	second := &AdminUser{Email: "second@example.com"}
+	DB.Create(second)
+	if err := SetAdminRole(second, RoleOwner, true); err != nil {
+		t.Fatalf("SetAdminRole returned error: %v", err)
+	}
+	if err := SetAdminRole(owner, "", true); err != nil {
+		t.Fatalf("with another owner the first can be demoted, got %v", err)
+	}
+	var stored AdminUser
+	DB.First(&stored, owner.ID)
+	if stored.Role != "" || stored.IsAdmin() {
+		t.Errorf("expected the role to be removed, got %+v", stored)
+	}
+	if hasOwner, _ := HasOwner(); !hasOwner {
+		t.Error("expected an owner")
+	}
+}
+
+func TestBootstrapOwner(t *testing.T) {
+	setupTestDB(t)
+	if hasOwner, _ := HasOwner(); hasOwner {
+		t.Fatal("a new database should have no owner")
+	}
+	existing := &AdminUser{Email: "teacher@example.com"}
+	DB.Create(existing)
+
+	owner, err := BootstrapOwner(" teacher@example.com ")
+	if err != nil || owner.ID != existing.ID || !owner.IsOwner() {
+		t.Fatalf("expected the existing user to become owner, got %+v (err %v)", owner, err)
+	}
+	if _, err := BootstrapOwner("not-an-email"); err == nil {
+		t.Error("expected an error for a name without @")
+	}
+}
+
+func TestMigrateAdminsFromEnv(t *testing.T) {
+	setupTestDB(t)
+	existing := &AdminUser{Email: "a@example.com"}
+	DB.Create(existing)
+
+	n, err := MigrateAdminsFromEnv("a@example.com, b@example.com,")
+	if err != nil || n != 2 {
+		t.Fatalf("MigrateAdminsFromEnv = %d, %v", n, err)
+	}
+	users, _ := GetAdminUsers()
+	if len(users) != 2 || users[0].Email != "a@example.com" || !users[0].CanEdit() || users[0].IsOwner() || !users[1].CanEdit() {
+		t.Fatalf("expected two admins, got %+v", users)
+	}
+
+	// Once roles are managed in the database the setting is ignored
+	SetAdminRole(&users[1], "", true)
+	if n, err := MigrateAdminsFromEnv("b@example.com,c@example.com"); err != nil || n != 0 {
+		t.Errorf("a second migration should do nothing, got %d, %v", n, err)
+	}
+}
+
+func TestMigrateAdminsFromEnvIgnoresCase(t *testing.T) {
+	setupTestDB(t)
+	DB.Create(&AdminUser{Email: "Anna@Example.com"})
+
+	n, err := MigrateAdminsFromEnv("anna@example.com")
+	if err != nil || n != 1 {
+		t.Fatalf("MigrateAdminsFromEnv = %d, %v", n, err)
+	}
+	users, _ := GetAdminUsers()
+	if len(users) != 1 || users[0].Email != "Anna@Example.com" || !users[0].CanEdit() {
+		t.Fatalf("expected the existing user to become admin, got %+v", users)
+	}
+}
@@ -27,11 +27,17 @@ func InitDb(dbConfig *DbConfig) {
 	if err != nil {
 		panic(err.Error())
 	}
-	DB.AutoMigrate(&AdminUser{}, &Poll{}, &Question{}, &Vote{}, &Option{})
+	Migrate(DB)
 
 	seedData(DB)
 }
 
+// Migrate creates or updates the tables of all models. InitDb calls it, and the tests call it on
+// their in-memory databases.
+func Migrate(db *gorm.DB) error {
+	return db.AutoMigrate(&AdminUser{}, &Poll{}, &Question{}, &Vote{}, &Option{}, &PollRun{}, &VoteSubmission{}, &Webhook{}, &WebhookDelivery{}, &AdminInvite{}, &Workspace{}, &WorkspaceMember{}, &PollShare{})
+}
+
 func seedData(DB *gorm.DB) {
 
 }
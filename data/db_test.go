@@ -0,0 +1,25 @@
+package data
+
+import (
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// setupTestDB points DB at a fresh in-memory SQLite database with all tables migrated.
+func setupTestDB(t *testing.T) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1) // Every connection to :memory: is a separate database
+	if err := Migrate(db); err != nil {
+		t.Fatalf("failed to migrate test database: %v", err)
+	}
+	DB = db
+	t.Cleanup(func() { sqlDB.Close() })
+}
@@ -15,6 +15,8 @@ func (p *Poll) DeepCopyWithoutID() *Poll {
 		CurrentQuestionIndex: p.CurrentQuestionIndex,
 		Status:               p.Status,
 		AdminUserID:          p.AdminUserID,
+		WorkspaceID:          p.WorkspaceID,
+		ResultsVisibility:    p.ResultsVisibility,
 		// Explicitly set ID to 0. Copy other gorm.Model fields.
 		Model: gorm.Model{
 			ID:        0, // ID is reset to 0
@@ -44,9 +46,10 @@ func (q *Question) DeepCopyWithoutID() *Question {
 		return nil
 	}
 	newQuestion := &Question{
-		Text:   q.Text,
-		Type:   q.Type,
-		PollID: q.PollID,
+		Text:              q.Text,
+		Type:              q.Type,
+		PollID:            q.PollID,
+		ResultsVisibility: q.ResultsVisibility,
 		// Explicitly set ID to 0. Copy other gorm.Model fields.
 		Model: gorm.Model{
 			ID:        0, // ID is reset to 0
@@ -84,6 +87,7 @@ func (o *Option) DeepCopyWithoutID() *Option {
 	}
 	newOption := &Option{
 		Text:       o.Text,
+		Correct:    o.Correct,
 		QuestionID: o.QuestionID,
 		// Explicitly set ID to 0. Copy other gorm.Model fields.
 		Model: gorm.Model{
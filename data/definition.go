@@ -0,0 +1,205 @@
+package data
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// PollDefinitionVersion is the version of the poll definition format written by ExportPollDefinition.
+const PollDefinitionVersion = 1
+
+// Limits checked when a poll definition is imported. MaxDefinitionQuestions also limits the
+// questions imported from quiz formats.
+const (
+	maxDefinitionTitleLength = 200
+	MaxDefinitionQuestions   = 100
+	maxDefinitionOptions     = 50
+)
+
+// PollDefinition is the content of a poll, without IDs, owner, status or votes, so it can be
+// kept in git or moved between environments. It is written as JSON or YAML, for example:
+//
+//	version: 1
+//	title: Friday quiz
+//	questions:
+//	  - text: Favourite language?
+//	    type: single-select
+//	    options: [Go, Rust, Zig]
+//	  - text: Which is compiled?
+//	    type: multi-select
+//	    options:
+//	      - {text: Go, correct: true}
+//	      - {text: Python}
+//
+// resultsVisibility, on the poll or a question, is never, after_reveal or live, see ResultsNever.
+type PollDefinition struct {
+	Version           int                  `json:"version" yaml:"version"`
+	Title             string               `json:"title" yaml:"title"`
+	ResultsVisibility string               `json:"resultsVisibility,omitempty" yaml:"resultsVisibility,omitempty"`
+	Questions         []QuestionDefinition `json:"questions" yaml:"questions"`
+}
+
+// QuestionDefinition is a question of a PollDefinition. Options are in order.
+// A question without a resultsVisibility uses the setting of the poll.
+type QuestionDefinition struct {
+	Text              string             `json:"text" yaml:"text"`
+	Type              string             `json:"type" yaml:"type"` // "single-select" or "multi-select"
+	ResultsVisibility string             `json:"resultsVisibility,omitempty" yaml:"resultsVisibility,omitempty"`
+	Options           []OptionDefinition `json:"options" yaml:"options"`
+}
+
+// OptionDefinition is an option of a QuestionDefinition. It is written as an object; a plain
+// string, as written before correct answers were kept, is read as the option text.
+type OptionDefinition struct {
+	Text    string `json:"text" yaml:"text"`
+	Correct bool   `json:"correct,omitempty" yaml:"correct,omitempty"`
+}
+
+// optionDefinitionFields are the keys of an OptionDefinition object, any other key is rejected.
+var optionDefinitionFields = []string{"text", "correct"}
+
+func (o *OptionDefinition) UnmarshalJSON(raw []byte) error {
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		*o = OptionDefinition{Text: text}
+		return nil
+	}
+	type plain OptionDefinition // Without this method
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	return dec.Decode((*plain)(o))
+}
+
+func (o *OptionDefinition) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*o = OptionDefinition{}
+		return node.Decode(&o.Text)
+	}
+	if node.Kind == yaml.MappingNode {
+		for i := 0; i < len(node.Content); i += 2 {
+			if key := node.Content[i].Value; !slices.Contains(optionDefinitionFields, key) {
+				return fmt.Errorf("line %d: field %s not found in type data.OptionDefinition", node.Content[i].Line, key)
+			}
+		}
+	}
+	type plain OptionDefinition
+	return node.Decode((*plain)(o))
+}
+
+// ExportPollDefinition returns the definition of a poll loaded with its questions and options.
+func ExportPollDefinition(p *Poll) *PollDefinition {
+	def := &PollDefinition{Version: PollDefinitionVersion, Title: p.Title, ResultsVisibility: p.ResultsVisibility, Questions: []QuestionDefinition{}}
+	for _, q := range p.Questions {
+		question := QuestionDefinition{Text: q.Text, Type: q.Type, ResultsVisibility: q.ResultsVisibility, Options: []OptionDefinition{}}
+		for _, o := range q.Options {
+			question.Options = append(question.Options, OptionDefinition{Text: o.Text, Correct: o.Correct})
+		}
+		def.Questions = append(def.Questions, question)
+	}
+	return def
+}
+
+// ParsePollDefinition reads a poll definition in JSON or YAML and validates it.
+// Unknown fields are rejected so typos do not silently drop content.
+func ParsePollDefinition(raw []byte, format string) (*PollDefinition, error) {
+	def := &PollDefinition{}
+	switch format {
+	case "json":
+		dec := json.NewDecoder(bytes.NewReader(raw))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(def); err != nil {
+			return nil, fmt.Errorf("invalid JSON: %w", err)
+		}
+	case "yaml":
+		dec := yaml.NewDecoder(bytes.NewReader(raw))
+		dec.KnownFields(true)
+		if err := dec.Decode(def); err != nil {
+			return nil, fmt.Errorf("invalid YAML: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unknown format %q", format)
+	}
+	if err := def.Validate(); err != nil {
+		return nil, err
+	}
+	return def, nil
+}
+
+// Validate checks a definition and returns all problems found, one per line.
+func (d *PollDefinition) Validate() error {
+	var problems []error
+	if d.Version != PollDefinitionVersion {
+		problems = append(problems, fmt.Errorf("version: must be %d", PollDefinitionVersion))
+	}
+	if strings.TrimSpace(d.Title) == "" {
+		problems = append(problems, errors.New("title: is required"))
+	} else if len(d.Title) > maxDefinitionTitleLength {
+		problems = append(problems, fmt.Errorf("title: must be at most %d characters", maxDefinitionTitleLength))
+	}
+	if !ValidResultsVisibility(d.ResultsVisibility) {
+		problems = append(problems, errors.New("resultsVisibility: must be never, after_reveal or live"))
+	}
+	if len(d.Questions) > MaxDefinitionQuestions {
+		problems = append(problems, fmt.Errorf("questions: at most %d questions are allowed", MaxDefinitionQuestions))
+	}
+	for i, q := range d.Questions {
+		path := fmt.Sprintf("questions[%d]", i)
+		if strings.TrimSpace(q.Text) == "" {
+			problems = append(problems, fmt.Errorf("%s.text: is required", path))
+		}
+		if q.Type != "single-select" && q.Type != "multi-select" {
+			problems = append(problems, fmt.Errorf("%s.type: must be single-select or multi-select", path))
+		}
+		if !ValidResultsVisibility(q.ResultsVisibility) {
+			problems = append(problems, fmt.Errorf("%s.resultsVisibility: must be never, after_reveal or live", path))
+		}
+		if len(q.Options) == 0 {
+			problems = append(problems, fmt.Errorf("%s.options: at least one option is required", path))
+		} else if len(q.Options) > maxDefinitionOptions {
+			problems = append(problems, fmt.Errorf("%s.options: at most %d options are allowed", path, maxDefinitionOptions))
+		}
+		for j, o := range q.Options {
+			if strings.TrimSpace(o.Text) == "" {
+				problems = append(problems, fmt.Errorf("%s.options[%d]: text is required", path, j))
+			}
+		}
+	}
+	return errors.Join(problems...)
+}
+
+// NewPollFromDefinition returns an unsaved poll with the content of a definition, owned by adminUserID
+// and ready to be started. Like a copied poll it gets a new invite ID.
+func NewPollFromDefinition(def *PollDefinition, adminUserID int) *Poll {
+	template := &Poll{
+		Title:                def.Title,
+		CurrentQuestionIndex: -1, // No question active yet
+		Status:               "setup",
+		AdminUserID:          adminUserID,
+		ResultsVisibility:    def.ResultsVisibility,
+	}
+	for _, q := range def.Questions {
+		question := Question{Text: q.Text, Type: q.Type, ResultsVisibility: q.ResultsVisibility}
+		for _, o := range q.Options {
+			question.Options = append(question.Options, Option{Text: o.Text, Correct: o.Correct})
+		}
+		template.Questions = append(template.Questions, question)
+	}
+	return template.DeepCopyWithoutID()
+}
+
+// Marshal writes the definition as JSON or YAML.
+func (d *PollDefinition) Marshal(format string) ([]byte, error) {
+	switch format {
+	case "json":
+		return json.MarshalIndent(d, "", "  ")
+	case "yaml":
+		return yaml.Marshal(d)
+	}
+	return nil, fmt.Errorf("unknown format %q", format)
+}
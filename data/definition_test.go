@@ -0,0 +1,117 @@
+package data
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPollDefinitionRoundTrip(t *testing.T) {
+	setupTestDB(t)
+	poll := createTestPoll(t, 1)
+	poll.ResultsVisibility = ResultsLive
+	poll.Questions[0].ResultsVisibility = ResultsNever
+	poll.Questions[0].Options[1].Correct = true
+
+	def := ExportPollDefinition(poll)
+	for _, format := range []string{"json", "yaml"} {
+		raw, err := def.Marshal(format)
+		if err != nil {
+			t.Fatalf("Marshal(%s) returned error: %v", format, err)
+		}
+		parsed, err := ParsePollDefinition(raw, format)
+		if err != nil {
+			t.Fatalf("ParsePollDefinition(%s) returned error: %v\n%s", format, err, raw)
+		}
+
+		imported := NewPollFromDefinition(parsed, 7)
+		if err := DB.Create(imported).Error; err != nil {
+			t.Fatalf("failed to save imported poll: %v", err)
+		}
+		if imported.ID == poll.ID || imported.InviteID == "" || imported.InviteID == poll.InviteID {
+			t.Errorf("imported poll should be a new poll with its own invite ID")
+		}
+		if imported.AdminUserID != 7 || imported.Status != "setup" || imported.CurrentQuestionIndex != -1 {
+			t.Errorf("imported poll should belong to the importer and be ready to start, got %+v", imported)
+		}
+		if len(imported.Questions) != 1 || len(imported.Questions[0].Options) != 2 || imported.Questions[0].Options[1].Text != "B" {
+			t.Errorf("imported poll should have the same questions and options, got %+v", imported.Questions)
+		}
+		if imported.ResultsVisibility != ResultsLive || imported.Questions[0].ResultsVisibility != ResultsNever {
+			t.Errorf("imported poll should keep the result visibility settings")
+		}
+		if imported.Questions[0].ID == poll.Questions[0].ID {
+			t.Errorf("imported questions should get new IDs")
+		}
+		var options []Option
+		DB.Where("question_id = ?", imported.Questions[0].ID).Order("id").Find(&options)
+		if len(options) != 2 || options[0].Correct || !options[1].Correct {
+			t.Errorf("imported poll should keep the correct answers, got %+v", options)
+		}
+	}
+}
+
+func TestParsePollDefinitionOptions(t *testing.T) {
+	for format, raw := range map[string]string{
+		"json": `{"version":1,"title":"Quiz","questions":[{"text":"Q","type":"single-select","options":["A",{"text":"B","correct":true}]}]}`,
+		"yaml": "version: 1\ntitle: Quiz\nquestions:\n  - text: Q\n    type: single-select\n    options:\n      - A\n      - {text: B, correct: true}\n",
+	} {
+		def, err := ParsePollDefinition([]byte(raw), format)
+		if err != nil {
+			t.Fatalf("ParsePollDefinition(%s) returned error: %v", format, err)
+		}
+		want := []OptionDefinition{{Text: "A"}, {Text: "B", Correct: true}}
+		if got := def.Questions[0].Options; len(got) != 2 || got[0] != want[0] || got[1] != want[1] {
+			t.Errorf("%s: plain strings and option objects should both be read, got %+v", format, got)
+		}
+	}
+
+	if _, err := ParsePollDefinition([]byte(`{"version":1,"title":"x","questions":[{"text":"Q","type":"single-select","options":[{"text":"A","right":true}]}]}`), "json"); err == nil {
+		t.Error("expected unknown JSON option field to be rejected")
+	}
+	if _, err := ParsePollDefinition([]byte("version: 1\ntitle: x\nquestions:\n  - text: Q\n    type: single-select\n    options: [{text: A, right: true}]\n"), "yaml"); err == nil {
+		t.Error("expected unknown YAML option field to be rejected")
+	}
+}
+
+func TestParsePollDefinitionReportsAllProblems(t *testing.T) {
+	raw := []byte(`
+version: 1
+title: ""
+questions:
+  - text: Pick one
+    type: dropdown
+    resultsVisibility: sometimes
+    options: []
+  - text: ""
+    type: multi-select
+    options: [A, " "]
+`)
+	_, err := ParsePollDefinition(raw, "yaml")
+	if err == nil {
+		t.Fatal("expected an invalid definition to be rejected")
+	}
+	for _, want := range []string{
+		"title: is required",
+		"questions[0].type: must be single-select or multi-select",
+		"questions[0].resultsVisibility: must be never, after_reveal or live",
+		"questions[0].options: at least one option is required",
+		"questions[1].text: is required",
+		"questions[1].options[1]: text is required",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected error to contain %q, got:\n%v", want, err)
+		}
+	}
+}
+
+func TestParsePollDefinitionRejectsUnknownFields(t *testing.T) {
+	if _, err := ParsePollDefinition([]byte(`{"version":1,"title":"x","questions":[],"owner":"me"}`), "json"); err == nil {
+		t.Error("expected unknown JSON field to be rejected")
+	}
+	if _, err := ParsePollDefinition([]byte("version: 1\ntitle: x\nquestion: []\n"), "yaml"); err == nil {
+		t.Error("expected unknown YAML field to be rejected")
+	}
+	if _, err := ParsePollDefinition([]byte(`{"version":2,"title":"x","questions":[]}`), "json"); err == nil {
+		t.Error("expected unsupported version to be rejected")
+	}
+}
@@ -0,0 +1,103 @@
+package data
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aspcodenet/systementorlivepolls/utils"
+	"gorm.io/gorm"
+)
+
+// AdminInvite is a single-use link that lets whoever logs in through it use the admin pages
+// with Role, without waiting for an owner to approve them.
+type AdminInvite struct {
+	gorm.Model
+	Code        string `gorm:"size:30;uniqueIndex"` // Stored in AdminUser.FromInvite of the user who used it
+	Role        string `gorm:"size:20"`
+	CreatedByID uint
+	ExpiresAt   time.Time
+	UsedAt      *time.Time
+	UsedByID    *uint
+	CreatedBy   *AdminUser `gorm:"foreignKey:CreatedByID"`
+}
+
+// ErrInviteInvalid is returned for invites that do not exist, have expired or have been used.
+var ErrInviteInvalid = errors.New("the invitation has expired or has already been used")
+
+// Usable reports whether the invite can still be used at now.
+func (i *AdminInvite) Usable(now time.Time) bool {
+	return i.UsedAt == nil && now.Before(i.ExpiresAt)
+}
+
+// CreateAdminInvite stores a new invite for role that expires after validFor.
+func CreateAdminInvite(createdBy *AdminUser, role string, validFor time.Duration) (*AdminInvite, error) {
+	if !ValidRole(role) {
+		return nil, fmt.Errorf("invalid role %q", role)
+	}
+	code, err := utils.RandString(18) // 24 characters, fits AdminUser.FromInvite
+	if err != nil {
+		return nil, err
+	}
+	invite := &AdminInvite{
+		Code:        code,
+		Role:        role,
+		CreatedByID: createdBy.ID,
+		ExpiresAt:   time.Now().Add(validFor),
+	}
+	if err := DB.Create(invite).Error; err != nil {
+		return nil, fmt.Errorf("failed to create invite: %w", err)
+	}
+	return invite, nil
+}
+
+// GetUsableAdminInvite returns the invite with code if it can still be used.
+func GetUsableAdminInvite(code string, now time.Time) (*AdminInvite, error) {
+	invite := &AdminInvite{}
+	if code == "" {
+		return nil, ErrInviteInvalid
+	}
+	err := DB.First(invite, "code = ?", code).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && !invite.Usable(now)) {
+		return nil, ErrInviteInvalid
+	}
+	return invite, err
+}
+
+// GetAdminInvites returns the invites that have not been used and have not expired, newest first.
+func GetAdminInvites(now time.Time) ([]*AdminInvite, error) {
+	invites := []*AdminInvite{}
+	err := DB.Preload("CreatedBy").Where("used_at IS NULL AND expires_at > ?", now).Order("id desc").Find(&invites).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve invites: %w", err)
+	}
+	return invites, nil
+}
+
+// RevokeAdminInvite deletes an invite so its link stops working.
+func RevokeAdminInvite(inviteID uint) error {
+	return DB.Delete(&AdminInvite{}, inviteID).Error
+}
+
+// RedeemAdminInvite uses the invite with code for user: the user gets the invite's role, is made
+// active and remembers the invite in FromInvite. An invite can only be used once, even by two
+// logins at the same time.
+func RedeemAdminInvite(code string, user *AdminUser, now time.Time) error {
+	invite, err := GetUsableAdminInvite(code, now)
+	if err != nil {
+		return err
+	}
+	return DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&AdminInvite{}).
+			Where("id = ? AND used_at IS NULL AND expires_at > ?", invite.ID, now).
+			Updates(map[string]interface{}{"used_at": now, "used_by_id": user.ID})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected != 1 {
+			return ErrInviteInvalid
+		}
+		user.FromInvite, user.Role, user.Active = invite.Code, invite.Role, true
+		return tx.Model(user).Select("FromInvite", "Role", "Active").Updates(user).Error
+	})
+}
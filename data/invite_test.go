@@ -0,0 +1,71 @@
+package data
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRedeemAdminInvite(t *testing.T) {
+	setupTestDB(t)
+	owner, _ := BootstrapOwner("owner@example.com")
+	invite, err := CreateAdminInvite(owner, RoleViewer, 24*time.Hour)
+	if err != nil {
+		t.Fatalf("CreateAdminInvite returned error: %v", err)
+	}
+	if len(invite.Code) != 24 {
+		t.Errorf("expected a 24 character code, got %q", invite.Code)
+	}
+
+	user := &AdminUser{Email: "new@example.com"}
+	DB.Create(user)
+	if err := RedeemAdminInvite(invite.Code, user, time.Now()); err != nil {
+		t.Fatalf("RedeemAdminInvite returned error: %v", err)
+	}
+	var stored AdminUser
+	DB.First(&stored, user.ID)
+	if stored.FromInvite != invite.Code || stored.Role != RoleViewer || !stored.Active {
+		t.Errorf("expected an active viewer from the invite, got %+v", stored)
+	}
+
+	// Single use
+	other := &AdminUser{Email: "other@example.com"}
+	DB.Create(other)
+	if err := RedeemAdminInvite(invite.Code, other, time.Now()); !errors.Is(err, ErrInviteInvalid) {
+		t.Errorf("a used invite: expected ErrInviteInvalid, got %v", err)
+	}
+	if invites, _ := GetAdminInvites(time.Now()); len(invites) != 0 {
+		t.Errorf("a used invite should not be listed, got %d", len(invites))
+	}
+}
+
+func TestAdminInviteExpiresAndCanBeRevoked(t *testing.T) {
+	setupTestDB(t)
+	owner, _ := BootstrapOwner("owner@example.com")
+	user := &AdminUser{Email: "new@example.com"}
+	DB.Create(user)
+
+	expiring, _ := CreateAdminInvite(owner, RoleAdmin, time.Hour)
+	if err := RedeemAdminInvite(expiring.Code, user, time.Now().Add(2*time.Hour)); !errors.Is(err, ErrInviteInvalid) {
+		t.Errorf("an expired invite: expected ErrInviteInvalid, got %v", err)
+	}
+
+	revoked, _ := CreateAdminInvite(owner, RoleAdmin, time.Hour)
+	invites, _ := GetAdminInvites(time.Now())
+	if len(invites) != 2 || invites[0].ID != revoked.ID || invites[0].CreatedBy == nil {
+		t.Fatalf("expected both invites with their creator, newest first, got %+v", invites)
+	}
+	if err := RevokeAdminInvite(revoked.ID); err != nil {
+		t.Fatalf("RevokeAdminInvite returned error: %v", err)
+	}
+	if err := RedeemAdminInvite(revoked.Code, user, time.Now()); !errors.Is(err, ErrInviteInvalid) {
+		t.Errorf("a revoked invite: expected ErrInviteInvalid, got %v", err)
+	}
+	if user.IsAdmin() {
+		t.Error("the user should not have been given access")
+	}
+
+	if _, err := CreateAdminInvite(owner, "superuser", time.Hour); err == nil {
+		t.Error("an unknown role should be refused")
+	}
+}
@@ -0,0 +1,100 @@
+package data
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// JoinPINLength is the number of digits in a join PIN.
+const JoinPINLength = 6
+
+// maxJoinPINAttempts is how many random PINs AllocateJoinPIN tries before giving up. With a
+// million PINs and few live polls, a collision more than a couple of times in a row means
+// something else is wrong.
+const maxJoinPINAttempts = 20
+
+// ErrNoJoinPIN is returned when no free join PIN was found.
+var ErrNoJoinPIN = errors.New("no free join PIN")
+
+// IsJoinPIN reports whether code looks like a join PIN rather than an invite ID.
+func IsJoinPIN(code string) bool {
+	if len(code) != JoinPINLength {
+		return false
+	}
+	for _, r := range code {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// NormalizeJoinCode trims what participants type around a code. Spaces and dashes inside a
+// PIN, as in "123 456", are removed; invite IDs are returned trimmed but otherwise as is.
+func NormalizeJoinCode(code string) string {
+	code = strings.TrimSpace(code)
+	compact := strings.NewReplacer(" ", "", "-", "").Replace(code)
+	if IsJoinPIN(compact) {
+		return compact
+	}
+	return code
+}
+
+func randomJoinPIN() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1_000_000))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
+
+// AllocateJoinPIN gives a live poll a random PIN that no other poll holds. The unique index on
+// join_pin settles races between polls starting at the same time. A poll that already has a
+// PIN keeps it.
+func AllocateJoinPIN(p *Poll) error {
+	if p.JoinPIN != nil {
+		return nil
+	}
+	for attempt := 0; attempt < maxJoinPINAttempts; attempt++ {
+		pin, err := randomJoinPIN()
+		if err != nil {
+			return err
+		}
+		result := DB.Model(&Poll{}).Where("id = ? AND join_pin IS NULL", p.ID).Update("join_pin", pin)
+		if result.Error != nil {
+			continue // Most likely taken by another poll
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("poll %d not found or already has a join PIN", p.ID)
+		}
+		p.JoinPIN = &pin
+		return nil
+	}
+	return ErrNoJoinPIN
+}
+
+// ReleaseJoinPIN frees the PIN of a poll so other polls can get it.
+func ReleaseJoinPIN(p *Poll) error {
+	p.JoinPIN = nil
+	if err := DB.Model(&Poll{}).Where("id = ?", p.ID).Update("join_pin", nil).Error; err != nil {
+		return fmt.Errorf("failed to release join PIN of poll %d: %w", p.ID, err)
+	}
+	return nil
+}
+
+// FindPollByJoinCode looks up a poll by its join PIN or its invite ID.
+func FindPollByJoinCode(code string) (*Poll, error) {
+	code = NormalizeJoinCode(code)
+	poll := &Poll{}
+	column := "invite_id"
+	if IsJoinPIN(code) {
+		column = "join_pin"
+	}
+	if err := DB.First(poll, column+" = ?", code).Error; err != nil {
+		return nil, err
+	}
+	return poll, nil
+}
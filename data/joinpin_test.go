@@ -0,0 +1,82 @@
+package data
+
+import (
+	"testing"
+)
+
+func TestIsJoinPIN(t *testing.T) {
+	for code, want := range map[string]bool{"012345": true, "12345": false, "1234567": false, "12a456": false, "abcdefghijklmnopqrstuv": false} {
+		if got := IsJoinPIN(code); got != want {
+			t.Errorf("IsJoinPIN(%q) = %v, want %v", code, got, want)
+		}
+	}
+	if got := NormalizeJoinCode(" 123 456 "); got != "123456" {
+		t.Errorf("NormalizeJoinCode = %q, want 123456", got)
+	}
+	if got := NormalizeJoinCode(" ab-cd "); got != "ab-cd" {
+		t.Errorf("NormalizeJoinCode changed an invite ID: %q", got)
+	}
+}
+
+func TestAllocateJoinPIN(t *testing.T) {
+	setupTestDB(t)
+	first := createTestPoll(t, 1)
+	second := createTestPoll(t, 1)
+
+	if err := AllocateJoinPIN(first); err != nil {
+		t.Fatalf("AllocateJoinPIN returned error: %v", err)
+	}
+	if err := AllocateJoinPIN(second); err != nil {
+		t.Fatalf("AllocateJoinPIN returned error: %v", err)
+	}
+	if first.JoinPIN == nil || !IsJoinPIN(*first.JoinPIN) {
+		t.Fatalf("expected a 6 digit PIN, got %v", first.JoinPIN)
+	}
+	if *first.JoinPIN == *second.JoinPIN {
+		t.Errorf("two live polls got the same PIN %s", *first.JoinPIN)
+	}
+
+	pin := *first.JoinPIN
+	if err := AllocateJoinPIN(first); err != nil || *first.JoinPIN != pin {
+		t.Errorf("a poll with a PIN should keep it, got %v (err %v)", first.JoinPIN, err)
+	}
+
+	found, err := FindPollByJoinCode(pin[:3] + " " + pin[3:])
+	if err != nil || found.ID != first.ID {
+		t.Fatalf("FindPollByJoinCode(%s) = %v, %v", pin, found, err)
+	}
+
+	if err := ReleaseJoinPIN(first); err != nil {
+		t.Fatalf("ReleaseJoinPIN returned error: %v", err)
+	}
+	if _, err := FindPollByJoinCode(pin); err == nil {
+		t.Error("a released PIN should not find the poll")
+	}
+}
+
+func TestFindPollByJoinCodeInviteID(t *testing.T) {
+	setupTestDB(t)
+	poll := createTestPoll(t, 1)
+	DB.Model(poll).Update("invite_id", "abcdefghijklmnopqrstuv")
+
+	found, err := FindPollByJoinCode(" abcdefghijklmnopqrstuv")
+	if err != nil || found.ID != poll.ID {
+		t.Fatalf("FindPollByJoinCode by invite ID = %v, %v", found, err)
+	}
+}
+
+func TestSoftDeletePollReleasesJoinPIN(t *testing.T) {
+	setupTestDB(t)
+	poll := createTestPoll(t, 1)
+	if err := AllocateJoinPIN(poll); err != nil {
+		t.Fatalf("AllocateJoinPIN returned error: %v", err)
+	}
+	if err := SoftDeletePoll(poll.ID); err != nil {
+		t.Fatalf("SoftDeletePoll returned error: %v", err)
+	}
+	var deleted Poll
+	DB.Unscoped().First(&deleted, poll.ID)
+	if deleted.JoinPIN != nil {
+		t.Errorf("expected the PIN of a deleted poll to be released, got %s", *deleted.JoinPIN)
+	}
+}
@@ -11,6 +11,7 @@ import (
 type Option struct {
 	gorm.Model        // Adds ID, CreatedAt, UpdatedAt, DeletedAt
 	Text       string `json:"text"`
+	Correct    bool   `json:"correct"`        // Marked as the right answer, e.g. by a quiz import. Never sent to participants
 	QuestionID uint   `json:"-" gorm:"index"` // Foreign key to Question, '-' to ignore in JSON marshal
 }
 
@@ -21,6 +22,10 @@ type Question struct {
 	Type       string   `json:"type"`                                 // "single-select" or "multi-select"
 	Options    []Option `json:"options" gorm:"foreignKey:QuestionID"` // One-to-many relationship
 	PollID     uint     `json:"-" gorm:"index"`                       // Foreign key to Poll
+	// When participants see the votes, one of the Results constants. Empty uses the poll's setting
+	ResultsVisibility string `json:"resultsVisibility,omitempty" gorm:"size:20"`
+	// Set when the presenter has shown the results of the question in a run
+	ResultsShown bool `json:"-"`
 
 	// Transient field for votes, not stored directly by GORM but populated from Vote table
 	Votes map[string]int `json:"votes" gorm:"-"` // '-' to ignore by GORM, handled manually
@@ -36,6 +41,14 @@ type Poll struct {
 	AdminUserID          int        `json:"-"`
 	Mu                   sync.Mutex `gorm:"-"`        // Mutex for protecting poll data, ignore by GORM
 	InviteID             string     `json:"inviteID"` // Identifier for the poll invite (e.g., unique code)
+	// Short code participants can type while the poll is live, nil otherwise
+	JoinPIN *string `json:"joinPin,omitempty" gorm:"size:6;uniqueIndex"`
+	// Secret for the read-only presenter link, empty until the control panel is first opened
+	PresenterToken string `json:"-" gorm:"size:32;index"`
+	// When participants see the votes, one of the Results constants. Empty means ResultsAfterReveal
+	ResultsVisibility string `json:"resultsVisibility,omitempty" gorm:"size:20"`
+	// The workspace whose members share the poll, nil for a poll of AdminUserID alone
+	WorkspaceID *uint `json:"-" gorm:"index"`
 }
 
 // Vote represents a single vote by a user for an option.
@@ -44,6 +57,7 @@ type Vote struct {
 	QuestionID uint   `gorm:"index"` // Foreign key to Question
 	OptionID   uint   `gorm:"index"` // Foreign key to Option
 	VoterID    string `gorm:"index"` // Identifier for the voter (e.g., session ID, user ID)
+	PollRunID  uint   `gorm:"index"` // The run the vote was cast in, 0 for votes from before runs were recorded
 }
 
 // GetPollAndDetailsForAdmin retrieves a poll, its questions, options, and aggregates vote counts.
@@ -186,3 +200,15 @@ func GetPollWithDetails(inviteID string) (*Poll, error) {
 
 	return poll, nil
 }
+
+// MaxPollsPerAdmin is how many polls a user may own, not counting polls in the trash.
+const MaxPollsPerAdmin = 5
+
+// PollLimitReached reports whether user owns MaxPollsPerAdmin polls and may not create another.
+func PollLimitReached(user *AdminUser) (bool, error) {
+	var count int64
+	if err := DB.Model(&Poll{}).Where("admin_user_id = ?", user.ID).Count(&count).Error; err != nil {
+		return false, fmt.Errorf("failed to count polls of user %d: %w", user.ID, err)
+	}
+	return count >= MaxPollsPerAdmin, nil
+}
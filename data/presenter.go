@@ -0,0 +1,37 @@
+package data
+
+import (
+	"fmt"
+
+	"github.com/aspcodenet/systementorlivepolls/utils"
+)
+
+// EnsurePresenterToken gives a poll a presenter token unless it already has one.
+func EnsurePresenterToken(p *Poll) error {
+	if p.PresenterToken != "" {
+		return nil
+	}
+	return ResetPresenterToken(p)
+}
+
+// ResetPresenterToken replaces the presenter token of a poll, so links shared earlier stop working.
+func ResetPresenterToken(p *Poll) error {
+	token, err := utils.RandString(18) // 24 characters
+	if err != nil {
+		return err
+	}
+	if err := DB.Model(&Poll{}).Where("id = ?", p.ID).Update("presenter_token", token).Error; err != nil {
+		return fmt.Errorf("failed to save presenter token of poll %d: %w", p.ID, err)
+	}
+	p.PresenterToken = token
+	return nil
+}
+
+// GetPollByPresenterToken looks up the poll a presenter link belongs to.
+func GetPollByPresenterToken(token string) (*Poll, error) {
+	poll := &Poll{}
+	if err := DB.First(poll, "presenter_token = ? AND presenter_token <> ''", token).Error; err != nil {
+		return nil, err
+	}
+	return poll, nil
+}
@@ -0,0 +1,36 @@
+package data
+
+import "testing"
+
+func TestPresenterToken(t *testing.T) {
+	setupTestDB(t)
+	poll := createTestPoll(t, 1)
+
+	if _, err := GetPollByPresenterToken(""); err == nil {
+		t.Error("an empty token should not find a poll without a presenter link")
+	}
+	if err := EnsurePresenterToken(poll); err != nil {
+		t.Fatalf("EnsurePresenterToken returned error: %v", err)
+	}
+	token := poll.PresenterToken
+	if len(token) != 24 {
+		t.Fatalf("expected a 24 character token, got %q", token)
+	}
+	if err := EnsurePresenterToken(poll); err != nil || poll.PresenterToken != token {
+		t.Errorf("EnsurePresenterToken should keep an existing token, got %q (err %v)", poll.PresenterToken, err)
+	}
+	found, err := GetPollByPresenterToken(token)
+	if err != nil || found.ID != poll.ID {
+		t.Fatalf("GetPollByPresenterToken = %v, %v", found, err)
+	}
+
+	if err := ResetPresenterToken(poll); err != nil {
+		t.Fatalf("ResetPresenterToken returned error: %v", err)
+	}
+	if poll.PresenterToken == token {
+		t.Error("ResetPresenterToken should change the token")
+	}
+	if _, err := GetPollByPresenterToken(token); err == nil {
+		t.Error("the old token should stop working after a reset")
+	}
+}
@@ -0,0 +1,135 @@
+package data
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// OptionResult is the number of votes for one option of a question.
+type OptionResult struct {
+	OptionID   uint    `json:"optionId"`
+	Text       string  `json:"text"`
+	Count      int     `json:"count"`
+	Percentage float64 `json:"percentage"` // Share of all votes for the question, 0-100
+}
+
+// QuestionResult holds the aggregated votes for one question.
+type QuestionResult struct {
+	QuestionID  uint           `json:"questionId"`
+	Text        string         `json:"text"`
+	Type        string         `json:"type"`
+	Options     []OptionResult `json:"options"`
+	TotalVotes  int            `json:"totalVotes"`
+	Respondents int            `json:"respondents"` // Distinct voters, differs from TotalVotes for multi-select
+}
+
+// GetPollResults aggregates the votes of every question in a poll, in question order.
+// If runID is 0 votes from all runs are counted, otherwise only votes cast in that run.
+func GetPollResults(pollID uint, runID uint) ([]QuestionResult, error) {
+	poll := &Poll{}
+	if err := DB.Preload("Questions.Options").First(poll, pollID).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve poll %d: %w", pollID, err)
+	}
+
+	questionIDs := []uint{}
+	for _, q := range poll.Questions {
+		questionIDs = append(questionIDs, q.ID)
+	}
+
+	type voteCount struct {
+		QuestionID uint
+		OptionID   uint
+		Count      int
+	}
+	type respondentCount struct {
+		QuestionID uint
+		Count      int
+	}
+	var counts []voteCount
+	var respondents []respondentCount
+	if len(questionIDs) > 0 {
+		query := DB.Model(&Vote{}).Where("question_id IN (?)", questionIDs)
+		if runID != 0 {
+			query = query.Where("poll_run_id = ?", runID)
+		}
+		query = query.Session(&gorm.Session{}) // Reuse the conditions for both aggregations
+		err := query.
+			Select("question_id, option_id, COUNT(*) as count").
+			Group("question_id, option_id").
+			Find(&counts).Error
+		if err != nil {
+			return nil, fmt.Errorf("failed to aggregate votes: %w", err)
+		}
+		err = query.
+			Select("question_id, COUNT(DISTINCT voter_id) as count").
+			Group("question_id").
+			Find(&respondents).Error
+		if err != nil {
+			return nil, fmt.Errorf("failed to count respondents: %w", err)
+		}
+	}
+
+	countByOption := make(map[uint]int)
+	for _, vc := range counts {
+		countByOption[vc.OptionID] = vc.Count
+	}
+	respondentsByQuestion := make(map[uint]int)
+	for _, rc := range respondents {
+		respondentsByQuestion[rc.QuestionID] = rc.Count
+	}
+
+	results := make([]QuestionResult, 0, len(poll.Questions))
+	for _, q := range poll.Questions {
+		qr := QuestionResult{
+			QuestionID:  q.ID,
+			Text:        q.Text,
+			Type:        q.Type,
+			Options:     make([]OptionResult, 0, len(q.Options)),
+			Respondents: respondentsByQuestion[q.ID],
+		}
+		for _, opt := range q.Options {
+			count := countByOption[opt.ID]
+			qr.TotalVotes += count
+			qr.Options = append(qr.Options, OptionResult{OptionID: opt.ID, Text: opt.Text, Count: count})
+		}
+		if qr.TotalVotes > 0 {
+			for i := range qr.Options {
+				qr.Options[i].Percentage = float64(qr.Options[i].Count) * 100 / float64(qr.TotalVotes)
+			}
+		}
+		results = append(results, qr)
+	}
+	return results, nil
+}
+
+// RawResponse is one vote, joined with the texts of its question and option.
+type RawResponse struct {
+	PollRunID    uint      `json:"runId"`
+	VoterID      string    `json:"voterId"`
+	QuestionID   uint      `json:"questionId"`
+	QuestionText string    `json:"question"`
+	OptionID     uint      `json:"optionId"`
+	OptionText   string    `json:"option"`
+	VotedAt      time.Time `json:"votedAt"`
+}
+
+// GetRawResponses returns every vote of a poll ordered by time, for exports.
+// If runID is 0 votes from all runs are returned, otherwise only votes cast in that run.
+func GetRawResponses(pollID uint, runID uint) ([]RawResponse, error) {
+	responses := []RawResponse{}
+	query := DB.Model(&Vote{}).
+		Select("votes.poll_run_id, votes.voter_id, votes.question_id, questions.text AS question_text, "+
+			"votes.option_id, options.text AS option_text, votes.created_at AS voted_at").
+		Joins("JOIN questions ON questions.id = votes.question_id AND questions.deleted_at IS NULL").
+		Joins("JOIN options ON options.id = votes.option_id").
+		Where("questions.poll_id = ?", pollID)
+	if runID != 0 {
+		query = query.Where("votes.poll_run_id = ?", runID)
+	}
+	if err := query.Order("votes.created_at, votes.id").Scan(&responses).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve responses for poll %d: %w", pollID, err)
+	}
+	return responses, nil
+}
@@ -0,0 +1,59 @@
+package data
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PollRun is one live session of a poll, from "start" until the poll is finished.
+// Votes cast during the session point to it so results can be reported per run.
+type PollRun struct {
+	gorm.Model
+	PollID     uint       `gorm:"index"`
+	StartedAt  time.Time  `json:"startedAt"`
+	FinishedAt *time.Time `json:"finishedAt"`
+}
+
+// StartPollRun records the start of a new run for a poll.
+func StartPollRun(pollID uint) (*PollRun, error) {
+	run := &PollRun{PollID: pollID, StartedAt: time.Now()}
+	if err := DB.Create(run).Error; err != nil {
+		return nil, fmt.Errorf("failed to start run for poll %d: %w", pollID, err)
+	}
+	return run, nil
+}
+
+// GetActivePollRun returns the run that has not finished yet for a poll, or nil if there is none.
+func GetActivePollRun(pollID uint) (*PollRun, error) {
+	run := &PollRun{}
+	err := DB.Where("poll_id = ? AND finished_at IS NULL", pollID).Order("started_at DESC").First(run).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve active run for poll %d: %w", pollID, err)
+	}
+	return run, nil
+}
+
+// FinishPollRun marks the active run of a poll as finished. Does nothing if no run is active.
+func FinishPollRun(pollID uint) error {
+	now := time.Now()
+	err := DB.Model(&PollRun{}).Where("poll_id = ? AND finished_at IS NULL", pollID).Update("finished_at", &now).Error
+	if err != nil {
+		return fmt.Errorf("failed to finish run for poll %d: %w", pollID, err)
+	}
+	return nil
+}
+
+// GetPollRuns returns all runs of a poll, oldest first.
+func GetPollRuns(pollID uint) ([]PollRun, error) {
+	runs := []PollRun{}
+	if err := DB.Where("poll_id = ?", pollID).Order("started_at").Find(&runs).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve runs for poll %d: %w", pollID, err)
+	}
+	return runs, nil
+}
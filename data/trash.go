@@ -0,0 +1,131 @@
+package data
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// SoftDeletePoll moves a poll to the trash. The poll, its questions, options and votes
+// all get the same DeletedAt timestamp so RestorePoll can bring back exactly that tree.
+func SoftDeletePoll(pollID uint) error {
+	now := time.Now().Truncate(time.Millisecond) // Match the datetime(3) precision of the database
+	return DB.Transaction(func(tx *gorm.DB) error {
+		var poll Poll
+		if err := tx.First(&poll, pollID).Error; err != nil {
+			return fmt.Errorf("failed to find poll %d: %w", pollID, err)
+		}
+
+		questionIDs := tx.Model(&Question{}).Select("id").Where("poll_id = ?", pollID)
+		if err := tx.Model(&Vote{}).Where("question_id IN (?)", questionIDs).Update("deleted_at", now).Error; err != nil {
+			return fmt.Errorf("failed to delete votes: %w", err)
+		}
+		if err := tx.Model(&Option{}).Where("question_id IN (?)", questionIDs).Update("deleted_at", now).Error; err != nil {
+			return fmt.Errorf("failed to delete options: %w", err)
+		}
+		if err := tx.Model(&Question{}).Where("poll_id = ?", pollID).Update("deleted_at", now).Error; err != nil {
+			return fmt.Errorf("failed to delete questions: %w", err)
+		}
+		// A deleted poll is no longer live, so its join PIN is freed as well
+		if err := tx.Model(&poll).Updates(map[string]interface{}{"deleted_at": now, "join_pin": nil}).Error; err != nil {
+			return fmt.Errorf("failed to delete poll: %w", err)
+		}
+		return nil
+	})
+}
+
+// RestorePoll takes a poll out of the trash together with the questions, options and votes
+// that were deleted with it. Rows deleted earlier (e.g. a question removed in the editor) stay deleted.
+func RestorePoll(pollID uint) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		var poll Poll
+		if err := tx.Unscoped().Where("deleted_at IS NOT NULL").First(&poll, pollID).Error; err != nil {
+			return fmt.Errorf("failed to find deleted poll %d: %w", pollID, err)
+		}
+		deletedAt := poll.DeletedAt.Time
+
+		questionIDs := tx.Unscoped().Model(&Question{}).Select("id").Where("poll_id = ?", pollID)
+		if err := tx.Unscoped().Model(&Vote{}).Where("question_id IN (?) AND deleted_at = ?", questionIDs, deletedAt).Update("deleted_at", nil).Error; err != nil {
+			return fmt.Errorf("failed to restore votes: %w", err)
+		}
+		if err := tx.Unscoped().Model(&Option{}).Where("question_id IN (?) AND deleted_at = ?", questionIDs, deletedAt).Update("deleted_at", nil).Error; err != nil {
+			return fmt.Errorf("failed to restore options: %w", err)
+		}
+		if err := tx.Unscoped().Model(&Question{}).Where("poll_id = ? AND deleted_at = ?", pollID, deletedAt).Update("deleted_at", nil).Error; err != nil {
+			return fmt.Errorf("failed to restore questions: %w", err)
+		}
+		if err := tx.Unscoped().Model(&poll).Update("deleted_at", nil).Error; err != nil {
+			return fmt.Errorf("failed to restore poll: %w", err)
+		}
+		return nil
+	})
+}
+
+// GetDeletedPolls returns the polls in the trash that an admin user may restore, their personal
+// polls and those of the workspaces they own, most recently deleted first.
+func GetDeletedPolls(adminUserID uint) ([]*Poll, error) {
+	polls := []*Poll{}
+	ownedWorkspaceIDs := DB.Model(&WorkspaceMember{}).Select("workspace_id").Where("admin_user_id = ? AND access = ?", adminUserID, AccessOwner)
+	err := DB.Unscoped().
+		Where("deleted_at IS NOT NULL").
+		Where("(admin_user_id = ? AND workspace_id IS NULL) OR workspace_id IN (?)", adminUserID, ownedWorkspaceIDs).
+		Order("deleted_at DESC").
+		Find(&polls).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve deleted polls: %w", err)
+	}
+	return polls, nil
+}
+
+// PurgeDeletedPolls permanently removes polls that have been in the trash since before
+// the given time, including all their questions, options and votes. Returns the number of polls purged.
+func PurgeDeletedPolls(deletedBefore time.Time) (int, error) {
+	var pollIDs []uint
+	err := DB.Unscoped().Model(&Poll{}).
+		Where("deleted_at IS NOT NULL AND deleted_at < ?", deletedBefore).
+		Pluck("id", &pollIDs).Error
+	if err != nil {
+		return 0, fmt.Errorf("failed to find polls to purge: %w", err)
+	}
+	if len(pollIDs) == 0 {
+		return 0, nil
+	}
+
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		questionIDs := tx.Unscoped().Model(&Question{}).Select("id").Where("poll_id IN (?)", pollIDs)
+		if err := tx.Unscoped().Where("question_id IN (?)", questionIDs).Delete(&Vote{}).Error; err != nil {
+			return fmt.Errorf("failed to purge votes: %w", err)
+		}
+		if err := tx.Where("question_id IN (?)", questionIDs).Delete(&VoteSubmission{}).Error; err != nil {
+			return fmt.Errorf("failed to purge vote submissions: %w", err)
+		}
+		if err := tx.Unscoped().Where("question_id IN (?)", questionIDs).Delete(&Option{}).Error; err != nil {
+			return fmt.Errorf("failed to purge options: %w", err)
+		}
+		if err := tx.Unscoped().Where("poll_id IN (?)", pollIDs).Delete(&Question{}).Error; err != nil {
+			return fmt.Errorf("failed to purge questions: %w", err)
+		}
+		webhookIDs := tx.Unscoped().Model(&Webhook{}).Select("id").Where("poll_id IN (?)", pollIDs)
+		if err := tx.Where("webhook_id IN (?)", webhookIDs).Delete(&WebhookDelivery{}).Error; err != nil {
+			return fmt.Errorf("failed to purge webhook deliveries: %w", err)
+		}
+		if err := tx.Unscoped().Where("poll_id IN (?)", pollIDs).Delete(&Webhook{}).Error; err != nil {
+			return fmt.Errorf("failed to purge webhooks: %w", err)
+		}
+		if err := tx.Unscoped().Where("poll_id IN (?)", pollIDs).Delete(&PollRun{}).Error; err != nil {
+			return fmt.Errorf("failed to purge runs: %w", err)
+		}
+		if err := tx.Where("poll_id IN (?)", pollIDs).Delete(&PollShare{}).Error; err != nil {
+			return fmt.Errorf("failed to purge poll shares: %w", err)
+		}
+		if err := tx.Unscoped().Where("id IN (?)", pollIDs).Delete(&Poll{}).Error; err != nil {
+			return fmt.Errorf("failed to purge polls: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(pollIDs), nil
+}
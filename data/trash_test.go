@@ -0,0 +1,133 @@
+package data
+
+import (
+	"testing"
+	"time"
+)
+
+func createTestPoll(t *testing.T, adminUserID int) *Poll {
+	t.Helper()
+	poll := &Poll{
+		Title:       "Trash poll",
+		AdminUserID: adminUserID,
+		Status:      "setup",
+		Questions: []Question{
+			{Text: "Question 1", Type: "single-select", Options: []Option{{Text: "A"}, {Text: "B"}}},
+		},
+	}
+	if err := DB.Create(poll).Error; err != nil {
+		t.Fatalf("failed to create poll: %v", err)
+	}
+	q := poll.Questions[0]
+	if err := DB.Create(&Vote{QuestionID: q.ID, OptionID: q.Options[0].ID, VoterID: "voter"}).Error; err != nil {
+		t.Fatalf("failed to create vote: %v", err)
+	}
+	return poll
+}
+
+func countRows(t *testing.T, model interface{}, unscoped bool) int64 {
+	t.Helper()
+	var count int64
+	db := DB
+	if unscoped {
+		db = db.Unscoped()
+	}
+	if err := db.Model(model).Count(&count).Error; err != nil {
+		t.Fatalf("failed to count rows: %v", err)
+	}
+	return count
+}
+
+func TestSoftDeletePollDeletesWholeTree(t *testing.T) {
+	setupTestDB(t)
+	poll := createTestPoll(t, 1)
+
+	if err := SoftDeletePoll(poll.ID); err != nil {
+		t.Fatalf("SoftDeletePoll returned error: %v", err)
+	}
+
+	for name, model := range map[string]interface{}{"polls": &Poll{}, "questions": &Question{}, "options": &Option{}, "votes": &Vote{}} {
+		if n := countRows(t, model, false); n != 0 {
+			t.Errorf("expected no visible %s after delete, got %d", name, n)
+		}
+		if n := countRows(t, model, true); n == 0 {
+			t.Errorf("expected %s to be kept in the trash", name)
+		}
+	}
+
+	deleted, err := GetDeletedPolls(1)
+	if err != nil {
+		t.Fatalf("GetDeletedPolls returned error: %v", err)
+	}
+	if len(deleted) != 1 || deleted[0].ID != poll.ID {
+		t.Errorf("expected deleted poll %d in trash, got %v", poll.ID, deleted)
+	}
+	if deleted, _ := GetDeletedPolls(2); len(deleted) != 0 {
+		t.Errorf("expected other admin's trash to be empty, got %d polls", len(deleted))
+	}
+}
+
+func TestRestorePollRestoresOnlyTreeDeletedWithPoll(t *testing.T) {
+	setupTestDB(t)
+	poll := createTestPoll(t, 1)
+
+	// An option removed before the poll was deleted must stay deleted after restore
+	removed := poll.Questions[0].Options[1]
+	if err := DB.Delete(&removed).Error; err != nil {
+		t.Fatalf("failed to delete option: %v", err)
+	}
+
+	if err := SoftDeletePoll(poll.ID); err != nil {
+		t.Fatalf("SoftDeletePoll returned error: %v", err)
+	}
+	if err := RestorePoll(poll.ID); err != nil {
+		t.Fatalf("RestorePoll returned error: %v", err)
+	}
+
+	restored, err := GetPollAndDetailsForAdmin(poll.ID)
+	if err != nil {
+		t.Fatalf("restored poll not found: %v", err)
+	}
+	if len(restored.Questions) != 1 {
+		t.Fatalf("expected 1 restored question, got %d", len(restored.Questions))
+	}
+	if len(restored.Questions[0].Options) != 1 {
+		t.Errorf("expected 1 restored option, got %d", len(restored.Questions[0].Options))
+	}
+	if restored.Questions[0].Votes["A"] != 1 {
+		t.Errorf("expected restored vote for A, got %v", restored.Questions[0].Votes)
+	}
+}
+
+func TestPurgeDeletedPolls(t *testing.T) {
+	setupTestDB(t)
+	old := createTestPoll(t, 1)
+	recent := createTestPoll(t, 1)
+	live := createTestPoll(t, 1)
+
+	if err := SoftDeletePoll(old.ID); err != nil {
+		t.Fatalf("SoftDeletePoll returned error: %v", err)
+	}
+	if err := SoftDeletePoll(recent.ID); err != nil {
+		t.Fatalf("SoftDeletePoll returned error: %v", err)
+	}
+	DB.Unscoped().Model(&Poll{}).Where("id = ?", old.ID).Update("deleted_at", time.Now().Add(-48*time.Hour))
+
+	purged, err := PurgeDeletedPolls(time.Now().Add(-24 * time.Hour))
+	if err != nil {
+		t.Fatalf("PurgeDeletedPolls returned error: %v", err)
+	}
+	if purged != 1 {
+		t.Errorf("expected 1 purged poll, got %d", purged)
+	}
+
+	if n := countRows(t, &Poll{}, true); n != 2 {
+		t.Errorf("expected 2 polls left, got %d", n)
+	}
+	if n := countRows(t, &Vote{}, true); n != 2 {
+		t.Errorf("expected votes of purged poll to be removed, %d votes left", n)
+	}
+	if _, err := GetPollAndDetailsForAdmin(live.ID); err != nil {
+		t.Errorf("live poll should not be affected: %v", err)
+	}
+}
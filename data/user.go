@@ -1,6 +1,10 @@
 package data
 
 import (
+	"errors"
+	"fmt"
+
+	"github.com/aspcodenet/systementorlivepolls/utils"
 	"gorm.io/gorm"
 )
 
@@ -13,5 +17,138 @@ type AdminUser struct {
 	SecretKey2 string `gorm:"size:30"`
 	Active     bool
 	Polls      []Poll `gorm:"foreignKey:AdminUserID"`
+
+	// The login provider and its stable ID of the user, e.g. "github" and the GitHub user ID.
+	// Empty for users who have not logged in since it was added; they are found by email.
+	LoginProvider string `gorm:"size:20;index:idx_admin_login"`
+	LoginSubject  string `gorm:"size:255;index:idx_admin_login"`
+
+	// What the user may do on the admin pages, see Roles. Users without a role, like everyone
+	// who just logged in, may not use them.
+	Role string `gorm:"size:20"`
 	gorm.Model
 }
+
+// accessKeyPrefix makes API access keys easy to recognize in logs and config files.
+const accessKeyPrefix = "LPK"
+
+// GenerateAPIKey creates a new access/secret key pair in slot 1 or 2, replacing any key already there.
+// Having two slots lets a client switch to a new key before the old one is revoked.
+func GenerateAPIKey(user *AdminUser, slot int) (accessKey string, secretKey string, err error) {
+	if slot != 1 && slot != 2 {
+		return "", "", fmt.Errorf("invalid api key slot %d", slot)
+	}
+	random, err := utils.RandString(12)
+	if err != nil {
+		return "", "", err
+	}
+	accessKey = accessKeyPrefix + random
+	secretKey, err = utils.RandString(21) // 28 characters, fits the 30 character column
+	if err != nil {
+		return "", "", err
+	}
+
+	if slot == 1 {
+		user.AccessKey1, user.SecretKey1 = accessKey, secretKey
+	} else {
+		user.AccessKey2, user.SecretKey2 = accessKey, secretKey
+	}
+	if err := DB.Model(user).Select("AccessKey1", "SecretKey1", "AccessKey2", "SecretKey2").Updates(user).Error; err != nil {
+		return "", "", fmt.Errorf("failed to save api key: %w", err)
+	}
+	return accessKey, secretKey, nil
+}
+
+// RevokeAPIKey removes the key pair in slot 1 or 2.
+func RevokeAPIKey(user *AdminUser, slot int) error {
+	if slot != 1 && slot != 2 {
+		return fmt.Errorf("invalid api key slot %d", slot)
+	}
+	if slot == 1 {
+		user.AccessKey1, user.SecretKey1 = "", ""
+	} else {
+		user.AccessKey2, user.SecretKey2 = "", ""
+	}
+	if err := DB.Model(user).Select("AccessKey1", "SecretKey1", "AccessKey2", "SecretKey2").Updates(user).Error; err != nil {
+		return fmt.Errorf("failed to revoke api key: %w", err)
+	}
+	return nil
+}
+
+// FindAdminUserByAccessKey looks up the user owning an access key in either slot and returns
+// the secret belonging to that key.
+func FindAdminUserByAccessKey(accessKey string) (*AdminUser, string, error) {
+	if accessKey == "" {
+		return nil, "", gorm.ErrRecordNotFound
+	}
+	user := &AdminUser{}
+	if err := DB.Where("access_key1 = ? OR access_key2 = ?", accessKey, accessKey).First(user).Error; err != nil {
+		return nil, "", err
+	}
+	if user.AccessKey1 == accessKey {
+		return user, user.SecretKey1, nil
+	}
+	return user, user.SecretKey2, nil
+}
+
+// ErrEmailTaken is returned by LoginAdminUser when the email belongs to another login.
+var ErrEmailTaken = errors.New("the email address belongs to another account")
+
+// ErrEmailNotVerified is returned by LoginAdminUser for a first login with an email the provider
+// has not verified.
+var ErrEmailNotVerified = errors.New("the email address is not verified")
+
+// LoginAdminUser finds or creates the user logging in with a provider. Users are found by the
+// provider's ID of them first, so changing the email at the provider keeps their polls; the
+// stored email is updated to match when the provider has verified it. An empty subject falls
+// back to the email alone. Otherwise the email must be verified, or it is ErrEmailNotVerified:
+// the stored email is what admins, owners and shares are given to, so a provider that lets users
+// choose their email must not let them claim someone else's. A verified email only finds an
+// existing user that is not linked to another login, else it is ErrEmailTaken.
+func LoginAdminUser(provider, subject, email string, emailVerified bool) (*AdminUser, error) {
+	user := &AdminUser{}
+	if subject != "" {
+		err := DB.Where("login_provider = ? AND login_subject = ?", provider, subject).First(user).Error
+		if err == nil {
+			if user.Email != email && emailVerified {
+				var count int64
+				DB.Model(&AdminUser{}).Where("email = ? AND id <> ?", email, user.ID).Count(&count)
+				if count > 0 {
+					return nil, ErrEmailTaken
+				}
+				if err := DB.Model(user).Update("email", email).Error; err != nil {
+					return nil, err
+				}
+			}
+			return user, nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+	}
+
+	if !emailVerified {
+		return nil, ErrEmailNotVerified
+	}
+	err := DB.Where("email = ?", email).First(user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		user = &AdminUser{Email: email}
+		if subject != "" {
+			user.LoginProvider, user.LoginSubject = provider, subject
+		}
+		return user, DB.Create(user).Error
+	}
+	if err != nil {
+		return nil, err
+	}
+	if subject == "" {
+		return user, nil
+	}
+	if user.LoginSubject != "" {
+		// Linked to another login, at this provider or another one; never take over the user
+		return nil, ErrEmailTaken
+	}
+	// Link users who logged in before their provider ID was stored
+	user.LoginProvider, user.LoginSubject = provider, subject
+	return user, DB.Model(user).Select("LoginProvider", "LoginSubject").Updates(user).Error
+}
@@ -0,0 +1,96 @@
+package data
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestLoginAdminUser(t *testing.T) {
+	setupTestDB(t)
+
+	user, err := LoginAdminUser("github", "1001", "teacher@school.example", true)
+	if err != nil {
+		t.Fatalf("LoginAdminUser returned error: %v", err)
+	}
+	if user.ID == 0 || user.LoginProvider != "github" || user.LoginSubject != "1001" {
+		t.Fatalf("expected a new linked user, got %+v", user)
+	}
+
+	// The email changed at GitHub: same user, new email
+	again, err := LoginAdminUser("github", "1001", "teacher@newschool.example", true)
+	if err != nil || again.ID != user.ID || again.Email != "teacher@newschool.example" {
+		t.Fatalf("expected user %d with the new email, got %+v (err %v)", user.ID, again, err)
+	}
+	var stored AdminUser
+	DB.First(&stored, user.ID)
+	if stored.Email != "teacher@newschool.example" {
+		t.Errorf("the new email should be saved, got %q", stored.Email)
+	}
+
+	// Another GitHub account with the old user's email must not take it over
+	if _, err := LoginAdminUser("github", "2002", "teacher@newschool.example", true); !errors.Is(err, ErrEmailTaken) {
+		t.Errorf("expected ErrEmailTaken, got %v", err)
+	}
+	// Nor may another provider, even if it has verified the email
+	if _, err := LoginAdminUser("oidc", "abc", "teacher@newschool.example", true); !errors.Is(err, ErrEmailTaken) {
+		t.Errorf("expected ErrEmailTaken for a user linked to another provider, got %v", err)
+	}
+}
+
+func TestLoginAdminUserWithUnverifiedEmail(t *testing.T) {
+	setupTestDB(t)
+	existing := AdminUser{Email: "teacher@school.example"}
+	DB.Create(&existing)
+
+	// An unverified email does not find, or link, the existing user
+	if _, err := LoginAdminUser("oidc", "abc", "teacher@school.example", false); !errors.Is(err, ErrEmailNotVerified) {
+		t.Fatalf("expected ErrEmailNotVerified, got %v", err)
+	}
+	var stored AdminUser
+	DB.First(&stored, existing.ID)
+	if stored.LoginSubject != "" {
+		t.Errorf("the existing user should not be linked, got %+v", stored)
+	}
+
+	// Nor does it create a user that owns the email, which could then be given roles and polls
+	if _, err := LoginAdminUser("oidc", "abc", "new@school.example", false); !errors.Is(err, ErrEmailNotVerified) {
+		t.Fatalf("expected ErrEmailNotVerified, got %v", err)
+	}
+	if _, err := GetAdminUserByEmail("new@school.example"); !errors.Is(err, ErrNoSuchAdmin) {
+		t.Errorf("no user should have the unverified email, got %v", err)
+	}
+	var count int64
+	DB.Model(&AdminUser{}).Count(&count)
+	if count != 1 {
+		t.Errorf("expected no new user, got %d users", count)
+	}
+
+	// A user found by subject logs in, but an unverified email does not replace the stored one
+	user, err := LoginAdminUser("oidc", "abc", "new@school.example", true)
+	if err != nil {
+		t.Fatalf("LoginAdminUser = %v", err)
+	}
+	again, err := LoginAdminUser("oidc", "abc", "changed@school.example", false)
+	if err != nil || again.ID != user.ID || again.Email != "new@school.example" {
+		t.Errorf("expected user %d with the old email, got %+v (err %v)", user.ID, again, err)
+	}
+}
+
+func TestLoginAdminUserLinksExistingUser(t *testing.T) {
+	setupTestDB(t)
+	existing := AdminUser{Email: "old@school.example"}
+	DB.Create(&existing)
+
+	if user, err := LoginAdminUser("dev", "", "old@school.example", true); err != nil || user.ID != existing.ID || user.LoginSubject != "" {
+		t.Fatalf("a login without subject should find the user by email, got %+v (err %v)", user, err)
+	}
+	user, err := LoginAdminUser("github", "1001", "old@school.example", true)
+	if err != nil || user.ID != existing.ID {
+		t.Fatalf("expected user %d, got %+v (err %v)", existing.ID, user, err)
+	}
+	var stored AdminUser
+	DB.First(&stored, existing.ID)
+	if stored.LoginProvider != "github" || stored.LoginSubject != "1001" {
+		t.Errorf("the existing user should be linked to the GitHub account, got %+v", stored)
+	}
+}
@@ -0,0 +1,62 @@
+package data
+
+// When participants see the votes of a question. A question with no setting of its own uses the
+// setting of its poll, and a poll with no setting uses ResultsAfterReveal.
+const (
+	ResultsNever       = "never"        // Only the admin and the presenter view see votes
+	ResultsAfterReveal = "after_reveal" // Votes are shown after show_results, and when the poll is finished
+	ResultsLive        = "live"         // Votes are shown while voting is open
+)
+
+// ResultsVisibilities lists the valid result visibility settings.
+var ResultsVisibilities = []string{ResultsNever, ResultsAfterReveal, ResultsLive}
+
+// ValidResultsVisibility reports whether v is a result visibility setting. Empty means the
+// default and is valid too.
+func ValidResultsVisibility(v string) bool {
+	if v == "" {
+		return true
+	}
+	for _, valid := range ResultsVisibilities {
+		if v == valid {
+			return true
+		}
+	}
+	return false
+}
+
+// EffectiveResultsVisibility returns the visibility of a question of poll p, after falling back
+// to the poll's setting and the default.
+func (q *Question) EffectiveResultsVisibility(p *Poll) string {
+	switch {
+	case q.ResultsVisibility != "":
+		return q.ResultsVisibility
+	case p.ResultsVisibility != "":
+		return p.ResultsVisibility
+	}
+	return ResultsAfterReveal
+}
+
+// ParticipantsSeeVotes reports whether participants may see the votes of question i in the
+// poll's current state.
+func (p *Poll) ParticipantsSeeVotes(i int) bool {
+	if i < 0 || i >= len(p.Questions) {
+		return false
+	}
+	switch p.Questions[i].EffectiveResultsVisibility(p) {
+	case ResultsLive:
+		return true
+	case ResultsAfterReveal:
+		// Questions skipped with next stay hidden until the poll is finished
+		return p.Status == "finished" || p.Questions[i].ResultsShown || (p.Status == "results" && i == p.CurrentQuestionIndex)
+	}
+	return false
+}
+
+// MarkResultsShown records that the results of question i were shown, so participants keep
+// seeing them while the poll moves on to the next questions.
+func (p *Poll) MarkResultsShown(i int) error {
+	q := &p.Questions[i]
+	q.ResultsShown = true
+	return DB.Model(q).Update("results_shown", true).Error
+}
@@ -0,0 +1,70 @@
+package data
+
+import "testing"
+
+func TestParticipantsSeeVotes(t *testing.T) {
+	poll := &Poll{CurrentQuestionIndex: 1, Questions: []Question{{}, {}, {}}}
+	cases := []struct {
+		poll, question string
+		status         string
+		index          int
+		want           bool
+	}{
+		{"", "", "active", 1, false},
+		{"", "", "results", 1, true},
+		{"", "", "results", 0, false}, // Skipped without showing its results
+		{"", "", "results", 2, false}, // Not asked yet
+		{"", "", "finished", 2, true},
+		{ResultsLive, "", "active", 1, true},
+		{ResultsLive, ResultsAfterReveal, "active", 1, false},
+		{ResultsNever, "", "results", 1, false},
+		{ResultsNever, "", "finished", 1, false},
+		{ResultsNever, ResultsLive, "active", 1, true},
+		{ResultsAfterReveal, ResultsNever, "finished", 1, false},
+		{"", "", "results", 5, false},
+	}
+	for _, tc := range cases {
+		poll.ResultsVisibility = tc.poll
+		poll.Status = tc.status
+		for i := range poll.Questions {
+			poll.Questions[i].ResultsVisibility = tc.question
+		}
+		if got := poll.ParticipantsSeeVotes(tc.index); got != tc.want {
+			t.Errorf("poll %q, question %q, status %s, question %d: got %v, want %v", tc.poll, tc.question, tc.status, tc.index, got, tc.want)
+		}
+	}
+}
+
+func TestParticipantsSeeShownResults(t *testing.T) {
+	setupTestDB(t)
+	poll := &Poll{Status: "active", CurrentQuestionIndex: 0, Questions: []Question{{Text: "Q1"}, {Text: "Q2"}, {Text: "Q3"}}}
+	DB.Create(poll)
+
+	if err := poll.MarkResultsShown(0); err != nil {
+		t.Fatalf("MarkResultsShown = %v", err)
+	}
+	// The presenter moves on to question 2, skips it, and shows the results of question 3
+	poll.CurrentQuestionIndex, poll.Status = 2, "results"
+	for i, want := range []bool{true, false, true} {
+		if got := poll.ParticipantsSeeVotes(i); got != want {
+			t.Errorf("question %d: got %v, want %v", i+1, got, want)
+		}
+	}
+
+	var stored Poll
+	DB.Preload("Questions").First(&stored, poll.ID)
+	if !stored.Questions[0].ResultsShown || stored.Questions[1].ResultsShown {
+		t.Errorf("expected only question 1 to be stored as shown, got %+v", stored.Questions)
+	}
+}
+
+func TestValidResultsVisibility(t *testing.T) {
+	for _, v := range []string{"", ResultsNever, ResultsAfterReveal, ResultsLive} {
+		if !ValidResultsVisibility(v) {
+			t.Errorf("%q should be valid", v)
+		}
+	}
+	if ValidResultsVisibility("always") {
+		t.Error(`"always" should not be valid`)
+	}
+}
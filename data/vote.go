@@ -0,0 +1,87 @@
+package data
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Longest voter ID and idempotency key a client may send with a vote.
+const (
+	MaxVoterIDLength        = 64
+	MaxIdempotencyKeyLength = 64
+)
+
+// VoteSubmission remembers a vote submitted with an idempotency key, so a retried
+// submission is recognised and not counted twice.
+type VoteSubmission struct {
+	ID             uint `gorm:"primarykey"`
+	CreatedAt      time.Time
+	QuestionID     uint   `gorm:"index"`
+	VoterID        string `gorm:"size:64;uniqueIndex:idx_vote_submission_key"`
+	IdempotencyKey string `gorm:"size:64;uniqueIndex:idx_vote_submission_key"`
+}
+
+// RecordVote stores the votes of a voter for a question. With replace set, the voter's earlier
+// votes for the question are removed first, as for single-select questions. If idempotencyKey is
+// not empty and the voter already submitted a vote with it, nothing is stored and duplicate is true.
+func RecordVote(questionID uint, voterID string, optionIDs []uint, replace bool, runID uint, idempotencyKey string) (duplicate bool, err error) {
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		if idempotencyKey != "" {
+			var count int64
+			if err := tx.Model(&VoteSubmission{}).Where("voter_id = ? AND idempotency_key = ?", voterID, idempotencyKey).Count(&count).Error; err != nil {
+				return fmt.Errorf("failed to look up vote submission: %w", err)
+			}
+			if count > 0 {
+				duplicate = true
+				return nil
+			}
+			submission := &VoteSubmission{QuestionID: questionID, VoterID: voterID, IdempotencyKey: idempotencyKey}
+			if err := tx.Create(submission).Error; err != nil {
+				return fmt.Errorf("failed to record vote submission: %w", err)
+			}
+		}
+
+		if replace {
+			if err := tx.Where("question_id = ? AND voter_id = ?", questionID, voterID).Delete(&Vote{}).Error; err != nil {
+				return fmt.Errorf("failed to delete previous votes: %w", err)
+			}
+		}
+		for _, optionID := range optionIDs {
+			vote := &Vote{QuestionID: questionID, OptionID: optionID, VoterID: voterID, PollRunID: runID}
+			if err := tx.Create(vote).Error; err != nil {
+				return fmt.Errorf("failed to save vote: %w", err)
+			}
+		}
+		return nil
+	})
+	return duplicate, err
+}
+
+// SubmissionVoterID returns the voter ID a vote for the question was stored under with
+// idempotencyKey, or "" if there is none. It recognises a retried first vote, sent before the
+// client knew the voter ID the server generated for it.
+func SubmissionVoterID(questionID uint, idempotencyKey string) (string, error) {
+	var submissions []VoteSubmission
+	err := DB.Where("question_id = ? AND idempotency_key = ?", questionID, idempotencyKey).Limit(1).Find(&submissions).Error
+	if err != nil {
+		return "", fmt.Errorf("failed to look up vote submission: %w", err)
+	}
+	if len(submissions) == 0 {
+		return "", nil
+	}
+	return submissions[0].VoterID, nil
+}
+
+// GetVoterSelection returns the IDs of the options a voter has voted for on a question, in order.
+func GetVoterSelection(questionID uint, voterID string) ([]uint, error) {
+	optionIDs := []uint{}
+	err := DB.Model(&Vote{}).Distinct("option_id").
+		Where("question_id = ? AND voter_id = ?", questionID, voterID).
+		Order("option_id").Pluck("option_id", &optionIDs).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get selection of voter %s: %w", voterID, err)
+	}
+	return optionIDs, nil
+}
@@ -0,0 +1,63 @@
+package data
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRecordVoteIdempotencyKey(t *testing.T) {
+	setupTestDB(t)
+	poll := createTestPoll(t, 1)
+	q := poll.Questions[0]
+	a, b := q.Options[0].ID, q.Options[1].ID
+
+	if duplicate, err := RecordVote(q.ID, "alice", []uint{a}, true, 0, "key-1"); err != nil || duplicate {
+		t.Fatalf("first submission: duplicate=%v err=%v", duplicate, err)
+	}
+	if duplicate, err := RecordVote(q.ID, "alice", []uint{a}, true, 0, "key-1"); err != nil || !duplicate {
+		t.Fatalf("expected retry with the same key to be a duplicate: duplicate=%v err=%v", duplicate, err)
+	}
+	// The same key from another voter is a different submission
+	if duplicate, err := RecordVote(q.ID, "bob", []uint{b}, true, 0, "key-1"); err != nil || duplicate {
+		t.Fatalf("other voter's submission: duplicate=%v err=%v", duplicate, err)
+	}
+
+	selection, err := GetVoterSelection(q.ID, "alice")
+	if err != nil {
+		t.Fatalf("GetVoterSelection returned error: %v", err)
+	}
+	if !reflect.DeepEqual(selection, []uint{a}) {
+		t.Errorf("expected selection [%d], got %v", a, selection)
+	}
+
+	// A new key replaces the single-select answer
+	if _, err := RecordVote(q.ID, "alice", []uint{b}, true, 0, "key-2"); err != nil {
+		t.Fatalf("second submission returned error: %v", err)
+	}
+	if selection, _ := GetVoterSelection(q.ID, "alice"); !reflect.DeepEqual(selection, []uint{b}) {
+		t.Errorf("expected selection [%d] after changing the answer, got %v", b, selection)
+	}
+
+	// createTestPoll adds one vote, alice and bob have one each
+	if n := countRows(t, &Vote{}, false); n != 3 {
+		t.Errorf("expected 3 votes, got %d", n)
+	}
+}
+
+func TestRecordVoteWithoutKeyIsNotDeduplicated(t *testing.T) {
+	setupTestDB(t)
+	poll := createTestPoll(t, 1)
+	q := poll.Questions[0]
+
+	for i := 0; i < 2; i++ {
+		if duplicate, err := RecordVote(q.ID, "carol", []uint{q.Options[0].ID}, false, 0, ""); err != nil || duplicate {
+			t.Fatalf("submission %d: duplicate=%v err=%v", i, duplicate, err)
+		}
+	}
+	if n := countRows(t, &VoteSubmission{}, false); n != 0 {
+		t.Errorf("expected no submissions to be remembered without a key, got %d", n)
+	}
+	if n := countRows(t, &Vote{}, false); n != 3 {
+		t.Errorf("expected 3 votes, got %d", n)
+	}
+}
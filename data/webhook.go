@@ -0,0 +1,158 @@
+package data
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aspcodenet/systementorlivepolls/utils"
+	"gorm.io/gorm"
+)
+
+// Webhook is a URL that is called when something happens to the polls of an admin.
+// With PollID 0 it applies to all the admin's polls. Events holds the subscribed event
+// types separated by commas; empty means all events.
+type Webhook struct {
+	gorm.Model
+	AdminUserID uint   `gorm:"index"`
+	PollID      uint   `gorm:"index"`
+	URL         string `gorm:"size:500"`
+	Secret      string `gorm:"size:50"` // Key for the HMAC signature of each delivery
+	Events      string `gorm:"size:500"`
+	Active      bool
+	Poll        *Poll `gorm:"foreignKey:PollID;references:ID"`
+}
+
+// WebhookDelivery is one attempt to deliver an event to a webhook.
+type WebhookDelivery struct {
+	ID         uint `gorm:"primarykey"`
+	CreatedAt  time.Time
+	WebhookID  uint   `gorm:"index"`
+	EventID    string `gorm:"size:50;index"` // The same for all attempts to deliver one event
+	Event      string `gorm:"size:50"`
+	Attempt    int
+	StatusCode int    // 0 if no response was received
+	Error      string `gorm:"size:500"`
+	Success    bool
+	DurationMs int64
+	Payload    string `gorm:"type:text"`
+}
+
+// Subscribes reports whether the webhook wants events of the given type.
+func (w *Webhook) Subscribes(event string) bool {
+	if w.Events == "" {
+		return true
+	}
+	for _, e := range strings.Split(w.Events, ",") {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// EventList returns the subscribed event types, empty for all events.
+func (w *Webhook) EventList() []string {
+	if w.Events == "" {
+		return nil
+	}
+	return strings.Split(w.Events, ",")
+}
+
+// CreateWebhook stores a new active webhook with a generated secret.
+func CreateWebhook(adminUserID, pollID uint, url string, events []string) (*Webhook, error) {
+	secret, err := utils.RandString(24)
+	if err != nil {
+		return nil, err
+	}
+	hook := &Webhook{
+		AdminUserID: adminUserID,
+		PollID:      pollID,
+		URL:         url,
+		Secret:      "whsec_" + secret,
+		Events:      strings.Join(events, ","),
+		Active:      true,
+	}
+	if err := DB.Create(hook).Error; err != nil {
+		return nil, fmt.Errorf("failed to create webhook: %w", err)
+	}
+	return hook, nil
+}
+
+// GetWebhooksForAdmin returns the webhooks of an admin with their polls, oldest first.
+func GetWebhooksForAdmin(adminUserID uint) ([]*Webhook, error) {
+	hooks := []*Webhook{}
+	if err := DB.Preload("Poll").Where("admin_user_id = ?", adminUserID).Order("id").Find(&hooks).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve webhooks: %w", err)
+	}
+	return hooks, nil
+}
+
+// GetWebhook returns a webhook with its poll.
+func GetWebhook(webhookID uint) (*Webhook, error) {
+	hook := &Webhook{}
+	if err := DB.Preload("Poll").First(hook, webhookID).Error; err != nil {
+		return nil, err
+	}
+	return hook, nil
+}
+
+// GetWebhooksForEvent returns the active webhooks that subscribe to an event of a poll: those
+// set up for the poll itself and those of its owner for all polls. Only webhooks of admins who
+// may still edit the poll are returned, so a transfer, an unshare or leaving the workspace stops
+// the deliveries to the admin who lost access.
+func GetWebhooksForEvent(p *Poll, event string) ([]*Webhook, error) {
+	candidates := []*Webhook{}
+	err := DB.Where("active = ? AND (poll_id = ? OR (poll_id = 0 AND admin_user_id = ?))", true, p.ID, p.AdminUserID).
+		Order("id").Find(&candidates).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve webhooks: %w", err)
+	}
+	canEdit := map[uint]bool{}
+	hooks := []*Webhook{}
+	for _, h := range candidates {
+		if !h.Subscribes(event) {
+			continue
+		}
+		allowed, ok := canEdit[h.AdminUserID]
+		if !ok {
+			var adminUser AdminUser
+			allowed = DB.First(&adminUser, h.AdminUserID).Error == nil && adminUser.IsAdmin() && CanAccessPoll(p, &adminUser, AccessEdit)
+			canEdit[h.AdminUserID] = allowed
+		}
+		if allowed {
+			hooks = append(hooks, h)
+		}
+	}
+	return hooks, nil
+}
+
+// RecordWebhookDelivery stores the outcome of a delivery attempt.
+func RecordWebhookDelivery(delivery *WebhookDelivery) error {
+	if err := DB.Create(delivery).Error; err != nil {
+		return fmt.Errorf("failed to record webhook delivery: %w", err)
+	}
+	return nil
+}
+
+// GetWebhookDeliveries returns the latest delivery attempts of a webhook, newest first.
+func GetWebhookDeliveries(webhookID uint, limit int) ([]*WebhookDelivery, error) {
+	deliveries := []*WebhookDelivery{}
+	if err := DB.Where("webhook_id = ?", webhookID).Order("id desc").Limit(limit).Find(&deliveries).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve webhook deliveries: %w", err)
+	}
+	return deliveries, nil
+}
+
+// DeleteWebhook removes a webhook and its delivery log.
+func DeleteWebhook(webhookID uint) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("webhook_id = ?", webhookID).Delete(&WebhookDelivery{}).Error; err != nil {
+			return fmt.Errorf("failed to delete webhook deliveries: %w", err)
+		}
+		if err := tx.Unscoped().Delete(&Webhook{}, webhookID).Error; err != nil {
+			return fmt.Errorf("failed to delete webhook: %w", err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,186 @@
+package data
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Workspace is a team, e.g. the teachers of a course, whose members share the polls in it.
+type Workspace struct {
+	gorm.Model
+	Name    string            `gorm:"size:100"`
+	Members []WorkspaceMember `gorm:"foreignKey:WorkspaceID"`
+}
+
+// WorkspaceMember gives an admin access to all polls of a workspace. Members with AccessOwner
+// also manage the members.
+type WorkspaceMember struct {
+	ID          uint `gorm:"primarykey"`
+	CreatedAt   time.Time
+	WorkspaceID uint       `gorm:"uniqueIndex:idx_workspace_member"`
+	AdminUserID uint       `gorm:"uniqueIndex:idx_workspace_member;index"`
+	Access      string     `gorm:"size:10"`
+	AdminUser   *AdminUser `gorm:"foreignKey:AdminUserID"`
+}
+
+// PollShare gives an admin access to a single poll, AccessEdit or AccessRun.
+type PollShare struct {
+	ID          uint `gorm:"primarykey"`
+	CreatedAt   time.Time
+	PollID      uint       `gorm:"uniqueIndex:idx_poll_share"`
+	AdminUserID uint       `gorm:"uniqueIndex:idx_poll_share;index"`
+	Access      string     `gorm:"size:10"`
+	AdminUser   *AdminUser `gorm:"foreignKey:AdminUserID"`
+}
+
+// ErrLastWorkspaceOwner is returned when a change would leave a workspace without an owner.
+var ErrLastWorkspaceOwner = errors.New("a workspace needs at least one owner")
+
+// WorkspaceWithAccess is a workspace and what the admin who asked for it may do there.
+type WorkspaceWithAccess struct {
+	Workspace
+	Access string
+}
+
+// CreateWorkspace creates a workspace with creator as its owner.
+func CreateWorkspace(name string, creator *AdminUser) (*Workspace, error) {
+	workspace := &Workspace{Name: name, Members: []WorkspaceMember{{AdminUserID: creator.ID, Access: AccessOwner}}}
+	if err := DB.Create(workspace).Error; err != nil {
+		return nil, fmt.Errorf("failed to create workspace: %w", err)
+	}
+	return workspace, nil
+}
+
+// GetWorkspace returns a workspace with its members and their admin users.
+func GetWorkspace(workspaceID uint) (*Workspace, error) {
+	workspace := &Workspace{}
+	if err := DB.Preload("Members.AdminUser").First(workspace, workspaceID).Error; err != nil {
+		return nil, err
+	}
+	return workspace, nil
+}
+
+// GetWorkspacesForAdmin returns the workspaces an admin is a member of, by name.
+func GetWorkspacesForAdmin(adminUserID uint) ([]WorkspaceWithAccess, error) {
+	workspaces := []WorkspaceWithAccess{}
+	err := DB.Model(&Workspace{}).
+		Select("workspaces.*, workspace_members.access").
+		Joins("JOIN workspace_members ON workspace_members.workspace_id = workspaces.id").
+		Where("workspace_members.admin_user_id = ?", adminUserID).
+		Order("workspaces.name").
+		Find(&workspaces).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve workspaces: %w", err)
+	}
+	return workspaces, nil
+}
+
+// WorkspaceAccess returns what an admin may do with the polls of a workspace, "" if they are
+// not a member.
+func WorkspaceAccess(workspaceID, adminUserID uint) string {
+	var member WorkspaceMember
+	if err := DB.First(&member, "workspace_id = ? AND admin_user_id = ?", workspaceID, adminUserID).Error; err != nil {
+		return ""
+	}
+	return member.Access
+}
+
+// SetWorkspaceMember adds an admin to a workspace or changes their access. An empty access
+// removes them. The last owner can neither be removed nor lose the owner access.
+func SetWorkspaceMember(workspaceID, adminUserID uint, access string) error {
+	if access != "" && !ValidAccess(access) {
+		return fmt.Errorf("invalid access %q", access)
+	}
+	return DB.Transaction(func(tx *gorm.DB) error {
+		var member WorkspaceMember
+		err := tx.First(&member, "workspace_id = ? AND admin_user_id = ?", workspaceID, adminUserID).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		if member.Access == AccessOwner && access != AccessOwner {
+			var owners int64
+			tx.Model(&WorkspaceMember{}).Where("workspace_id = ? AND access = ?", workspaceID, AccessOwner).Count(&owners)
+			if owners <= 1 {
+				return ErrLastWorkspaceOwner
+			}
+		}
+		switch {
+		case access == "":
+			return tx.Where("workspace_id = ? AND admin_user_id = ?", workspaceID, adminUserID).Delete(&WorkspaceMember{}).Error
+		case member.ID == 0:
+			return tx.Create(&WorkspaceMember{WorkspaceID: workspaceID, AdminUserID: adminUserID, Access: access}).Error
+		default:
+			return tx.Model(&member).Update("access", access).Error
+		}
+	})
+}
+
+// MovePoll moves a poll into a workspace, or with a nil workspaceID out of it to be a personal
+// poll of adminUserID.
+func MovePoll(p *Poll, workspaceID *uint, adminUserID uint) error {
+	p.WorkspaceID = workspaceID
+	if workspaceID == nil {
+		p.AdminUserID = int(adminUserID)
+	}
+	return DB.Model(p).Select("WorkspaceID", "AdminUserID").Updates(p).Error
+}
+
+// GetPollShares returns whom a poll is shared with, in the order it was shared.
+func GetPollShares(pollID uint) ([]*PollShare, error) {
+	shares := []*PollShare{}
+	err := DB.Preload("AdminUser").Where("poll_id = ?", pollID).Order("id").Find(&shares).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve poll shares: %w", err)
+	}
+	return shares, nil
+}
+
+// SharePoll gives an admin AccessEdit or AccessRun to a poll. An empty access stops sharing it.
+func SharePoll(pollID, adminUserID uint, access string) error {
+	if access != "" && access != AccessEdit && access != AccessRun {
+		return fmt.Errorf("invalid access %q", access)
+	}
+	if err := DB.Where("poll_id = ? AND admin_user_id = ?", pollID, adminUserID).Delete(&PollShare{}).Error; err != nil || access == "" {
+		return err
+	}
+	return DB.Create(&PollShare{PollID: pollID, AdminUserID: adminUserID, Access: access}).Error
+}
+
+// TransferPoll makes another admin the owner of a personal poll. The previous owner keeps no access.
+func TransferPoll(p *Poll, toAdminUserID uint) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(p).Update("admin_user_id", toAdminUserID).Error; err != nil {
+			return err
+		}
+		p.AdminUserID = int(toAdminUserID)
+		return tx.Where("poll_id = ? AND admin_user_id = ?", p.ID, toAdminUserID).Delete(&PollShare{}).Error
+	})
+}
+
+// TransferAdminPolls gives all personal polls of an admin who leaves, including those in the
+// trash, and their webhooks to another admin. Polls in workspaces stay with the workspace.
+// It returns the number of polls transferred.
+func TransferAdminPolls(fromAdminUserID, toAdminUserID uint) (int, error) {
+	var transferred int64
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var pollIDs []uint
+		if err := tx.Unscoped().Model(&Poll{}).Where("admin_user_id = ? AND workspace_id IS NULL", fromAdminUserID).Pluck("id", &pollIDs).Error; err != nil {
+			return err
+		}
+		if len(pollIDs) > 0 {
+			result := tx.Unscoped().Model(&Poll{}).Where("id IN ?", pollIDs).Update("admin_user_id", toAdminUserID)
+			if result.Error != nil {
+				return result.Error
+			}
+			transferred = result.RowsAffected
+			if err := tx.Where("poll_id IN ? AND admin_user_id = ?", pollIDs, toAdminUserID).Delete(&PollShare{}).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(&Webhook{}).Where("admin_user_id = ?", fromAdminUserID).Update("admin_user_id", toAdminUserID).Error
+	})
+	return int(transferred), err
+}
@@ -0,0 +1,156 @@
+package data
+
+import (
+	"errors"
+	"testing"
+)
+
+func createWorkspaceTestUsers(t *testing.T) (alice, bob, carol *AdminUser) {
+	t.Helper()
+	alice = &AdminUser{Email: "alice@example.com", Role: RoleAdmin, Active: true}
+	bob = &AdminUser{Email: "bob@example.com", Role: RoleAdmin, Active: true}
+	carol = &AdminUser{Email: "carol@example.com", Role: RoleAdmin, Active: true}
+	for _, u := range []*AdminUser{alice, bob, carol} {
+		if err := DB.Create(u).Error; err != nil {
+			t.Fatalf("failed to create user: %v", err)
+		}
+	}
+	return alice, bob, carol
+}
+
+func TestPollAccess(t *testing.T) {
+	setupTestDB(t)
+	alice, bob, carol := createWorkspaceTestUsers(t)
+	poll := &Poll{Title: "Personal", AdminUserID: int(alice.ID), InviteID: "personal"}
+	DB.Create(poll)
+
+	if got := PollAccess(poll, alice); got != AccessOwner {
+		t.Errorf("the owner of a personal poll: expected owner, got %q", got)
+	}
+	if CanAccessPoll(poll, bob, AccessRun) {
+		t.Error("a personal poll should not be accessible to others")
+	}
+	if CanAccessPoll(nil, alice, AccessRun) {
+		t.Error("a nil poll should not be accessible")
+	}
+
+	if err := SharePoll(poll.ID, bob.ID, AccessRun); err != nil {
+		t.Fatalf("SharePoll returned error: %v", err)
+	}
+	if !CanAccessPoll(poll, bob, AccessRun) || CanAccessPoll(poll, bob, AccessEdit) {
+		t.Errorf("a poll shared to run: expected run access, got %q", PollAccess(poll, bob))
+	}
+	if err := SharePoll(poll.ID, bob.ID, AccessOwner); err == nil {
+		t.Error("sharing a poll as owner should fail")
+	}
+
+	workspace, err := CreateWorkspace("Teachers", bob)
+	if err != nil {
+		t.Fatalf("CreateWorkspace returned error: %v", err)
+	}
+	if err := SetWorkspaceMember(workspace.ID, carol.ID, AccessEdit); err != nil {
+		t.Fatalf("SetWorkspaceMember returned error: %v", err)
+	}
+	if err := MovePoll(poll, &workspace.ID, alice.ID); err != nil {
+		t.Fatalf("MovePoll returned error: %v", err)
+	}
+	if got := PollAccess(poll, alice); got != "" {
+		t.Errorf("the creator of a poll moved to a workspace they are not in: expected no access, got %q", got)
+	}
+	if got := PollAccess(poll, bob); got != AccessOwner {
+		t.Errorf("the workspace owner: expected owner, got %q", got)
+	}
+	if got := PollAccess(poll, carol); got != AccessEdit {
+		t.Errorf("a workspace member: expected edit, got %q", got)
+	}
+
+	polls, err := GetAccessiblePolls(carol, AccessEdit)
+	if err != nil || len(polls) != 1 || polls[0].ID != poll.ID {
+		t.Errorf("expected carol to edit the workspace poll, got %v, %v", polls, err)
+	}
+	if polls, _ := GetAccessiblePolls(alice, AccessRun); len(polls) != 0 {
+		t.Errorf("expected alice to have no polls, got %d", len(polls))
+	}
+}
+
+func TestCopyPollFor(t *testing.T) {
+	setupTestDB(t)
+	alice, bob, _ := createWorkspaceTestUsers(t)
+	workspace, _ := CreateWorkspace("Teachers", alice)
+	SetWorkspaceMember(workspace.ID, bob.ID, AccessRun)
+	poll := &Poll{Title: "Shared", AdminUserID: int(alice.ID), WorkspaceID: &workspace.ID}
+
+	if c := CopyPollFor(poll, alice); c.WorkspaceID == nil || *c.WorkspaceID != workspace.ID {
+		t.Error("a copy by a member who may edit should stay in the workspace")
+	}
+	c := CopyPollFor(poll, bob)
+	if c.WorkspaceID != nil || c.AdminUserID != int(bob.ID) {
+		t.Errorf("a copy by a member who may only run: expected a personal poll of bob, got %+v", c)
+	}
+}
+
+func TestWorkspaceKeepsAnOwner(t *testing.T) {
+	setupTestDB(t)
+	alice, bob, _ := createWorkspaceTestUsers(t)
+	workspace, _ := CreateWorkspace("Teachers", alice)
+
+	if err := SetWorkspaceMember(workspace.ID, alice.ID, AccessEdit); !errors.Is(err, ErrLastWorkspaceOwner) {
+		t.Errorf("demoting the last owner: expected ErrLastWorkspaceOwner, got %v", err)
+	}
+	if err := SetWorkspaceMember(workspace.ID, alice.ID, ""); !errors.Is(err, ErrLastWorkspaceOwner) {
+		t.Errorf("removing the last owner: expected ErrLastWorkspaceOwner, got %v", err)
+	}
+	if err := SetWorkspaceMember(workspace.ID, bob.ID, AccessOwner); err != nil {
+		t.Fatalf("SetWorkspaceMember returned error: %v", err)
+	}
+	if err := SetWorkspaceMember(workspace.ID, alice.ID, ""); err != nil {
+		t.Errorf("removing an owner when there is another: %v", err)
+	}
+	if got := WorkspaceAccess(workspace.ID, alice.ID); got != "" {
+		t.Errorf("expected alice to be removed, got %q", got)
+	}
+	workspaces, _ := GetWorkspacesForAdmin(bob.ID)
+	if len(workspaces) != 1 || workspaces[0].Name != "Teachers" || workspaces[0].Access != AccessOwner {
+		t.Errorf("expected bob to own Teachers, got %+v", workspaces)
+	}
+}
+
+func TestTransferAdminPolls(t *testing.T) {
+	setupTestDB(t)
+	alice, bob, _ := createWorkspaceTestUsers(t)
+	workspace, _ := CreateWorkspace("Teachers", alice)
+	personal := &Poll{Title: "Personal", AdminUserID: int(alice.ID), InviteID: "personal"}
+	trashed := &Poll{Title: "Trashed", AdminUserID: int(alice.ID), InviteID: "trashed"}
+	shared := &Poll{Title: "Workspace", AdminUserID: int(alice.ID), InviteID: "workspace", WorkspaceID: &workspace.ID}
+	for _, p := range []*Poll{personal, trashed, shared} {
+		DB.Create(p)
+	}
+	SoftDeletePoll(trashed.ID)
+	SharePoll(personal.ID, bob.ID, AccessRun)
+	CreateWebhook(alice.ID, 0, "https://example.com/hook", nil)
+
+	count, err := TransferAdminPolls(alice.ID, bob.ID)
+	if err != nil {
+		t.Fatalf("TransferAdminPolls returned error: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("expected the personal and trashed polls to be transferred, got %d", count)
+	}
+	DB.First(personal, personal.ID)
+	if PollAccess(personal, bob) != AccessOwner || PollAccess(personal, alice) != "" {
+		t.Error("expected bob to own the personal poll and alice to lose it")
+	}
+	if shares, _ := GetPollShares(personal.ID); len(shares) != 0 {
+		t.Errorf("expected the share with the new owner to be removed, got %d", len(shares))
+	}
+	if deleted, _ := GetDeletedPolls(bob.ID); len(deleted) != 1 {
+		t.Errorf("expected the trashed poll in bob's trash, got %d", len(deleted))
+	}
+	DB.First(shared, shared.ID)
+	if shared.AdminUserID != int(alice.ID) {
+		t.Error("a workspace poll should stay with the workspace")
+	}
+	if hooks, _ := GetWebhooksForAdmin(bob.ID); len(hooks) != 1 {
+		t.Errorf("expected the webhook to be transferred, got %d", len(hooks))
+	}
+}
@@ -0,0 +1,14 @@
+// Package docs embeds the machine-readable descriptions of the LivePolls protocols:
+// an OpenAPI document for the HTTP endpoints and an AsyncAPI document for the WebSocket messages.
+// Tests in this package check both documents against the Go types so they cannot drift.
+package docs
+
+import (
+	_ "embed"
+)
+
+//go:embed openapi.json
+var OpenAPI []byte
+
+//go:embed asyncapi.json
+var AsyncAPI []byte
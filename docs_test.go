@@ -0,0 +1,242 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aspcodenet/systementorlivepolls/data"
+	"github.com/aspcodenet/systementorlivepolls/docs"
+	"github.com/aspcodenet/systementorlivepolls/pages"
+	"github.com/aspcodenet/systementorlivepolls/protocol"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// schemaDoc is the part of an OpenAPI/AsyncAPI document the tests look at.
+type schemaDoc struct {
+	Paths      map[string]map[string]json.RawMessage `json:"paths"`
+	Components struct {
+		Schemas  map[string]schema          `json:"schemas"`
+		Messages map[string]json.RawMessage `json:"messages"`
+	} `json:"components"`
+}
+
+type schema struct {
+	Type       interface{}       `json:"type"`
+	Const      string            `json:"const"`
+	Required   []string          `json:"required"`
+	Properties map[string]schema `json:"properties"`
+}
+
+func loadSchemaDoc(t *testing.T, raw []byte) schemaDoc {
+	t.Helper()
+	var doc schemaDoc
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		t.Fatalf("document is not valid JSON: %v", err)
+	}
+	return doc
+}
+
+// jsonFields returns the JSON property names of a struct type and their Go types,
+// flattening embedded structs such as gorm.Model the way encoding/json does.
+func jsonFields(typ reflect.Type) map[string]reflect.Type {
+	fields := map[string]reflect.Type{}
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" || (!f.IsExported() && !f.Anonymous) {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			for k, v := range jsonFields(f.Type) {
+				fields[k] = v
+			}
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields[name] = f.Type
+	}
+	return fields
+}
+
+// schemaTypeFor is the JSON schema type a Go type is encoded as.
+func schemaTypeFor(typ reflect.Type) string {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	switch {
+	case typ == reflect.TypeOf(time.Time{}) || typ == reflect.TypeOf(gorm.DeletedAt{}):
+		return "string"
+	}
+	switch typ.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	default:
+		return "object"
+	}
+}
+
+// declaredType returns the type of a schema property, ignoring "null" in type lists.
+// Properties that only use $ref or allOf have no declared type.
+func declaredType(s schema) string {
+	switch v := s.Type.(type) {
+	case string:
+		return v
+	case []interface{}:
+		for _, t := range v {
+			if t != "null" {
+				return t.(string)
+			}
+		}
+	}
+	return ""
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// assertSchemaMatches checks that a schema has exactly the JSON fields of a Go type, with matching types.
+func assertSchemaMatches(t *testing.T, name string, s schema, typ reflect.Type) {
+	t.Helper()
+	fields := jsonFields(typ)
+	assert.Equal(t, sortedKeys(fields), sortedKeys(s.Properties), "properties of schema %s should match %s", name, typ)
+	for prop, propSchema := range s.Properties {
+		if goType, ok := fields[prop]; ok && declaredType(propSchema) != "" {
+			assert.Equal(t, schemaTypeFor(goType), declaredType(propSchema), "type of %s.%s", name, prop)
+		}
+	}
+	for _, req := range s.Required {
+		assert.Contains(t, s.Properties, req, "required property %s.%s is not defined", name, req)
+	}
+}
+
+func TestOpenAPISchemasMatchGoTypes(t *testing.T) {
+	doc := loadSchemaDoc(t, docs.OpenAPI)
+	types := map[string]interface{}{
+		"APIError":           pages.APIError{},
+		"APIOption":          pages.APIOption{},
+		"APIQuestion":        pages.APIQuestion{},
+		"APIPoll":            pages.APIPoll{},
+		"APIPollList":        pages.APIPollList{},
+		"APIRun":             pages.APIRun{},
+		"APIResults":         pages.APIResults{},
+		"APIResponses":       pages.APIResponses{},
+		"RawResponse":        data.RawResponse{},
+		"OptionResult":       data.OptionResult{},
+		"QuestionResult":     data.QuestionResult{},
+		"RequestOption":      pages.RequestOption{},
+		"RequestQuestion":    pages.RequestQuestion{},
+		"PollSaveRequest":    pages.PollSaveRequest{},
+		"PollSaveResponse":   pages.PollSaveResponse{},
+		"PollDefinition":     data.PollDefinition{},
+		"QuestionDefinition": data.QuestionDefinition{},
+		"OptionDefinition":   data.OptionDefinition{},
+	}
+	assert.Equal(t, sortedKeys(types), sortedKeys(doc.Components.Schemas), "every schema should be checked against a Go type")
+	for name, value := range types {
+		s, ok := doc.Components.Schemas[name]
+		if assert.True(t, ok, "schema %s is missing", name) {
+			assertSchemaMatches(t, name, s, reflect.TypeOf(value))
+		}
+	}
+}
+
+func TestOpenAPIDocumentsAllAPIRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	doc := loadSchemaDoc(t, docs.OpenAPI)
+	router := gin.New()
+	registerAPIRoutes(router)
+
+	ginParam := regexp.MustCompile(`:(\w+)`)
+	registered := map[string]bool{}
+	for _, route := range router.Routes() {
+		path := ginParam.ReplaceAllString(route.Path, "{$1}")
+		method := strings.ToLower(route.Method)
+		registered[method+" "+path] = true
+		_, ok := doc.Paths[path][method]
+		assert.True(t, ok, "%s %s is not documented in openapi.json", route.Method, path)
+	}
+
+	for path, operations := range doc.Paths {
+		if !strings.HasPrefix(path, "/api/") {
+			continue
+		}
+		for method := range operations {
+			if method == "parameters" {
+				continue
+			}
+			assert.True(t, registered[method+" "+path], "%s %s is documented but not registered", method, path)
+		}
+	}
+}
+
+func TestAsyncAPIMessagesMatchProtocolTypes(t *testing.T) {
+	doc := loadSchemaDoc(t, docs.AsyncAPI)
+
+	messages := map[string]struct {
+		schema string
+		value  interface{}
+	}{
+		protocol.TypeSubmitVote:    {"SubmitVoteMessage", protocol.SubmitVoteMessage{}},
+		protocol.TypeAdminAction:   {"AdminActionMessage", protocol.AdminActionMessage{}},
+		protocol.TypeWelcome:       {"WelcomeMessage", protocol.WelcomeMessage{}},
+		protocol.TypePollState:     {"PollStateMessage", protocol.PollStateMessage{}},
+		protocol.TypeResultsUpdate: {"ResultsUpdateMessage", protocol.ResultsUpdateMessage{}},
+		protocol.TypeVoteAccepted:  {"VoteAcceptedMessage", protocol.VoteAcceptedMessage{}},
+		protocol.TypeVoteRejected:  {"VoteRejectedMessage", protocol.VoteRejectedMessage{}},
+		protocol.TypeAck:           {"AckMessage", protocol.AckMessage{}},
+		protocol.TypeError:         {"ErrorMessage", protocol.ErrorMessage{}},
+	}
+	assert.Equal(t, sortedKeys(messages), sortedKeys(doc.Components.Messages), "every message type should be documented")
+
+	for messageType, m := range messages {
+		s, ok := doc.Components.Schemas[m.schema]
+		if !assert.True(t, ok, "schema %s is missing", m.schema) {
+			continue
+		}
+		assert.Equal(t, messageType, s.Properties["type"].Const, "type of %s", m.schema)
+		assertSchemaMatches(t, m.schema, s, reflect.TypeOf(m.value))
+	}
+
+	assertSchemaMatches(t, "Question", doc.Components.Schemas["Question"], reflect.TypeOf(protocol.Question{}))
+	assertSchemaMatches(t, "Option", doc.Components.Schemas["Option"], reflect.TypeOf(protocol.Option{}))
+}
+
+func TestDocsAreServed(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	registerAPIRoutes(router)
+
+	for _, path := range []string{"/api/openapi.json", "/api/asyncapi.json"} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(t, http.StatusOK, w.Code, path)
+		assert.True(t, json.Valid(w.Body.Bytes()), "%s should return valid JSON", path)
+	}
+}
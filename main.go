@@ -3,9 +3,14 @@ package main
 import (
 	"fmt"
 	"log"
+	"net/http"
 	"os"
+	"strconv"
+	"time"
 
+	"github.com/aspcodenet/systementorlivepolls/auth"
 	"github.com/aspcodenet/systementorlivepolls/data"
+	"github.com/aspcodenet/systementorlivepolls/docs"
 	"github.com/aspcodenet/systementorlivepolls/pages"
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-contrib/sessions/cookie"
@@ -30,10 +35,23 @@ func main() {
 		Database: os.Getenv("ADMIN_DATABASE_DATABASE"),
 		Server:   os.Getenv("ADMIN_DATABASE_SERVER")})
 
-	pages.Init(os.Getenv("ADMIN_SSO_CLIENTID"), os.Getenv("ADMIN_SSO_CLIENTSECRET"))
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(os.Args[1:], os.Stdout))
+	}
+	migrateAdmins()
+
+	pages.Init(auth.ProvidersFromEnv())
+
+	if days, err := strconv.Atoi(os.Getenv("POLL_TRASH_RETENTION_DAYS")); err == nil && days > 0 {
+		pages.TrashRetentionDays = days
+	}
+	startTrashPurger(time.Duration(pages.TrashRetentionDays)*24*time.Hour, time.Hour)
 
 	r := gin.Default()
 
+	allowedOrigins = originPolicyFromEnv()
+	r.Use(allowedOrigins.corsMiddleware())
+
 	var secret = os.Getenv("SESSION_STORE_SECRET")
 	if os.Getenv("ADMIN_REDIS_SERVER") != "" {
 		store, _eee := redis.NewStore(10, "tcp", os.Getenv("ADMIN_REDIS_SERVER"), "", "", []byte(secret))
@@ -55,25 +73,71 @@ func main() {
 
 	r.GET("/", pages.Start)
 	r.GET("/poll/:inviteID", pages.Poll)
+	r.GET("/poll/:inviteID/qr", pages.PollQRCode)
 	r.POST("/selectpoll", pages.SelectPoll)
-	r.GET("/loginv1", pages.GithubLoginHandler)
-	r.GET("/login/oauth2/code/github", pages.GithubCallbackHandler)
+	r.GET("/present/:token", pages.Presenter)
+	r.GET("/invite/:code", pages.Invite)
+	r.GET("/loginv1", pages.Login)
+	r.GET("/login/:provider", pages.LoginStart)
+	r.GET("/login/oauth2/code/:provider", pages.LoginCallback)
 	r.GET("/logout", pages.Logout)
-	r.GET("/admin/polls", WebPageAuthRequired, pages.AdminPolls)
-	r.GET("/admin/polls/new", WebPageAuthRequired, pages.AdminPollsNew)
-	r.POST("/admin/polls/save", WebPageAuthRequired, pages.AdminPollsSavePOST)
 
-	r.GET("/admin/polls/delete/:pollID", WebPageAuthRequired, pages.AdminPollsDelete)
-	r.POST("/admin/polls/delete/:pollID", WebPageAuthRequired, pages.AdminPollsDeletePOST)
-
-	r.GET("/admin/polls/copy/:pollID", WebPageAuthRequired, pages.AdminPollsCopy)
-	r.POST("/admin/polls/copy/:pollID", WebPageAuthRequired, pages.AdminPollsCopyPOST)
-
-	r.GET("/admin/polls/edit/:pollID", WebPageAuthRequired, pages.AdminPollsEdit)
-	r.GET("/admin/polls/controlpanel/:inviteID", WebPageAuthRequired, pages.AdminPollsControlPanel)
+	// Every admin page needs a logged in admin, see pages.AdminRequired. Pages about one poll load it
+	// with pages.PollRequired and the access they need. Forms and scripts that change anything send
+	// the CSRF token, see pages.CSRFProtect.
+	admin := r.Group("/admin", WebPageAuthRequired, pages.AdminRequired, pages.CSRFProtect)
+	admin.GET("/polls", pages.AdminPolls)
+	admin.GET("/polls/new", pages.EditorRequired, pages.AdminPollsNew)
+	admin.POST("/polls/save", pages.SameOriginRequired, pages.EditorRequired, pages.AdminPollsSavePOST)
+
+	admin.GET("/polls/delete/:pollID", pages.EditorRequired, pages.PollRequired(data.AccessOwner), pages.AdminPollsDelete)
+	admin.POST("/polls/delete/:pollID", pages.EditorRequired, pages.PollRequired(data.AccessOwner), pages.AdminPollsDeletePOST)
+
+	admin.GET("/polls/import", pages.EditorRequired, pages.AdminPollsImport)
+	admin.POST("/polls/import", pages.EditorRequired, pages.AdminPollsImportPOST)
+	admin.GET("/polls/questions/import", pages.EditorRequired, pages.AdminPollsQuestionsImport)
+	admin.POST("/polls/questions/import", pages.EditorRequired, pages.AdminPollsQuestionsImportPOST)
+
+	admin.GET("/polls/trash", pages.AdminPollsTrash)
+	admin.POST("/polls/restore/:pollID", pages.EditorRequired, pages.AdminPollsRestorePOST)
+
+	admin.GET("/polls/copy/:pollID", pages.EditorRequired, pages.PollRequired(data.AccessEdit), pages.AdminPollsCopy)
+	admin.POST("/polls/copy/:pollID", pages.EditorRequired, pages.PollRequired(data.AccessEdit), pages.AdminPollsCopyPOST)
+
+	admin.GET("/polls/edit/:pollID", pages.EditorRequired, pages.PollRequired(data.AccessEdit), pages.AdminPollsEdit)
+	admin.GET("/polls/controlpanel/:inviteID", pages.EditorRequired, pages.PollRequired(data.AccessRun), pages.AdminPollsControlPanel)
+	admin.GET("/polls/join/:inviteID", pages.PollRequired(data.AccessRun), pages.AdminPollsJoin)
+	admin.POST("/polls/presenter/reset/:inviteID", pages.EditorRequired, pages.PollRequired(data.AccessRun), pages.AdminPollsPresenterResetPOST)
+	admin.GET("/polls/share/:pollID", pages.EditorRequired, pages.PollRequired(data.AccessOwner), pages.AdminPollsShare)
+	admin.POST("/polls/share/:pollID", pages.EditorRequired, pages.PollRequired(data.AccessOwner), pages.AdminPollsSharePOST)
+	admin.POST("/polls/move/:pollID", pages.EditorRequired, pages.PollRequired(data.AccessOwner), pages.AdminPollsMovePOST)
+	admin.POST("/polls/transfer/:pollID", pages.EditorRequired, pages.PollRequired(data.AccessOwner), pages.AdminPollsTransferPOST)
+
+	admin.GET("/workspaces", pages.AdminWorkspaces)
+	admin.POST("/workspaces", pages.EditorRequired, pages.AdminWorkspacesCreatePOST)
+	admin.GET("/workspaces/:workspaceID", pages.AdminWorkspace)
+	admin.POST("/workspaces/:workspaceID/members", pages.EditorRequired, pages.AdminWorkspaceMembersPOST)
+
+	admin.GET("/profile", pages.AdminProfile)
+	admin.POST("/profile/keys/:slot/generate", pages.AdminProfileKeyGeneratePOST)
+	admin.POST("/profile/keys/:slot/revoke", pages.AdminProfileKeyRevokePOST)
+
+	admin.GET("/users", pages.OwnerRequired, pages.AdminUsers)
+	admin.POST("/users/:userID", pages.OwnerRequired, pages.AdminUsersUpdatePOST)
+	admin.POST("/users/:userID/transfer", pages.OwnerRequired, pages.AdminUsersTransferPOST)
+	admin.POST("/invites", pages.OwnerRequired, pages.AdminInvitesCreatePOST)
+	admin.POST("/invites/revoke/:inviteID", pages.OwnerRequired, pages.AdminInvitesRevokePOST)
+
+	admin.GET("/webhooks", pages.AdminWebhooks)
+	admin.POST("/webhooks", pages.EditorRequired, pages.AdminWebhooksCreatePOST)
+	admin.GET("/webhooks/:webhookID", pages.AdminWebhookDeliveries)
+	admin.POST("/webhooks/test/:webhookID", pages.EditorRequired, pages.AdminWebhooksTestPOST)
+	admin.POST("/webhooks/delete/:webhookID", pages.EditorRequired, pages.AdminWebhooksDeletePOST)
 
 	r.GET("/ws/:inviteID", handleWebSocket)
 
+	registerAPIRoutes(r)
+
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8080" // Default port for json-server
@@ -81,3 +145,33 @@ func main() {
 
 	log.Fatal(r.Run(":" + port))
 }
+
+// registerAPIRoutes adds the JSON API and its documentation. Every route added here must be
+// described in docs/openapi.json, which is checked by the tests.
+func registerAPIRoutes(r *gin.Engine) {
+	r.GET("/api/openapi.json", func(c *gin.Context) {
+		c.Data(http.StatusOK, "application/json", docs.OpenAPI)
+	})
+	r.GET("/api/asyncapi.json", func(c *gin.Context) {
+		c.Data(http.StatusOK, "application/json", docs.AsyncAPI)
+	})
+
+	api := r.Group("/api/v1", APIAuthRequired)
+	api.GET("/polls", pages.APIPollsList)
+	api.POST("/polls", pages.APIPollsCreate)
+	api.POST("/polls/import", pages.APIPollsImport)
+	api.GET("/polls/:pollID", pages.APIPollsGet)
+	api.PUT("/polls/:pollID", pages.APIPollsUpdate)
+	api.DELETE("/polls/:pollID", pages.APIPollsDelete)
+	api.POST("/polls/:pollID/copy", pages.APIPollsCopy)
+	api.GET("/polls/:pollID/definition", pages.APIPollDefinitionGet)
+	api.GET("/polls/:pollID/runs", pages.APIPollRunsList)
+	api.GET("/polls/:pollID/results", pages.APIPollResults)
+	api.GET("/polls/:pollID/runs/:runID/results", pages.APIPollResults)
+	api.GET("/polls/:pollID/export/results", pages.APIPollExportResults)
+	api.GET("/polls/:pollID/export/responses", pages.APIPollExportResponses)
+	api.GET("/polls/:pollID/runs/:runID/export/results", pages.APIPollExportResults)
+	api.GET("/polls/:pollID/runs/:runID/export/responses", pages.APIPollExportResponses)
+	api.GET("/polls/:pollID/questions/:questionID/chart", pages.APIQuestionChart)
+	api.GET("/polls/:pollID/runs/:runID/questions/:questionID/chart", pages.APIQuestionChart)
+}
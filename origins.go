@@ -0,0 +1,71 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/aspcodenet/systementorlivepolls/pages"
+	"github.com/gin-gonic/gin"
+	cors "github.com/rs/cors/wrapper/gin"
+)
+
+// allowedOrigins decides which other sites may open WebSockets to the polls and call the API
+// from a browser. Our own site is always allowed. It is set from ALLOWED_ORIGINS in main.
+var allowedOrigins originPolicy
+
+// originPolicy holds the origins, like https://lms.example.edu, that may use the server besides
+// its own. An origin may contain one * wildcard, like https://*.example.edu, and a single * allows
+// every site.
+type originPolicy struct {
+	origins []string
+}
+
+// originPolicyFromEnv reads ALLOWED_ORIGINS, a comma or space separated list of origins. Empty
+// means same-origin only.
+func originPolicyFromEnv() originPolicy {
+	return newOriginPolicy(os.Getenv("ALLOWED_ORIGINS"))
+}
+
+func newOriginPolicy(list string) originPolicy {
+	var p originPolicy
+	for _, origin := range strings.FieldsFunc(list, func(r rune) bool { return r == ',' || r == ' ' }) {
+		p.origins = append(p.origins, strings.ToLower(strings.TrimRight(origin, "/")))
+	}
+	return p
+}
+
+// allowsOrigin reports whether origin, the Origin header of a request, is in the list.
+func (p originPolicy) allowsOrigin(origin string) bool {
+	origin = strings.ToLower(origin)
+	for _, allowed := range p.origins {
+		if allowed == "*" || allowed == origin {
+			return true
+		}
+		if prefix, suffix, ok := strings.Cut(allowed, "*"); ok &&
+			len(origin) >= len(prefix)+len(suffix) && strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkOrigin is the WebSocket upgrader's CheckOrigin. Browsers always send Origin, clients
+// without one are not browsers and cannot be abused by another site, so they are let through.
+func (p originPolicy) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	return pages.IsSameOrigin(r, origin) || p.allowsOrigin(origin)
+}
+
+// corsMiddleware answers CORS requests from the allowed origins with the same list as checkOrigin.
+// Cookies are not allowed cross-site, other sites use the API with signed requests.
+func (p originPolicy) corsMiddleware() gin.HandlerFunc {
+	return cors.New(cors.Options{
+		AllowOriginFunc: p.allowsOrigin,
+		AllowedMethods:  []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete},
+		AllowedHeaders:  []string{"Content-Type", APIKeyHeader, APITimestampHeader, APINonceHeader, APISignatureHeader},
+	})
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOriginPolicy_AllowsOrigin(t *testing.T) {
+	tests := []struct {
+		list   string
+		origin string
+		want   bool
+	}{
+		{"", "https://lms.example.edu", false},
+		{"https://lms.example.edu", "https://lms.example.edu", true},
+		{"https://LMS.example.edu/", "https://lms.example.edu", true},
+		{"https://lms.example.edu", "http://lms.example.edu", false},
+		{"https://lms.example.edu", "https://lms.example.edu.evil.com", false},
+		{"https://other.example.com, https://lms.example.edu", "https://lms.example.edu", true},
+		{"https://*.example.edu", "https://canvas.example.edu", true},
+		{"https://*.example.edu", "https://example.com/.example.edu", true},
+		{"https://*.example.edu", "https://evil.com", false},
+		{"*", "https://anything.example.com", true},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, newOriginPolicy(tt.list).allowsOrigin(tt.origin), "%q allows %q", tt.list, tt.origin)
+	}
+}
+
+func TestOriginPolicy_CheckOrigin(t *testing.T) {
+	p := newOriginPolicy("https://lms.example.edu")
+	request := func(origin string) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, "http://polls.example.com/ws/invite", nil)
+		if origin != "" {
+			r.Header.Set("Origin", origin)
+		}
+		return r
+	}
+
+	assert.True(t, p.checkOrigin(request("")), "clients without Origin are not browsers")
+	assert.True(t, p.checkOrigin(request("http://polls.example.com")))
+	assert.True(t, p.checkOrigin(request("https://lms.example.edu")))
+	assert.False(t, p.checkOrigin(request("https://evil.example.com")))
+	assert.False(t, p.checkOrigin(request("null")))
+	assert.False(t, p.checkOrigin(request("https://polls.example.com")), "the same host with another scheme is another origin")
+	forwarded := request("https://polls.example.com")
+	forwarded.Header.Set("X-Forwarded-Proto", "https")
+	assert.True(t, p.checkOrigin(forwarded), "https behind a proxy")
+
+	t.Setenv("PUBLIC_BASE_URL", "https://live.example.com")
+	assert.True(t, p.checkOrigin(request("https://live.example.com")), "the public URL behind a proxy")
+}
+
+func TestOriginPolicy_CORS(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(newOriginPolicy("https://lms.example.edu").corsMiddleware())
+	router.GET("/api/v1/polls", func(c *gin.Context) { c.String(http.StatusOK, "ok") })
+
+	for origin, want := range map[string]string{"https://lms.example.edu": "https://lms.example.edu", "https://evil.example.com": ""} {
+		r := httptest.NewRequest(http.MethodOptions, "/api/v1/polls", nil)
+		r.Header.Set("Origin", origin)
+		r.Header.Set("Access-Control-Request-Method", http.MethodGet)
+		r.Header.Set("Access-Control-Request-Headers", "content-type,x-api-key") // Lower case and sorted, as browsers send it
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		assert.Equal(t, want, w.Header().Get("Access-Control-Allow-Origin"), origin)
+		assert.Empty(t, w.Header().Get("Access-Control-Allow-Credentials"), origin)
+	}
+}
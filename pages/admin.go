@@ -6,57 +6,68 @@ import (
 	"fmt"
 	"log"
 	"net/http"
-	"os"
 	"strconv"
-	"strings"
 
 	"github.com/aspcodenet/systementorlivepolls/data"
 	"github.com/aspcodenet/systementorlivepolls/utils"
-	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
-	"gorm.io/gorm"
 )
 
+// TrashRetentionDays is how long deleted polls stay in the trash before they are purged.
+var TrashRetentionDays = 30
+
 type RequestOption struct {
 	DatabaseId uint   `json:"databaseId"`
 	Text       string `json:"text"`
 }
 
-func AdminPollsSavePOST(c *gin.Context) {
-	session := sessions.Default(c)
-	user := session.Get(Userkey)
-	var currentUser = ""
-	if user != nil {
-		currentUser = user.(string)
-	}
+type RequestQuestion struct {
+	DatabaseId        uint            `json:"databaseId"`
+	Text              string          `json:"text"`
+	Type              string          `json:"type"`
+	ResultsVisibility string          `json:"resultsVisibility"` // Empty to use the poll's setting
+	Options           []RequestOption `json:"options"`
+}
 
-	if checkAdmin(currentUser) == false {
-		c.HTML(http.StatusOK, "noadmin.html", gin.H{})
-		return
-	}
+// PollSaveRequest is the JSON body posted by the poll editor and the REST API to create or update a poll.
+type PollSaveRequest struct {
+	DatabaseId        uint              `json:"databaseId"`
+	Title             string            `json:"title"`
+	ResultsVisibility string            `json:"resultsVisibility"` // Empty for the default, data.ResultsAfterReveal
+	Questions         []RequestQuestion `json:"questions"`
+}
 
-	var adminUser data.AdminUser
-	err := data.DB.First(&adminUser, "email=?", currentUser).Error
-	if errors.Is(err, gorm.ErrRecordNotFound) {
-		c.Redirect(302, "/")
-		return
+// resultsVisibilityError returns an error message if a poll or question in the request has an
+// unknown result visibility setting, or an empty string.
+func (req *PollSaveRequest) resultsVisibilityError() string {
+	if !data.ValidResultsVisibility(req.ResultsVisibility) {
+		return "resultsVisibility must be never, after_reveal or live."
 	}
-
-	var req struct {
-		DatabaseId uint   `json:"databaseId"`
-		Title      string `json:"title"`
-		Questions  []struct {
-			DatabaseId uint            `json:"databaseId"`
-			Text       string          `json:"text"`
-			Type       string          `json:"type"`
-			Options    []RequestOption `json:"options"`
-		} `json:"questions"`
+	for i, q := range req.Questions {
+		if !data.ValidResultsVisibility(q.ResultsVisibility) {
+			return fmt.Sprintf("questions[%d].resultsVisibility must be never, after_reveal or live.", i)
+		}
 	}
+	return ""
+}
+
+type PollSaveResponse struct {
+	PollID  string `json:"pollId"`
+	Message string `json:"message"`
+}
+
+func AdminPollsSavePOST(c *gin.Context) {
+	adminUser := currentAdmin(c)
 
+	var req PollSaveRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if msg := req.resultsVisibilityError(); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
 
 	var poll *data.Poll
 	if req.DatabaseId == 0 {
@@ -69,24 +80,45 @@ func AdminPollsSavePOST(c *gin.Context) {
 			InviteID:             randString,
 		}
 	} else {
-		poll, _ = data.GetPollAndDetailsForAdmin(req.DatabaseId)
-		poll.Title = req.Title
-
+		var err error
+		if poll, err = data.GetPollAndDetailsForAdmin(req.DatabaseId); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Poll not found."})
+			return
+		}
 	}
 
-	if poll.AdminUserID != int(adminUser.ID) {
+	if !data.CanAccessPoll(poll, adminUser, data.AccessEdit) {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "You are not authorized to modify this poll."})
 		return
 	}
 
+	applyPollSaveRequest(poll, &req)
+
+	// Save the poll and its associations to the database
+	if result := data.DB.Save(&poll); result.Error != nil {
+		log.Printf("Error creating poll in DB: %v", result.Error)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create poll in database."})
+		return
+	}
+
+	c.JSON(http.StatusOK, PollSaveResponse{PollID: fmt.Sprintf("%d", poll.ID), Message: "Poll saved successfully!"})
+
+}
+
+// applyPollSaveRequest copies the title and questions posted by the poll editor (or the API) onto a poll.
+// Questions and options with a DatabaseId are updated in place, the rest are added.
+func applyPollSaveRequest(poll *data.Poll, req *PollSaveRequest) {
+	poll.Title = req.Title
+	poll.ResultsVisibility = req.ResultsVisibility
 	for _, formQuestion := range req.Questions {
 		// New or existing question?
 		var updated = false
-		if req.DatabaseId > 0 {
+		if poll.ID > 0 {
 			for i, existingQuestion := range poll.Questions {
 				if existingQuestion.ID == formQuestion.DatabaseId {
 					poll.Questions[i].Text = formQuestion.Text
 					poll.Questions[i].Type = formQuestion.Type
+					poll.Questions[i].ResultsVisibility = formQuestion.ResultsVisibility
 					poll.Questions[i].Options = syncOptions(poll.Questions[i].Options, formQuestion.Options)
 					updated = true
 					continue
@@ -95,24 +127,15 @@ func AdminPollsSavePOST(c *gin.Context) {
 		}
 		if updated == false {
 			newQuestion := data.Question{
-				Text:  formQuestion.Text,
-				Type:  formQuestion.Type,
-				Votes: make(map[string]int), // Initialize empty map (will be populated from Vote table)
+				Text:              formQuestion.Text,
+				Type:              formQuestion.Type,
+				ResultsVisibility: formQuestion.ResultsVisibility,
+				Votes:             make(map[string]int), // Initialize empty map (will be populated from Vote table)
 			}
 			newQuestion.Options = syncOptions(newQuestion.Options, formQuestion.Options)
 			poll.Questions = append(poll.Questions, newQuestion)
 		}
 	}
-
-	// Save the poll and its associations to the database
-	if result := data.DB.Save(&poll); result.Error != nil {
-		log.Printf("Error creating poll in DB: %v", result.Error)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create poll in database."})
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{"pollId": fmt.Sprintf("%d", poll.ID), "message": "Poll saved successfully!"})
-
 }
 
 func syncOptions(fromDatabase []data.Option, fromForm []RequestOption) []data.Option {
@@ -152,94 +175,44 @@ func syncOptions(fromDatabase []data.Option, fromForm []RequestOption) []data.Op
 }
 
 func AdminPollsControlPanel(c *gin.Context) {
-	session := sessions.Default(c)
-	user := session.Get(Userkey)
-	var currentUser = ""
-	if user != nil {
-		currentUser = user.(string)
-	}
-
-	if checkAdmin(currentUser) == false {
-		c.HTML(http.StatusOK, "noadmin.html", gin.H{})
-		return
-	}
-	var adminUser data.AdminUser
-	err := data.DB.First(&adminUser, "email=?", currentUser).Error
-	if errors.Is(err, gorm.ErrRecordNotFound) {
-		c.Redirect(302, "/")
-		return
-	}
-
-	pollID := c.Param("inviteID")
-	if err != nil {
-		c.AbortWithStatus(http.StatusBadRequest)
-		return
-	}
+	adminUser := currentAdmin(c)
+	poll := currentPoll(c)
 
-	//Here read the poll inclusive questions and inclusive options
-	poll, err := data.GetPollWithDetails(pollID)
-	if poll.AdminUserID != int(adminUser.ID) {
-		c.AbortWithStatus(http.StatusUnauthorized)
-		return
-	}
 	jsonData, _ := json.MarshalIndent(poll.Questions, "", "  ")
+	if err := data.EnsurePresenterToken(poll); err != nil {
+		log.Printf("Error creating presenter link for poll %d: %v", poll.ID, err)
+	}
 
 	c.HTML(http.StatusOK, "adminpollscontrolpanel.html", gin.H{
-		"AdminUser": adminUser,
-		"Poll":      poll,
-		"AsJson":    string(jsonData),
+		"CSRFToken":    csrfToken(c),
+		"AdminUser":    adminUser,
+		"Poll":         poll,
+		"AsJson":       string(jsonData),
+		"JoinURL":      publicURL(c, "/poll/"+poll.InviteID),
+		"PresenterURL": publicURL(c, presenterPath(poll)),
 	})
 
 }
 
 func AdminPollsCopyPOST(c *gin.Context) {
-	session := sessions.Default(c)
-	user := session.Get(Userkey)
-	var currentUser = ""
-	if user != nil {
-		currentUser = user.(string)
-	}
-	if checkAdmin(currentUser) == false {
-		c.HTML(http.StatusOK, "noadmin.html", gin.H{})
-	}
+	adminUser := currentAdmin(c)
+	poll := currentPoll(c)
 
-	var adminUser data.AdminUser
-	err := data.DB.First(&adminUser, "email=?", currentUser).Error
-	if errors.Is(err, gorm.ErrRecordNotFound) {
-		c.Redirect(302, "/")
-		return
-	}
-
-	pollID, err := strconv.Atoi(c.Param("pollID"))
+	limitReached, err := data.PollLimitReached(adminUser)
 	if err != nil {
-		c.AbortWithStatus(http.StatusBadRequest)
+		log.Printf("Error checking poll limit: %v", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
 		return
 	}
-
-	//Here read the poll inclusive questions and inclusive options
-	poll, _ := data.GetPollAndDetailsForAdmin(uint(pollID))
-	if poll.AdminUserID != int(adminUser.ID) {
-		c.HTML(http.StatusOK, "noadmin.html", gin.H{})
-		return
-	}
-
-	var count int64
-	data.DB.Model(&data.Poll{}).Where("admin_user_id = ?", adminUser.ID).Count(&count)
-	if count >= 5 {
+	if limitReached {
 		c.HTML(http.StatusOK, "maxpolls.html", gin.H{
 			"AdminUser": adminUser,
 		})
 		return
 	}
 
-	//Here read the poll inclusive questions and inclusive options
-	poll, _ = data.GetPollAndDetailsForAdmin(uint(pollID))
-	if poll.AdminUserID != int(adminUser.ID) {
-		c.HTML(http.StatusOK, "noadmin.html", gin.H{})
-		return
-	}
 	//Copy poll and all details to new poll
-	pollCopy := poll.DeepCopyWithoutID()
+	pollCopy := data.CopyPollFor(poll, adminUser)
 	pollCopy.Title = "Copy of " + poll.Title
 
 	data.DB.Save(&pollCopy)
@@ -248,39 +221,16 @@ func AdminPollsCopyPOST(c *gin.Context) {
 }
 
 func AdminPollsCopy(c *gin.Context) {
-	session := sessions.Default(c)
-	user := session.Get(Userkey)
-	var currentUser = ""
-	if user != nil {
-		currentUser = user.(string)
-	}
-	if checkAdmin(currentUser) == false {
-		c.HTML(http.StatusOK, "noadmin.html", gin.H{})
-	}
-
-	var adminUser data.AdminUser
-	err := data.DB.First(&adminUser, "email=?", currentUser).Error
-	if errors.Is(err, gorm.ErrRecordNotFound) {
-		c.Redirect(302, "/")
-		return
-	}
+	adminUser := currentAdmin(c)
+	poll := currentPoll(c)
 
-	pollID, err := strconv.Atoi(c.Param("pollID"))
+	limitReached, err := data.PollLimitReached(adminUser)
 	if err != nil {
-		c.AbortWithStatus(http.StatusBadRequest)
+		log.Printf("Error checking poll limit: %v", err)
+		c.AbortWithStatus(http.StatusInternalServerError)
 		return
 	}
-
-	//Here read the poll inclusive questions and inclusive options
-	poll, _ := data.GetPollAndDetailsForAdmin(uint(pollID))
-	if poll.AdminUserID != int(adminUser.ID) {
-		c.HTML(http.StatusOK, "noadmin.html", gin.H{})
-		return
-	}
-
-	var count int64
-	data.DB.Model(&data.Poll{}).Where("admin_user_id = ?", adminUser.ID).Count(&count)
-	if count >= 5 {
+	if limitReached {
 		c.HTML(http.StatusOK, "maxpolls.html", gin.H{
 			"AdminUser": adminUser,
 		})
@@ -288,6 +238,7 @@ func AdminPollsCopy(c *gin.Context) {
 	}
 
 	c.HTML(http.StatusOK, "adminpollscopy.html", gin.H{
+		"CSRFToken": csrfToken(c),
 		"AdminUser": adminUser,
 		"Poll":      poll,
 	})
@@ -295,37 +246,11 @@ func AdminPollsCopy(c *gin.Context) {
 }
 
 func AdminPollsDelete(c *gin.Context) {
-	session := sessions.Default(c)
-	user := session.Get(Userkey)
-	var currentUser = ""
-	if user != nil {
-		currentUser = user.(string)
-	}
-	if checkAdmin(currentUser) == false {
-		c.HTML(http.StatusOK, "noadmin.html", gin.H{})
-	}
-
-	var adminUser data.AdminUser
-	err := data.DB.First(&adminUser, "email=?", currentUser).Error
-	if errors.Is(err, gorm.ErrRecordNotFound) {
-		c.Redirect(302, "/")
-		return
-	}
-
-	pollID, err := strconv.Atoi(c.Param("pollID"))
-	if err != nil {
-		c.AbortWithStatus(http.StatusBadRequest)
-		return
-	}
-
-	//Here read the poll inclusive questions and inclusive options
-	poll, _ := data.GetPollAndDetailsForAdmin(uint(pollID))
-	if poll.AdminUserID != int(adminUser.ID) {
-		c.HTML(http.StatusOK, "noadmin.html", gin.H{})
-		return
-	}
+	adminUser := currentAdmin(c)
+	poll := currentPoll(c)
 
 	c.HTML(http.StatusOK, "adminpollsdelete.html", gin.H{
+		"CSRFToken": csrfToken(c),
 		"AdminUser": adminUser,
 		"Poll":      poll,
 	})
@@ -333,40 +258,14 @@ func AdminPollsDelete(c *gin.Context) {
 }
 
 func AdminPollsDeletePOST(c *gin.Context) {
-	session := sessions.Default(c)
-	user := session.Get(Userkey)
-	var currentUser = ""
-	if user != nil {
-		currentUser = user.(string)
-	}
-	if checkAdmin(currentUser) == false {
-		c.HTML(http.StatusOK, "noadmin.html", gin.H{})
-	}
-
-	var adminUser data.AdminUser
-	err := data.DB.First(&adminUser, "email=?", currentUser).Error
-	if errors.Is(err, gorm.ErrRecordNotFound) {
-		c.Redirect(302, "/")
-		return
-	}
-
-	pollID, err := strconv.Atoi(c.Param("pollID"))
-	if err != nil {
-		c.AbortWithStatus(http.StatusBadRequest)
-		return
-	}
-
-	//Here read the poll inclusive questions and inclusive options
-	poll, _ := data.GetPollAndDetailsForAdmin(uint(pollID))
-	if poll.AdminUserID != int(adminUser.ID) {
-		c.HTML(http.StatusOK, "noadmin.html", gin.H{})
-		return
-	}
+	currentUser := currentAdmin(c).Email
+	poll := currentPoll(c)
 
 	if poll.Title != c.PostForm("name") {
 		errors := make(map[string][]string)
 		errors["Name"] = append(errors["Name"], "Poll name does not match")
 		c.HTML(200, "adminpollsdelete.html", gin.H{
+			"CSRFToken":   csrfToken(c),
 			"title":       "Delete poll",
 			"CurrentUser": currentUser,
 			"Poll":        poll,
@@ -375,46 +274,71 @@ func AdminPollsDeletePOST(c *gin.Context) {
 		return
 	}
 
-	data.DB.Delete(&poll)
+	if err := data.SoftDeletePoll(poll.ID); err != nil {
+		log.Printf("Error deleting poll %d: %v", poll.ID, err)
+		c.AbortWithError(500, errors.New("Failed to delete poll"))
+		return
+	}
 	c.Redirect(302, "/admin/polls")
 
 }
 
-func AdminPollsEdit(c *gin.Context) {
-	session := sessions.Default(c)
-	user := session.Get(Userkey)
-	var currentUser = ""
-	if user != nil {
-		currentUser = user.(string)
-	}
+func AdminPollsTrash(c *gin.Context) {
+	adminUser := currentAdmin(c)
+	currentUser := adminUser.Email
 
-	if checkAdmin(currentUser) == false {
-		c.HTML(http.StatusOK, "noadmin.html", gin.H{})
-		return
-	}
-	var adminUser data.AdminUser
-	err := data.DB.First(&adminUser, "email=?", currentUser).Error
-	if errors.Is(err, gorm.ErrRecordNotFound) {
-		c.Redirect(302, "/")
+	polls, err := data.GetDeletedPolls(adminUser.ID)
+	if err != nil {
+		c.AbortWithError(500, errors.New("Failed to retrieve deleted polls"))
 		return
 	}
 
+	c.HTML(200, "adminpollstrash.html", gin.H{
+		"CSRFToken":     csrfToken(c),
+		"title":         "Deleted polls",
+		"CurrentUser":   currentUser,
+		"Polls":         polls,
+		"RetentionDays": TrashRetentionDays,
+	})
+}
+
+func AdminPollsRestorePOST(c *gin.Context) {
+	adminUser := currentAdmin(c)
+
 	pollID, err := strconv.Atoi(c.Param("pollID"))
 	if err != nil {
 		c.AbortWithStatus(http.StatusBadRequest)
 		return
 	}
 
-	//Here read the poll inclusive questions and inclusive options
-	poll, err := data.GetPollAndDetailsForAdmin(uint(pollID))
-	if poll.AdminUserID != int(adminUser.ID) {
-		c.HTML(http.StatusOK, "noadmin.html", gin.H{})
+	// Polls in the trash are not found by PollRequired
+	var poll data.Poll
+	err = data.DB.Unscoped().Where("deleted_at IS NOT NULL").First(&poll, pollID).Error
+	if err != nil {
+		c.HTML(http.StatusNotFound, "notfound.html", gin.H{"CurrentUser": adminUser.Email, "Message": "The poll is not in the trash."})
+		return
+	}
+	if !data.CanAccessPoll(&poll, adminUser, data.AccessOwner) {
+		c.HTML(http.StatusForbidden, "noadmin.html", gin.H{"CurrentUser": adminUser.Email, "NoPollAccess": true})
+		return
+	}
+
+	if err := data.RestorePoll(poll.ID); err != nil {
+		log.Printf("Error restoring poll %d: %v", poll.ID, err)
+		c.AbortWithError(500, errors.New("Failed to restore poll"))
 		return
 	}
+	c.Redirect(302, "/admin/polls")
+}
+
+func AdminPollsEdit(c *gin.Context) {
+	adminUser := currentAdmin(c)
+	poll := currentPoll(c)
 
 	jsonData, _ := json.MarshalIndent(poll.Questions, "", "  ")
 
 	c.HTML(http.StatusOK, "adminpollsedit.html", gin.H{
+		"CSRFToken": csrfToken(c),
 		"AdminUser": adminUser,
 		"Poll":      poll,
 		"AsJson":    string(jsonData),
@@ -423,59 +347,32 @@ func AdminPollsEdit(c *gin.Context) {
 }
 
 func AdminPollsNew(c *gin.Context) {
-	session := sessions.Default(c)
-	user := session.Get(Userkey)
-	var currentUser = ""
-	if user != nil {
-		currentUser = user.(string)
-	}
-
-	if checkAdmin(currentUser) == false {
-		c.HTML(http.StatusOK, "noadmin.html", gin.H{})
-		return
-	}
-
-	var adminUser data.AdminUser
-	err := data.DB.First(&adminUser, "email=?", currentUser).Error
-	if errors.Is(err, gorm.ErrRecordNotFound) {
-		c.Redirect(302, "/")
-		return
-	}
+	adminUser := currentAdmin(c)
 
 	c.HTML(http.StatusOK, "adminpollsnew.html", gin.H{
+		"CSRFToken": csrfToken(c),
 		"AdminUser": adminUser,
 	})
 }
 
 func AdminPolls(c *gin.Context) {
-	session := sessions.Default(c)
-	user := session.Get(Userkey)
-	var currentUser = ""
-	if user != nil {
-		currentUser = user.(string)
-	}
+	adminUser := currentAdmin(c)
+	currentUser := adminUser.Email
 
-	if checkAdmin(currentUser) == false {
-		c.HTML(http.StatusOK, "noadmin.html", gin.H{})
-		return
-	}
-
-	var adminUser data.AdminUser
-	err := data.DB.First(&adminUser, "email=?", currentUser).Error
-	if errors.Is(err, gorm.ErrRecordNotFound) {
-		c.Redirect(302, "/")
-		return
-	}
-
-	dataObjects := []data.Poll{}
-	if err := data.DB.Where("admin_user_id = ?", adminUser.ID).Find(&dataObjects).Error; err != nil && err != gorm.ErrRecordNotFound {
+	dataObjects, err := data.GetAccessiblePolls(adminUser, data.AccessRun)
+	if err != nil {
 		c.AbortWithError(500, errors.New("Failed to retrieve polls"))
 		return
 	}
+	workspaceNames := map[uint]string{}
+	workspaces, _ := data.GetWorkspacesForAdmin(adminUser.ID)
+	for _, w := range workspaces {
+		workspaceNames[w.ID] = w.Name
+	}
 
 	q := c.Query("q")
 	// Filter the dataObjects based on the query and type
-	result := []data.Poll{}
+	result := []adminPollRow{}
 	for _, db := range dataObjects {
 		if q != "" {
 			// Check if the database name contains the query string (case-insensitive)
@@ -483,10 +380,15 @@ func AdminPolls(c *gin.Context) {
 				continue
 			}
 		}
-		result = append(result, db)
+		row := adminPollRow{Poll: db, Access: data.PollAccess(db, adminUser)}
+		if db.WorkspaceID != nil {
+			row.Workspace = workspaceNames[*db.WorkspaceID]
+		}
+		result = append(result, row)
 	}
 
 	c.HTML(200, "adminpolls.html", gin.H{
+		"CSRFToken":   csrfToken(c),
 		"title":       "Admin polls",
 		"CurrentUser": currentUser,
 		"q":           q,
@@ -495,17 +397,22 @@ func AdminPolls(c *gin.Context) {
 
 }
 
+// adminPollRow is a poll in the poll list with what the admin may do with it and, for a poll
+// of a workspace, the workspace name.
+type adminPollRow struct {
+	*data.Poll
+	Access    string
+	Workspace string
+}
+
+// checkAdmin reports whether the user may use the admin pages, see data.AdminUser.IsAdmin.
 func checkAdmin(currentUser string) bool {
-	adminsCSV := os.Getenv("ADMINS")
-	if adminsCSV == "" {
-		return true
+	if currentUser == "" {
+		return false
 	}
-	adminEmails := strings.Split(adminsCSV, ",")
-	// Check if the current user's email is in the list of admins
-	for _, admin := range adminEmails {
-		if strings.EqualFold(admin, currentUser) {
-			return true
-		}
+	var adminUser data.AdminUser
+	if err := data.DB.First(&adminUser, "email=?", currentUser).Error; err != nil {
+		return false
 	}
-	return false
+	return adminUser.IsAdmin()
 }
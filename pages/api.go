@@ -0,0 +1,412 @@
+package pages
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/aspcodenet/systementorlivepolls/data"
+	"github.com/aspcodenet/systementorlivepolls/utils"
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	apiDefaultPageSize = 20
+	apiMaxPageSize     = 100
+)
+
+// APIOption, APIQuestion and APIPoll are the JSON representations of polls returned by /api/v1.
+type APIOption struct {
+	ID   uint   `json:"id"`
+	Text string `json:"text"`
+}
+
+type APIQuestion struct {
+	ID                uint        `json:"id"`
+	Text              string      `json:"text"`
+	Type              string      `json:"type"`
+	ResultsVisibility string      `json:"resultsVisibility,omitempty"`
+	Options           []APIOption `json:"options"`
+}
+
+type APIPoll struct {
+	ID                   uint          `json:"id"`
+	Title                string        `json:"title"`
+	Status               string        `json:"status"`
+	InviteID             string        `json:"inviteId"`
+	JoinPIN              string        `json:"joinPin,omitempty"`
+	ResultsVisibility    string        `json:"resultsVisibility,omitempty"`
+	CurrentQuestionIndex int           `json:"currentQuestionIndex"`
+	CreatedAt            time.Time     `json:"createdAt"`
+	UpdatedAt            time.Time     `json:"updatedAt"`
+	Questions            []APIQuestion `json:"questions,omitempty"`
+}
+
+type APIPollList struct {
+	Polls    []APIPoll `json:"polls"`
+	Page     int       `json:"page"`
+	PageSize int       `json:"pageSize"`
+	Total    int64     `json:"total"`
+}
+
+type APIRun struct {
+	ID         uint       `json:"id"`
+	StartedAt  time.Time  `json:"startedAt"`
+	FinishedAt *time.Time `json:"finishedAt"`
+}
+
+type APIResults struct {
+	PollID    uint                  `json:"pollId"`
+	RunID     uint                  `json:"runId,omitempty"`
+	Questions []data.QuestionResult `json:"questions"`
+}
+
+// APIError is the body of every non-2xx response from /api/v1.
+type APIError struct {
+	Error string `json:"error"`
+}
+
+func apiError(c *gin.Context, status int, message string) {
+	c.AbortWithStatusJSON(status, APIError{Error: message})
+}
+
+func toAPIPoll(p *data.Poll, withQuestions bool) APIPoll {
+	result := APIPoll{
+		ID:                   p.ID,
+		Title:                p.Title,
+		Status:               p.Status,
+		InviteID:             p.InviteID,
+		ResultsVisibility:    p.ResultsVisibility,
+		CurrentQuestionIndex: p.CurrentQuestionIndex,
+		CreatedAt:            p.CreatedAt,
+		UpdatedAt:            p.UpdatedAt,
+	}
+	if p.JoinPIN != nil {
+		result.JoinPIN = *p.JoinPIN
+	}
+	if withQuestions {
+		result.Questions = make([]APIQuestion, 0, len(p.Questions))
+		for _, q := range p.Questions {
+			question := APIQuestion{ID: q.ID, Text: q.Text, Type: q.Type, ResultsVisibility: q.ResultsVisibility, Options: make([]APIOption, 0, len(q.Options))}
+			for _, o := range q.Options {
+				question.Options = append(question.Options, APIOption{ID: o.ID, Text: o.Text})
+			}
+			result.Questions = append(result.Questions, question)
+		}
+	}
+	return result
+}
+
+// apiAdminUser loads the admin user making the request, or writes an error response and returns nil.
+// Requests signed with an API key carry the user in the gin context, others in the session.
+func apiAdminUser(c *gin.Context) *data.AdminUser {
+	currentUser := c.GetString(Userkey)
+	if currentUser == "" {
+		session := sessions.Default(c)
+		if user := session.Get(Userkey); user != nil {
+			currentUser = user.(string)
+		}
+	}
+
+	if checkAdmin(currentUser) == false {
+		apiError(c, http.StatusForbidden, "You are not registered as admin in the system.")
+		return nil
+	}
+
+	var adminUser data.AdminUser
+	err := data.DB.First(&adminUser, "email=?", currentUser).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		apiError(c, http.StatusUnauthorized, "Unknown user.")
+		return nil
+	} else if err != nil {
+		apiError(c, http.StatusInternalServerError, "Failed to retrieve user.")
+		return nil
+	}
+	if c.Request.Method != http.MethodGet && !adminUser.CanEdit() {
+		apiError(c, http.StatusForbidden, "Viewers cannot change polls.")
+		return nil
+	}
+	return &adminUser
+}
+
+// apiOwnedPoll loads the poll in the :pollID parameter with questions, options and votes, making sure
+// adminUser may use it: run it for GET requests, delete it for DELETE requests and edit it otherwise.
+// Writes an error response and returns nil otherwise.
+func apiOwnedPoll(c *gin.Context, adminUser *data.AdminUser) *data.Poll {
+	pollID, err := strconv.Atoi(c.Param("pollID"))
+	if err != nil {
+		apiError(c, http.StatusBadRequest, "Invalid poll id.")
+		return nil
+	}
+
+	poll, err := data.GetPollAndDetailsForAdmin(uint(pollID))
+	if err != nil {
+		apiError(c, http.StatusNotFound, "Poll not found.")
+		return nil
+	}
+	need := data.AccessEdit
+	switch c.Request.Method {
+	case http.MethodGet:
+		need = data.AccessRun
+	case http.MethodDelete:
+		need = data.AccessOwner
+	}
+	if !data.CanAccessPoll(poll, adminUser, need) {
+		apiError(c, http.StatusForbidden, "You are not authorized to access this poll.")
+		return nil
+	}
+	return poll
+}
+
+// apiRunID returns the run in the optional :runID parameter, making sure it belongs to poll.
+// Returns 0 if there is no :runID parameter. Writes an error response and returns false otherwise.
+func apiRunID(c *gin.Context, poll *data.Poll) (uint, bool) {
+	if c.Param("runID") == "" {
+		return 0, true
+	}
+	id, err := strconv.Atoi(c.Param("runID"))
+	if err != nil {
+		apiError(c, http.StatusBadRequest, "Invalid run id.")
+		return 0, false
+	}
+	var run data.PollRun
+	if err := data.DB.First(&run, "id = ? AND poll_id = ?", id, poll.ID).Error; err != nil {
+		apiError(c, http.StatusNotFound, "Run not found.")
+		return 0, false
+	}
+	return run.ID, true
+}
+
+func APIPollsList(c *gin.Context) {
+	adminUser := apiAdminUser(c)
+	if adminUser == nil {
+		return
+	}
+
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		apiError(c, http.StatusBadRequest, "page must be a positive integer.")
+		return
+	}
+	pageSize, err := strconv.Atoi(c.DefaultQuery("pageSize", strconv.Itoa(apiDefaultPageSize)))
+	if err != nil || pageSize < 1 || pageSize > apiMaxPageSize {
+		apiError(c, http.StatusBadRequest, "pageSize must be between 1 and "+strconv.Itoa(apiMaxPageSize)+".")
+		return
+	}
+
+	query := data.AccessiblePolls(adminUser).Session(&gorm.Session{})
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		apiError(c, http.StatusInternalServerError, "Failed to retrieve polls.")
+		return
+	}
+
+	polls := []*data.Poll{}
+	if err := query.Order("id").Offset((page - 1) * pageSize).Limit(pageSize).Find(&polls).Error; err != nil {
+		apiError(c, http.StatusInternalServerError, "Failed to retrieve polls.")
+		return
+	}
+
+	result := APIPollList{Polls: make([]APIPoll, 0, len(polls)), Page: page, PageSize: pageSize, Total: total}
+	for _, p := range polls {
+		result.Polls = append(result.Polls, toAPIPoll(p, false))
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+func APIPollsGet(c *gin.Context) {
+	adminUser := apiAdminUser(c)
+	if adminUser == nil {
+		return
+	}
+	poll := apiOwnedPoll(c, adminUser)
+	if poll == nil {
+		return
+	}
+	c.JSON(http.StatusOK, toAPIPoll(poll, true))
+}
+
+func APIPollsCreate(c *gin.Context) {
+	adminUser := apiAdminUser(c)
+	if adminUser == nil {
+		return
+	}
+
+	var req PollSaveRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apiError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.Title == "" {
+		apiError(c, http.StatusBadRequest, "title is required.")
+		return
+	}
+	if msg := req.resultsVisibilityError(); msg != "" {
+		apiError(c, http.StatusBadRequest, msg)
+		return
+	}
+
+	limitReached, err := data.PollLimitReached(adminUser)
+	if err != nil {
+		log.Printf("Error checking poll limit: %v", err)
+		apiError(c, http.StatusInternalServerError, "Failed to create poll in database.")
+		return
+	}
+	if limitReached {
+		apiError(c, http.StatusConflict, "You have reached the maximum number of polls.")
+		return
+	}
+
+	randString, _ := utils.RandString(16)
+	poll := &data.Poll{
+		CurrentQuestionIndex: -1, // No question active yet
+		Status:               "setup",
+		AdminUserID:          int(adminUser.ID),
+		InviteID:             randString,
+	}
+	applyPollSaveRequest(poll, &req)
+
+	if err := data.DB.Save(poll).Error; err != nil {
+		log.Printf("Error creating poll in DB: %v", err)
+		apiError(c, http.StatusInternalServerError, "Failed to create poll in database.")
+		return
+	}
+	c.JSON(http.StatusCreated, toAPIPoll(poll, true))
+}
+
+func APIPollsUpdate(c *gin.Context) {
+	adminUser := apiAdminUser(c)
+	if adminUser == nil {
+		return
+	}
+	poll := apiOwnedPoll(c, adminUser)
+	if poll == nil {
+		return
+	}
+
+	var req PollSaveRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apiError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.Title == "" {
+		apiError(c, http.StatusBadRequest, "title is required.")
+		return
+	}
+	if msg := req.resultsVisibilityError(); msg != "" {
+		apiError(c, http.StatusBadRequest, msg)
+		return
+	}
+
+	applyPollSaveRequest(poll, &req)
+	if err := data.DB.Save(poll).Error; err != nil {
+		log.Printf("Error updating poll in DB: %v", err)
+		apiError(c, http.StatusInternalServerError, "Failed to update poll in database.")
+		return
+	}
+
+	updated, err := data.GetPollAndDetailsForAdmin(poll.ID)
+	if err != nil {
+		apiError(c, http.StatusInternalServerError, "Failed to retrieve poll.")
+		return
+	}
+	c.JSON(http.StatusOK, toAPIPoll(updated, true))
+}
+
+func APIPollsDelete(c *gin.Context) {
+	adminUser := apiAdminUser(c)
+	if adminUser == nil {
+		return
+	}
+	poll := apiOwnedPoll(c, adminUser)
+	if poll == nil {
+		return
+	}
+
+	if err := data.SoftDeletePoll(poll.ID); err != nil {
+		log.Printf("Error deleting poll %d: %v", poll.ID, err)
+		apiError(c, http.StatusInternalServerError, "Failed to delete poll.")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func APIPollsCopy(c *gin.Context) {
+	adminUser := apiAdminUser(c)
+	if adminUser == nil {
+		return
+	}
+	poll := apiOwnedPoll(c, adminUser)
+	if poll == nil {
+		return
+	}
+
+	limitReached, err := data.PollLimitReached(adminUser)
+	if err != nil {
+		log.Printf("Error checking poll limit: %v", err)
+		apiError(c, http.StatusInternalServerError, "Failed to copy poll.")
+		return
+	}
+	if limitReached {
+		apiError(c, http.StatusConflict, "You have reached the maximum number of polls.")
+		return
+	}
+
+	pollCopy := data.CopyPollFor(poll, adminUser)
+	pollCopy.Title = "Copy of " + poll.Title
+	if err := data.DB.Save(pollCopy).Error; err != nil {
+		log.Printf("Error copying poll %d: %v", poll.ID, err)
+		apiError(c, http.StatusInternalServerError, "Failed to copy poll.")
+		return
+	}
+	c.JSON(http.StatusCreated, toAPIPoll(pollCopy, true))
+}
+
+func APIPollRunsList(c *gin.Context) {
+	adminUser := apiAdminUser(c)
+	if adminUser == nil {
+		return
+	}
+	poll := apiOwnedPoll(c, adminUser)
+	if poll == nil {
+		return
+	}
+
+	runs, err := data.GetPollRuns(poll.ID)
+	if err != nil {
+		apiError(c, http.StatusInternalServerError, "Failed to retrieve runs.")
+		return
+	}
+	result := make([]APIRun, 0, len(runs))
+	for _, r := range runs {
+		result = append(result, APIRun{ID: r.ID, StartedAt: r.StartedAt, FinishedAt: r.FinishedAt})
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+func APIPollResults(c *gin.Context) {
+	adminUser := apiAdminUser(c)
+	if adminUser == nil {
+		return
+	}
+	poll := apiOwnedPoll(c, adminUser)
+	if poll == nil {
+		return
+	}
+
+	runID, ok := apiRunID(c, poll)
+	if !ok {
+		return
+	}
+
+	questions, err := data.GetPollResults(poll.ID, runID)
+	if err != nil {
+		apiError(c, http.StatusInternalServerError, "Failed to retrieve results.")
+		return
+	}
+	c.JSON(http.StatusOK, APIResults{PollID: poll.ID, RunID: runID, Questions: questions})
+}
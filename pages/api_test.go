@@ -0,0 +1,168 @@
+package pages
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/aspcodenet/systementorlivepolls/data"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestAPIRouter(email string) *gin.Engine {
+	router := newTestRouter(email)
+	api := router.Group("/api/v1")
+	api.GET("/polls", APIPollsList)
+	api.POST("/polls", APIPollsCreate)
+	api.POST("/polls/import", APIPollsImport)
+	api.GET("/polls/:pollID", APIPollsGet)
+	api.PUT("/polls/:pollID", APIPollsUpdate)
+	api.DELETE("/polls/:pollID", APIPollsDelete)
+	api.POST("/polls/:pollID/copy", APIPollsCopy)
+	api.GET("/polls/:pollID/definition", APIPollDefinitionGet)
+	api.GET("/polls/:pollID/results", APIPollResults)
+	api.GET("/polls/:pollID/export/results", APIPollExportResults)
+	api.GET("/polls/:pollID/export/responses", APIPollExportResponses)
+	api.GET("/polls/:pollID/runs/:runID/export/responses", APIPollExportResponses)
+	api.GET("/polls/:pollID/questions/:questionID/chart", APIQuestionChart)
+	return router
+}
+
+func TestAPIPollsListPaginates(t *testing.T) {
+	setupTestDB(t)
+	owner := createTestAdmin(t, "owner@example.com")
+	other := createTestAdmin(t, "other@example.com")
+	createTestPoll(t, owner, "First")
+	createTestPoll(t, owner, "Second")
+	createTestPoll(t, owner, "Third")
+	createTestPoll(t, other, "Not mine")
+
+	w := doRequest(newTestAPIRouter(owner.Email), http.MethodGet, "/api/v1/polls?page=2&pageSize=2", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var list APIPollList
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	assert.Equal(t, int64(3), list.Total)
+	assert.Equal(t, 2, list.Page)
+	if assert.Len(t, list.Polls, 1) {
+		assert.Equal(t, "Third", list.Polls[0].Title)
+	}
+
+	w = doRequest(newTestAPIRouter(owner.Email), http.MethodGet, "/api/v1/polls?pageSize=1000", "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestAPIPollsCreateAndGet(t *testing.T) {
+	setupTestDB(t)
+	owner := createTestAdmin(t, "owner@example.com")
+	router := newTestAPIRouter(owner.Email)
+
+	body := `{"title":"From API","questions":[{"text":"Pick one","type":"single-select","options":[{"text":"Yes"},{"text":"No"}]}]}`
+	w := doRequest(router, http.MethodPost, "/api/v1/polls", body)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var created APIPoll
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.NotZero(t, created.ID)
+	assert.Equal(t, "setup", created.Status)
+
+	w = doRequest(router, http.MethodGet, "/api/v1/polls/"+idStr(created.ID), "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var fetched APIPoll
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &fetched))
+	if assert.Len(t, fetched.Questions, 1) {
+		assert.Len(t, fetched.Questions[0].Options, 2)
+	}
+
+	w = doRequest(router, http.MethodPost, "/api/v1/polls", `{"questions":[]}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestAPIPollsCreateRespectsPollLimit(t *testing.T) {
+	setupTestDB(t)
+	owner := createTestAdmin(t, "owner@example.com")
+	for i := 0; i < data.MaxPollsPerAdmin; i++ {
+		createTestPoll(t, owner, "Poll "+idStr(uint(i)))
+	}
+	router := newTestAPIRouter(owner.Email)
+
+	w := doRequest(router, http.MethodPost, "/api/v1/polls", `{"title":"One too many"}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), "maximum number of polls")
+	assert.Equal(t, int64(data.MaxPollsPerAdmin), countPolls(t))
+}
+
+func TestAPIPollsResultsVisibility(t *testing.T) {
+	setupTestDB(t)
+	owner := createTestAdmin(t, "owner@example.com")
+	router := newTestAPIRouter(owner.Email)
+
+	body := `{"title":"Quiz","resultsVisibility":"never","questions":[{"text":"Pick one","type":"single-select","resultsVisibility":"live","options":[{"text":"Yes"}]}]}`
+	w := doRequest(router, http.MethodPost, "/api/v1/polls", body)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var created APIPoll
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.Equal(t, data.ResultsNever, created.ResultsVisibility)
+	if assert.Len(t, created.Questions, 1) {
+		assert.Equal(t, data.ResultsLive, created.Questions[0].ResultsVisibility)
+	}
+
+	for _, body := range []string{
+		`{"title":"Quiz","resultsVisibility":"sometimes"}`,
+		`{"title":"Quiz","questions":[{"text":"Pick one","type":"single-select","resultsVisibility":"always","options":[{"text":"Yes"}]}]}`,
+	} {
+		w = doRequest(router, http.MethodPost, "/api/v1/polls", body)
+		assert.Equal(t, http.StatusBadRequest, w.Code, body)
+		assert.Contains(t, w.Body.String(), "resultsVisibility must be never, after_reveal or live.")
+	}
+}
+
+func TestAPIPollsRejectsOtherUsersPoll(t *testing.T) {
+	setupTestDB(t)
+	owner := createTestAdmin(t, "owner@example.com")
+	intruder := createTestAdmin(t, "intruder@example.com")
+	poll := createTestPoll(t, owner, "Private")
+	router := newTestAPIRouter(intruder.Email)
+
+	for _, tc := range []struct{ method, path, body string }{
+		{http.MethodGet, "/api/v1/polls/" + idStr(poll.ID), ""},
+		{http.MethodPut, "/api/v1/polls/" + idStr(poll.ID), `{"title":"Hacked"}`},
+		{http.MethodDelete, "/api/v1/polls/" + idStr(poll.ID), ""},
+		{http.MethodPost, "/api/v1/polls/" + idStr(poll.ID) + "/copy", ""},
+		{http.MethodGet, "/api/v1/polls/" + idStr(poll.ID) + "/results", ""},
+	} {
+		w := doRequest(router, tc.method, tc.path, tc.body)
+		assert.Equal(t, http.StatusForbidden, w.Code, "%s %s", tc.method, tc.path)
+		var apiErr APIError
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &apiErr))
+		assert.NotEmpty(t, apiErr.Error)
+	}
+
+	var stored data.Poll
+	data.DB.First(&stored, poll.ID)
+	assert.Equal(t, "Private", stored.Title)
+}
+
+func TestAPIPollResults(t *testing.T) {
+	setupTestDB(t)
+	owner := createTestAdmin(t, "owner@example.com")
+	poll := createTestPoll(t, owner, "Results")
+	q := poll.Questions[0]
+	data.DB.Create(&data.Vote{QuestionID: q.ID, OptionID: q.Options[0].ID, VoterID: "v1"})
+	data.DB.Create(&data.Vote{QuestionID: q.ID, OptionID: q.Options[0].ID, VoterID: "v2"})
+	data.DB.Create(&data.Vote{QuestionID: q.ID, OptionID: q.Options[1].ID, VoterID: "v3"})
+
+	w := doRequest(newTestAPIRouter(owner.Email), http.MethodGet, "/api/v1/polls/"+idStr(poll.ID)+"/results", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var results APIResults
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &results))
+	if assert.Len(t, results.Questions, 1) {
+		qr := results.Questions[0]
+		assert.Equal(t, 3, qr.TotalVotes)
+		assert.Equal(t, 3, qr.Respondents)
+		assert.Equal(t, 2, qr.Options[0].Count)
+		assert.InDelta(t, 66.67, qr.Options[0].Percentage, 0.01)
+	}
+}
@@ -0,0 +1,120 @@
+package pages
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/aspcodenet/systementorlivepolls/charts"
+	"github.com/aspcodenet/systementorlivepolls/data"
+	"github.com/gin-gonic/gin"
+)
+
+// Chart image formats, selected with the format query parameter.
+const (
+	chartSVG = "svg"
+	chartPNG = "png"
+)
+
+var chartContentTypes = map[string]string{
+	chartSVG: "image/svg+xml",
+	chartPNG: "image/png",
+}
+
+// chartOptions reads the type, values, theme, width and height query parameters. Writes an
+// error response and returns false if one of them is invalid.
+func chartOptions(c *gin.Context) (charts.Options, bool) {
+	opts := charts.Options{
+		Kind:  charts.Kind(c.Query("type")),
+		Theme: charts.Theme(c.DefaultQuery("theme", string(charts.Light))),
+	}
+	if opts.Kind != "" && !slices.Contains(charts.Kinds, opts.Kind) {
+		apiError(c, http.StatusBadRequest, "type must be bar, hbar or pie.")
+		return opts, false
+	}
+	if !slices.Contains(charts.Themes, opts.Theme) {
+		apiError(c, http.StatusBadRequest, "theme must be light or dark.")
+		return opts, false
+	}
+	switch c.DefaultQuery("values", "count") {
+	case "count":
+	case "percent":
+		opts.Percentages = true
+	default:
+		apiError(c, http.StatusBadRequest, "values must be count or percent.")
+		return opts, false
+	}
+	for _, size := range []struct {
+		param string
+		value *int
+		def   int
+	}{{"width", &opts.Width, charts.DefaultWidth}, {"height", &opts.Height, charts.DefaultHeight}} {
+		v, err := strconv.Atoi(c.DefaultQuery(size.param, strconv.Itoa(size.def)))
+		if err != nil || v < charts.MinSize || v > charts.MaxSize {
+			apiError(c, http.StatusBadRequest, fmt.Sprintf("%s must be between %d and %d.", size.param, charts.MinSize, charts.MaxSize))
+			return opts, false
+		}
+		*size.value = v
+	}
+	return opts, true
+}
+
+// APIQuestionChart renders the results of one question as an SVG or PNG chart.
+func APIQuestionChart(c *gin.Context) {
+	adminUser := apiAdminUser(c)
+	if adminUser == nil {
+		return
+	}
+	poll := apiOwnedPoll(c, adminUser)
+	if poll == nil {
+		return
+	}
+	runID, ok := apiRunID(c, poll)
+	if !ok {
+		return
+	}
+	questionID, err := strconv.Atoi(c.Param("questionID"))
+	if err != nil {
+		apiError(c, http.StatusBadRequest, "Invalid question id.")
+		return
+	}
+	format := c.DefaultQuery("format", chartSVG)
+	if _, ok := chartContentTypes[format]; !ok {
+		apiError(c, http.StatusBadRequest, "format must be svg or png.")
+		return
+	}
+	opts, ok := chartOptions(c)
+	if !ok {
+		return
+	}
+
+	questions, err := data.GetPollResults(poll.ID, runID)
+	if err != nil {
+		log.Printf("Error retrieving results of poll %d for chart: %v", poll.ID, err)
+		apiError(c, http.StatusInternalServerError, "Failed to retrieve results.")
+		return
+	}
+	i := slices.IndexFunc(questions, func(q data.QuestionResult) bool { return q.QuestionID == uint(questionID) })
+	if i < 0 {
+		apiError(c, http.StatusNotFound, "Question not found.")
+		return
+	}
+
+	var buf bytes.Buffer
+	if format == chartPNG {
+		err = charts.PNG(&buf, &questions[i], opts)
+	} else {
+		err = charts.SVG(&buf, &questions[i], opts)
+	}
+	if err != nil {
+		log.Printf("Error drawing chart for question %d: %v", questionID, err)
+		apiError(c, http.StatusInternalServerError, "Failed to draw chart.")
+		return
+	}
+	name := exportFileName(poll.ID, runID, fmt.Sprintf("question-%d", questionID), format)
+	c.Header("Content-Disposition", fmt.Sprintf(`inline; filename="%s"`, name))
+	c.Data(http.StatusOK, chartContentTypes[format], buf.Bytes())
+}
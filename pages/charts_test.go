@@ -0,0 +1,51 @@
+package pages
+
+import (
+	"image/png"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPIQuestionChart(t *testing.T) {
+	setupTestDB(t)
+	owner := createTestAdmin(t, "owner@example.com")
+	poll := createTestPoll(t, owner, "Chart")
+	createTestVotes(t, poll, 0)
+	router := newTestAPIRouter(owner.Email)
+	path := "/api/v1/polls/" + idStr(poll.ID) + "/questions/" + idStr(poll.Questions[0].ID) + "/chart"
+
+	w := doRequest(router, http.MethodGet, path+"?values=percent&theme=dark", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "image/svg+xml", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), ">75%<")
+	assert.Contains(t, w.Body.String(), ">25%<")
+
+	w = doRequest(router, http.MethodGet, path+"?format=png&type=pie&width=300&height=200", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `inline; filename="poll-`+idStr(poll.ID)+`-question-`+idStr(poll.Questions[0].ID)+`.png"`, w.Header().Get("Content-Disposition"))
+	img, err := png.Decode(w.Body)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 300, img.Bounds().Dx())
+	}
+}
+
+func TestAPIQuestionChartErrors(t *testing.T) {
+	setupTestDB(t)
+	owner := createTestAdmin(t, "owner@example.com")
+	other := createTestAdmin(t, "other@example.com")
+	poll := createTestPoll(t, owner, "Chart")
+	otherPoll := createTestPoll(t, other, "Other")
+	router := newTestAPIRouter(owner.Email)
+	path := "/api/v1/polls/" + idStr(poll.ID) + "/questions/" + idStr(poll.Questions[0].ID) + "/chart"
+
+	for _, query := range []string{"?format=gif", "?type=donut", "?values=votes", "?theme=blue", "?width=10"} {
+		w := doRequest(router, http.MethodGet, path+query, "")
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+	w := doRequest(router, http.MethodGet, "/api/v1/polls/"+idStr(poll.ID)+"/questions/"+idStr(otherPoll.Questions[0].ID)+"/chart", "")
+	assert.Equal(t, http.StatusNotFound, w.Code, "questions of other polls are not found")
+	w = doRequest(router, http.MethodGet, "/api/v1/polls/"+idStr(otherPoll.ID)+"/questions/"+idStr(otherPoll.Questions[0].ID)+"/chart", "")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
@@ -0,0 +1,124 @@
+package pages
+
+import (
+	"crypto/subtle"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/aspcodenet/systementorlivepolls/utils"
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+)
+
+// The CSRF token is kept in the session and sent back by the admin pages in a hidden form field,
+// or by scripts in a header. The templates get it as CSRFToken, head.html also puts it in a
+// csrf-token meta tag for the scripts.
+const (
+	csrfSessionKey = "CSRF-TOKEN"
+	csrfContextKey = "CSRF-TOKEN"
+	CSRFFormField  = "csrf_token"
+	CSRFHeader     = "X-CSRF-Token"
+)
+
+// CSRFProtect gives the session a CSRF token and rejects POST, PUT, PATCH and DELETE requests
+// that do not send it back. Use it on the routes that are authenticated with the session cookie.
+func CSRFProtect(c *gin.Context) {
+	session := sessions.Default(c)
+	token, _ := session.Get(csrfSessionKey).(string)
+	if token == "" {
+		var err error
+		token, err = utils.RandString(32)
+		if err != nil {
+			log.Printf("Error creating CSRF token: %v", err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		session.Set(csrfSessionKey, token)
+		session.Save()
+	}
+	c.Set(csrfContextKey, token)
+
+	if !isSafeMethod(c.Request.Method) && !ValidCSRFToken(c) {
+		rejectCSRF(c, "The form has expired. Go back, reload the page and try again.")
+		return
+	}
+	c.Next()
+}
+
+// ValidCSRFToken reports whether the request sends the session's CSRF token in the csrf_token
+// form field or the X-CSRF-Token header.
+func ValidCSRFToken(c *gin.Context) bool {
+	token, _ := sessions.Default(c).Get(csrfSessionKey).(string)
+	if token == "" {
+		return false
+	}
+	given := c.GetHeader(CSRFHeader)
+	if given == "" && !strings.HasPrefix(c.ContentType(), gin.MIMEJSON) {
+		given = c.PostForm(CSRFFormField)
+	}
+	return subtle.ConstantTimeCompare([]byte(given), []byte(token)) == 1
+}
+
+// SameOriginRequired rejects state-changing requests whose Origin, or Referer when the browser
+// sends no Origin, is not this server. Requests with neither are rejected too. It guards the
+// JSON endpoints the admin scripts call, on top of CSRFProtect.
+func SameOriginRequired(c *gin.Context) {
+	if isSafeMethod(c.Request.Method) {
+		c.Next()
+		return
+	}
+	source := c.GetHeader("Origin")
+	if source == "" || source == "null" {
+		source = c.GetHeader("Referer")
+	}
+	if !IsSameOrigin(c.Request, source) {
+		rejectCSRF(c, "The request did not come from this site.")
+		return
+	}
+	c.Next()
+}
+
+// csrfToken returns the CSRF token for the templates, empty when CSRFProtect is not used on the route.
+func csrfToken(c *gin.Context) string {
+	return c.GetString(csrfContextKey)
+}
+
+func isSafeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+// IsSameOrigin reports whether source, an Origin or Referer header, has the scheme and host the
+// request was sent to, or those of PUBLIC_BASE_URL for servers behind a proxy that rewrites the host.
+func IsSameOrigin(r *http.Request, source string) bool {
+	if source == "" {
+		return false
+	}
+	u, err := url.Parse(source)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	if strings.EqualFold(u.Scheme, requestScheme(r)) && strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	if base := os.Getenv("PUBLIC_BASE_URL"); base != "" {
+		if b, err := url.Parse(base); err == nil && b.Host != "" {
+			return strings.EqualFold(u.Scheme, b.Scheme) && strings.EqualFold(u.Host, b.Host)
+		}
+	}
+	return false
+}
+
+// rejectCSRF answers a request that failed the CSRF checks with a 403, as JSON to scripts and as
+// a page to browsers.
+func rejectCSRF(c *gin.Context, message string) {
+	if strings.HasPrefix(c.ContentType(), gin.MIMEJSON) || strings.Contains(c.GetHeader("Accept"), gin.MIMEJSON) {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": message})
+		return
+	}
+	currentUser, _ := sessions.Default(c).Get(Userkey).(string)
+	c.HTML(http.StatusForbidden, "notfound.html", gin.H{"CurrentUser": currentUser, "Title": "Request rejected", "Message": message})
+	c.Abort()
+}
@@ -0,0 +1,123 @@
+package pages
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/aspcodenet/systementorlivepolls/data"
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testCSRFToken = "test-csrf-token"
+
+// newTestCSRFRouter registers some admin pages behind CSRFProtect, with testCSRFToken in the session.
+func newTestCSRFRouter(email string) *gin.Engine {
+	router := newTestRouter(email)
+	router.LoadHTMLGlob("../templates/**")
+	router.Use(func(c *gin.Context) {
+		sessions.Default(c).Set(csrfSessionKey, testCSRFToken)
+		c.Next()
+	})
+	admin := router.Group("/admin", AdminRequired, CSRFProtect)
+	admin.POST("/polls/save", SameOriginRequired, EditorRequired, AdminPollsSavePOST)
+	admin.GET("/polls/delete/:pollID", EditorRequired, PollRequired(data.AccessOwner), AdminPollsDelete)
+	admin.POST("/polls/delete/:pollID", EditorRequired, PollRequired(data.AccessOwner), AdminPollsDeletePOST)
+	admin.POST("/polls/copy/:pollID", EditorRequired, PollRequired(data.AccessEdit), AdminPollsCopyPOST)
+	return router
+}
+
+func doSaveRequest(router *gin.Engine, body string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/admin/polls/save", strings.NewReader(body))
+	req.Host = "polls.example.com"
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Forwarded-Proto", "https") // Served over https behind a proxy
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestCSRFProtectRejectsFormsWithoutToken(t *testing.T) {
+	setupTestDB(t)
+	owner := createTestAdmin(t, "owner@example.com")
+	poll := createTestPoll(t, owner, "Quiz")
+	router := newTestCSRFRouter(owner.Email)
+
+	for _, token := range []string{"", "wrong-token"} {
+		w := doFormRequest(router, "/admin/polls/delete/"+idStr(poll.ID), url.Values{"name": {"Quiz"}, CSRFFormField: {token}})
+		assert.Equal(t, http.StatusForbidden, w.Code, token)
+		assert.Contains(t, w.Body.String(), "The form has expired")
+		w = doFormRequest(router, "/admin/polls/copy/"+idStr(poll.ID), url.Values{"title": {"Stolen"}, CSRFFormField: {token}})
+		assert.Equal(t, http.StatusForbidden, w.Code, token)
+	}
+	assert.Equal(t, int64(1), countPolls(t), "the poll is neither deleted nor copied")
+}
+
+func TestCSRFProtectAcceptsFormsWithToken(t *testing.T) {
+	setupTestDB(t)
+	owner := createTestAdmin(t, "owner@example.com")
+	poll := createTestPoll(t, owner, "Quiz")
+	router := newTestCSRFRouter(owner.Email)
+
+	// The token is in the form of the page
+	w := doRequest(router, "GET", "/admin/polls/delete/"+idStr(poll.ID), "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `name="csrf_token" value="`+testCSRFToken+`"`)
+	assert.Contains(t, w.Body.String(), `<meta name="csrf-token" content="`+testCSRFToken+`">`)
+
+	w = doFormRequest(router, "/admin/polls/delete/"+idStr(poll.ID), url.Values{"name": {"Quiz"}, CSRFFormField: {testCSRFToken}})
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, int64(0), countPolls(t), "the poll is deleted")
+}
+
+func TestSaveRequiresTokenAndSameOrigin(t *testing.T) {
+	setupTestDB(t)
+	owner := createTestAdmin(t, "owner@example.com")
+	router := newTestCSRFRouter(owner.Email)
+	body := `{"title":"New poll","questions":[{"text":"Q1","type":"single","options":[{"text":"A"},{"text":"B"}]}]}`
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		status  int
+	}{
+		{"no origin", map[string]string{CSRFHeader: testCSRFToken}, http.StatusForbidden},
+		{"other origin", map[string]string{CSRFHeader: testCSRFToken, "Origin": "https://evil.example.com"}, http.StatusForbidden},
+		{"other referer", map[string]string{CSRFHeader: testCSRFToken, "Referer": "https://evil.example.com/page"}, http.StatusForbidden},
+		{"no token", map[string]string{"Origin": "https://polls.example.com"}, http.StatusForbidden},
+		{"http origin", map[string]string{CSRFHeader: testCSRFToken, "Origin": "http://polls.example.com"}, http.StatusForbidden},
+		{"http referer", map[string]string{CSRFHeader: testCSRFToken, "Referer": "http://polls.example.com/admin/polls/new"}, http.StatusForbidden},
+		{"origin over http", map[string]string{CSRFHeader: testCSRFToken, "Origin": "https://polls.example.com", "X-Forwarded-Proto": "http"}, http.StatusForbidden},
+		{"origin and token", map[string]string{CSRFHeader: testCSRFToken, "Origin": "https://polls.example.com"}, http.StatusOK},
+		{"referer and token", map[string]string{CSRFHeader: testCSRFToken, "Referer": "https://polls.example.com/admin/polls/new"}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		w := doSaveRequest(router, body, tt.headers)
+		assert.Equal(t, tt.status, w.Code, tt.name)
+		if tt.status == http.StatusForbidden {
+			assert.Contains(t, w.Body.String(), `"error"`, tt.name)
+		}
+	}
+	assert.Equal(t, int64(2), countPolls(t))
+}
+
+func TestSameOriginAcceptsPublicBaseURL(t *testing.T) {
+	setupTestDB(t)
+	owner := createTestAdmin(t, "owner@example.com")
+	router := newTestCSRFRouter(owner.Email)
+	t.Setenv("PUBLIC_BASE_URL", "https://live.example.com/")
+	body := `{"title":"New poll","questions":[{"text":"Q1","type":"single","options":[{"text":"A"},{"text":"B"}]}]}`
+
+	w := doSaveRequest(router, body, map[string]string{CSRFHeader: testCSRFToken, "Origin": "https://live.example.com"})
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = doSaveRequest(router, body, map[string]string{CSRFHeader: testCSRFToken, "Origin": "http://live.example.com"})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
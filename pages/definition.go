@@ -0,0 +1,195 @@
+package pages
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/aspcodenet/systementorlivepolls/data"
+	"github.com/gin-gonic/gin"
+)
+
+// maxDefinitionSize limits the size of an imported poll definition.
+const maxDefinitionSize = 1 << 20
+
+var definitionContentTypes = map[string]string{
+	"json": "application/json; charset=utf-8",
+	"yaml": "application/yaml; charset=utf-8",
+}
+
+var errMaxPolls = errors.New("You have reached the maximum number of polls.")
+
+// definitionFormat guesses whether an uploaded definition is JSON or YAML from its file name,
+// content type or, failing both, its first character.
+func definitionFormat(fileName, contentType string, raw []byte) string {
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".json":
+		return "json"
+	case ".yaml", ".yml":
+		return "yaml"
+	}
+	if strings.Contains(contentType, "yaml") {
+		return "yaml"
+	}
+	if strings.Contains(contentType, "json") {
+		return "json"
+	}
+	if bytes.HasPrefix(bytes.TrimSpace(raw), []byte("{")) {
+		return "json"
+	}
+	return "yaml"
+}
+
+// importPollDefinition creates a poll owned by adminUser from a definition. The returned error
+// is meant for the user.
+func importPollDefinition(adminUser *data.AdminUser, raw []byte, format string) (*data.Poll, error) {
+	def, err := data.ParsePollDefinition(raw, format)
+	if err != nil {
+		return nil, err
+	}
+
+	limitReached, err := data.PollLimitReached(adminUser)
+	if err != nil {
+		log.Printf("Error checking poll limit: %v", err)
+		return nil, errors.New("Failed to create poll in database.")
+	}
+	if limitReached {
+		return nil, errMaxPolls
+	}
+
+	poll := data.NewPollFromDefinition(def, int(adminUser.ID))
+	if err := data.DB.Save(poll).Error; err != nil {
+		log.Printf("Error importing poll: %v", err)
+		return nil, errors.New("Failed to create poll in database.")
+	}
+	return poll, nil
+}
+
+// APIPollDefinitionGet exports the definition of a poll as JSON or YAML.
+func APIPollDefinitionGet(c *gin.Context) {
+	adminUser := apiAdminUser(c)
+	if adminUser == nil {
+		return
+	}
+	poll := apiOwnedPoll(c, adminUser)
+	if poll == nil {
+		return
+	}
+
+	format := c.DefaultQuery("format", "json")
+	contentType, ok := definitionContentTypes[format]
+	if !ok {
+		apiError(c, http.StatusBadRequest, "format must be json or yaml.")
+		return
+	}
+	body, err := data.ExportPollDefinition(poll).Marshal(format)
+	if err != nil {
+		log.Printf("Error exporting definition of poll %d: %v", poll.ID, err)
+		apiError(c, http.StatusInternalServerError, "Failed to export poll.")
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="poll-%d.%s"`, poll.ID, format))
+	c.Data(http.StatusOK, contentType, body)
+}
+
+// APIPollsImport creates a new poll from a JSON or YAML definition in the request body.
+func APIPollsImport(c *gin.Context) {
+	adminUser := apiAdminUser(c)
+	if adminUser == nil {
+		return
+	}
+
+	raw, err := io.ReadAll(io.LimitReader(c.Request.Body, maxDefinitionSize+1))
+	if err != nil {
+		apiError(c, http.StatusBadRequest, "Failed to read request body.")
+		return
+	}
+	if len(raw) > maxDefinitionSize {
+		apiError(c, http.StatusRequestEntityTooLarge, "Poll definition is too large.")
+		return
+	}
+	format := c.Query("format")
+	if format == "" {
+		format = definitionFormat("", c.ContentType(), raw)
+	}
+	if _, ok := definitionContentTypes[format]; !ok {
+		apiError(c, http.StatusBadRequest, "format must be json or yaml.")
+		return
+	}
+
+	poll, err := importPollDefinition(adminUser, raw, format)
+	if errors.Is(err, errMaxPolls) {
+		apiError(c, http.StatusConflict, err.Error())
+		return
+	} else if err != nil {
+		apiError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	c.JSON(http.StatusCreated, toAPIPoll(poll, true))
+}
+
+func AdminPollsImport(c *gin.Context) {
+	adminUser := currentAdmin(c)
+
+	c.HTML(http.StatusOK, "adminpollsimport.html", gin.H{
+		"CSRFToken": csrfToken(c),
+		"title":     "Import poll",
+		"AdminUser": adminUser,
+	})
+}
+
+func AdminPollsImportPOST(c *gin.Context) {
+	adminUser := currentAdmin(c)
+
+	// An uploaded file wins over the text area
+	raw := []byte(c.PostForm("definition"))
+	fileName := ""
+	if file, err := c.FormFile("file"); err == nil {
+		if file.Size > maxDefinitionSize {
+			renderImportError(c, adminUser, string(raw), "Poll definition is too large.")
+			return
+		}
+		f, err := file.Open()
+		if err != nil {
+			renderImportError(c, adminUser, string(raw), "Failed to read the uploaded file.")
+			return
+		}
+		raw, err = io.ReadAll(f)
+		f.Close()
+		if err != nil {
+			renderImportError(c, adminUser, "", "Failed to read the uploaded file.")
+			return
+		}
+		fileName = file.Filename
+	} else if len(raw) > maxDefinitionSize {
+		renderImportError(c, adminUser, "", "Poll definition is too large.")
+		return
+	}
+	if len(bytes.TrimSpace(raw)) == 0 {
+		renderImportError(c, adminUser, "", "Paste a poll definition or choose a file.")
+		return
+	}
+
+	poll, err := importPollDefinition(adminUser, raw, definitionFormat(fileName, "", raw))
+	if err != nil {
+		renderImportError(c, adminUser, string(raw), err.Error())
+		return
+	}
+	c.Redirect(302, "/admin/polls/edit/"+strconv.Itoa(int(poll.ID)))
+}
+
+func renderImportError(c *gin.Context, adminUser *data.AdminUser, definition, message string) {
+	c.HTML(http.StatusBadRequest, "adminpollsimport.html", gin.H{
+		"CSRFToken":  csrfToken(c),
+		"title":      "Import poll",
+		"AdminUser":  adminUser,
+		"Definition": definition,
+		"Errors":     strings.Split(message, "\n"),
+	})
+}
@@ -0,0 +1,105 @@
+package pages
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/aspcodenet/systementorlivepolls/data"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPIPollDefinitionExportAndImport(t *testing.T) {
+	setupTestDB(t)
+	owner := createTestAdmin(t, "owner@example.com")
+	other := createTestAdmin(t, "other@example.com")
+	poll := createTestPoll(t, owner, "Quiz")
+	assert.NoError(t, data.DB.Model(&poll.Questions[0].Options[1]).Update("correct", true).Error)
+
+	w := doRequest(newTestAPIRouter(owner.Email), http.MethodGet, "/api/v1/polls/"+idStr(poll.ID)+"/definition?format=yaml", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "yaml")
+	assert.Contains(t, w.Body.String(), "title: Quiz")
+	assert.NotContains(t, w.Body.String(), poll.InviteID, "definitions should not carry identity")
+
+	exported := w.Body.String()
+
+	// Another admin imports the exported YAML as their own poll
+	router := newTestAPIRouter(other.Email)
+	w = doRequest(router, http.MethodPost, "/api/v1/polls/import?format=yaml", "")
+	assert.Equal(t, http.StatusBadRequest, w.Code, "an empty body is not a valid definition")
+	w = doRequest(router, http.MethodPost, "/api/v1/polls/import?format=yaml", exported)
+	assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	var created APIPoll
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.Equal(t, "Quiz", created.Title)
+	assert.Equal(t, "setup", created.Status)
+	assert.NotEqual(t, poll.ID, created.ID)
+	if assert.Len(t, created.Questions, 1) {
+		assert.Len(t, created.Questions[0].Options, 2)
+	}
+
+	var imported data.Poll
+	data.DB.First(&imported, created.ID)
+	assert.Equal(t, int(other.ID), imported.AdminUserID)
+	assert.Equal(t, []bool{false, true}, optionCorrectMarks(t, created.ID), "the correct answer survives export and import")
+
+	// And a copy through the API
+	w = doRequest(router, http.MethodPost, "/api/v1/polls/"+idStr(created.ID)+"/copy", "")
+	assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var copied APIPoll
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &copied))
+	assert.Equal(t, []bool{false, true}, optionCorrectMarks(t, copied.ID))
+}
+
+// optionCorrectMarks returns the Correct marks of the options of a poll, in order.
+func optionCorrectMarks(t *testing.T, pollID uint) []bool {
+	t.Helper()
+	poll, err := data.GetPollAndDetailsForAdmin(pollID)
+	if !assert.NoError(t, err) {
+		return nil
+	}
+	marks := []bool{}
+	for _, q := range poll.Questions {
+		for _, o := range q.Options {
+			marks = append(marks, o.Correct)
+		}
+	}
+	return marks
+}
+
+func TestAPIPollsImportValidates(t *testing.T) {
+	setupTestDB(t)
+	owner := createTestAdmin(t, "owner@example.com")
+
+	w := doRequest(newTestAPIRouter(owner.Email), http.MethodPost, "/api/v1/polls/import",
+		`{"version":1,"title":"Bad","questions":[{"text":"Q","type":"ranking","options":["A"]}]}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "questions[0].type")
+
+	var count int64
+	data.DB.Model(&data.Poll{}).Count(&count)
+	assert.Equal(t, int64(0), count, "nothing should be created from an invalid definition")
+}
+
+func TestAdminPollsImportRejectsLargeDefinition(t *testing.T) {
+	setupTestDB(t)
+	owner := createTestAdmin(t, "owner@example.com")
+	router := newTestRouter(owner.Email)
+	router.LoadHTMLGlob("../templates/**")
+	router.POST("/admin/polls/import", AdminRequired, AdminPollsImportPOST)
+	definition := `{"version":1,"title":"Pasted","questions":[{"text":"Q","type":"single-select","options":["A","B"]}]}`
+
+	// Valid, but padded past the size limit
+	w := doFormRequest(router, "/admin/polls/import", url.Values{"definition": {definition + strings.Repeat(" ", maxDefinitionSize)}})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "Poll definition is too large.")
+	assert.Equal(t, int64(0), countPolls(t))
+
+	w = doFormRequest(router, "/admin/polls/import", url.Values{"definition": {definition}})
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, int64(1), countPolls(t))
+}
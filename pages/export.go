@@ -0,0 +1,187 @@
+package pages
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aspcodenet/systementorlivepolls/data"
+	"github.com/aspcodenet/systementorlivepolls/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// Export formats, selected with the format query parameter.
+const (
+	exportCSV  = "csv"
+	exportJSON = "json"
+	exportXLSX = "xlsx"
+)
+
+var exportContentTypes = map[string]string{
+	exportCSV:  "text/csv; charset=utf-8",
+	exportJSON: "application/json; charset=utf-8",
+	exportXLSX: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+}
+
+// APIResponses is the JSON export of the raw responses of a poll.
+type APIResponses struct {
+	PollID    uint               `json:"pollId"`
+	RunID     uint               `json:"runId,omitempty"`
+	Responses []data.RawResponse `json:"responses"`
+}
+
+var resultsHeader = []interface{}{"Question ID", "Question", "Type", "Option ID", "Option", "Votes", "Percentage", "Question total votes", "Question respondents"}
+var responsesHeader = []interface{}{"Run ID", "Voter ID", "Question ID", "Question", "Option ID", "Option", "Voted at"}
+
+func resultRows(questions []data.QuestionResult) [][]interface{} {
+	rows := [][]interface{}{resultsHeader}
+	for _, q := range questions {
+		for _, o := range q.Options {
+			rows = append(rows, []interface{}{q.QuestionID, q.Text, q.Type, o.OptionID, o.Text, o.Count, roundPercentage(o.Percentage), q.TotalVotes, q.Respondents})
+		}
+	}
+	return rows
+}
+
+func responseRows(responses []data.RawResponse) [][]interface{} {
+	rows := [][]interface{}{responsesHeader}
+	for _, r := range responses {
+		rows = append(rows, []interface{}{r.PollRunID, r.VoterID, r.QuestionID, r.QuestionText, r.OptionID, r.OptionText, r.VotedAt.UTC()})
+	}
+	return rows
+}
+
+func roundPercentage(p float64) float64 {
+	rounded, _ := strconv.ParseFloat(strconv.FormatFloat(p, 'f', 2, 64), 64)
+	return rounded
+}
+
+// exportFormat reads the format query parameter, defaulting to CSV. Writes an error response and
+// returns false if the format is unknown.
+func exportFormat(c *gin.Context) (string, bool) {
+	format := c.DefaultQuery("format", exportCSV)
+	if _, ok := exportContentTypes[format]; !ok {
+		apiError(c, http.StatusBadRequest, "format must be csv, json or xlsx.")
+		return "", false
+	}
+	return format, true
+}
+
+// exportFileName names downloads like poll-12-results.csv or poll-12-run-3-responses.xlsx.
+func exportFileName(pollID, runID uint, kind, format string) string {
+	if runID != 0 {
+		return fmt.Sprintf("poll-%d-run-%d-%s.%s", pollID, runID, kind, format)
+	}
+	return fmt.Sprintf("poll-%d-%s.%s", pollID, kind, format)
+}
+
+// spreadsheetText keeps a spreadsheet that opens the CSV export from running text as a formula:
+// voter IDs and question and option texts starting with =, +, -, @, tab or carriage return get a
+// leading '. The XLSX export needs no escaping, it stores text as inline strings that are never
+// run as formulas.
+func spreadsheetText(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// writeExport sends rows as CSV or XLSX, or jsonBody as JSON, as a file download.
+func writeExport(c *gin.Context, format, fileName, sheetName string, rows [][]interface{}, jsonBody interface{}) {
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, fileName))
+	if format == exportJSON {
+		c.JSON(http.StatusOK, jsonBody)
+		return
+	}
+	if format == exportXLSX {
+		var buf bytes.Buffer
+		if err := utils.WriteXLSX(&buf, []utils.XLSXSheet{{Name: sheetName, Rows: rows}}); err != nil {
+			log.Printf("Error writing %s: %v", fileName, err)
+			apiError(c, http.StatusInternalServerError, "Failed to create export.")
+			return
+		}
+		c.Data(http.StatusOK, exportContentTypes[exportXLSX], buf.Bytes())
+		return
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	for _, row := range rows {
+		record := make([]string, len(row))
+		for i, value := range row {
+			if t, ok := value.(time.Time); ok {
+				record[i] = t.Format(time.RFC3339)
+			} else if text, ok := value.(string); ok {
+				record[i] = spreadsheetText(text)
+			} else {
+				record[i] = fmt.Sprint(value)
+			}
+		}
+		w.Write(record)
+	}
+	w.Flush()
+	c.Data(http.StatusOK, exportContentTypes[exportCSV], buf.Bytes())
+}
+
+// APIPollExportResults exports the vote counts, percentages and totals per question and option.
+func APIPollExportResults(c *gin.Context) {
+	adminUser := apiAdminUser(c)
+	if adminUser == nil {
+		return
+	}
+	poll := apiOwnedPoll(c, adminUser)
+	if poll == nil {
+		return
+	}
+	runID, ok := apiRunID(c, poll)
+	if !ok {
+		return
+	}
+	format, ok := exportFormat(c)
+	if !ok {
+		return
+	}
+
+	questions, err := data.GetPollResults(poll.ID, runID)
+	if err != nil {
+		log.Printf("Error exporting results of poll %d: %v", poll.ID, err)
+		apiError(c, http.StatusInternalServerError, "Failed to retrieve results.")
+		return
+	}
+	writeExport(c, format, exportFileName(poll.ID, runID, "results", format), "Results",
+		resultRows(questions), APIResults{PollID: poll.ID, RunID: runID, Questions: questions})
+}
+
+// APIPollExportResponses exports every vote with its voter, question, option and time.
+func APIPollExportResponses(c *gin.Context) {
+	adminUser := apiAdminUser(c)
+	if adminUser == nil {
+		return
+	}
+	poll := apiOwnedPoll(c, adminUser)
+	if poll == nil {
+		return
+	}
+	runID, ok := apiRunID(c, poll)
+	if !ok {
+		return
+	}
+	format, ok := exportFormat(c)
+	if !ok {
+		return
+	}
+
+	responses, err := data.GetRawResponses(poll.ID, runID)
+	if err != nil {
+		log.Printf("Error exporting responses of poll %d: %v", poll.ID, err)
+		apiError(c, http.StatusInternalServerError, "Failed to retrieve responses.")
+		return
+	}
+	writeExport(c, format, exportFileName(poll.ID, runID, "responses", format), "Responses",
+		responseRows(responses), APIResponses{PollID: poll.ID, RunID: runID, Responses: responses})
+}
@@ -0,0 +1,147 @@
+package pages
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/aspcodenet/systementorlivepolls/data"
+	"github.com/stretchr/testify/assert"
+)
+
+func createTestVotes(t *testing.T, poll *data.Poll, runID uint) {
+	t.Helper()
+	q := poll.Questions[0]
+	for i, optionID := range []uint{q.Options[0].ID, q.Options[0].ID, q.Options[0].ID, q.Options[1].ID} {
+		vote := &data.Vote{QuestionID: q.ID, OptionID: optionID, VoterID: "voter" + idStr(uint(i)), PollRunID: runID}
+		if err := data.DB.Create(vote).Error; err != nil {
+			t.Fatalf("failed to create vote: %v", err)
+		}
+	}
+}
+
+func TestAPIPollExportResultsCSV(t *testing.T) {
+	setupTestDB(t)
+	owner := createTestAdmin(t, "owner@example.com")
+	poll := createTestPoll(t, owner, "Export")
+	createTestVotes(t, poll, 0)
+
+	w := doRequest(newTestAPIRouter(owner.Email), http.MethodGet, "/api/v1/polls/"+idStr(poll.ID)+"/export/results", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "text/csv")
+	assert.Equal(t, `attachment; filename="poll-`+idStr(poll.ID)+`-results.csv"`, w.Header().Get("Content-Disposition"))
+
+	records, err := csv.NewReader(w.Body).ReadAll()
+	assert.NoError(t, err)
+	if assert.Len(t, records, 3) {
+		assert.Equal(t, "Votes", records[0][5])
+		assert.Equal(t, []string{"A", "3", "75", "4", "4"}, records[1][4:])
+		assert.Equal(t, []string{"B", "1", "25", "4", "4"}, records[2][4:])
+	}
+}
+
+func TestAPIPollExportResultsFormats(t *testing.T) {
+	setupTestDB(t)
+	owner := createTestAdmin(t, "owner@example.com")
+	poll := createTestPoll(t, owner, "Export")
+	createTestVotes(t, poll, 0)
+	router := newTestAPIRouter(owner.Email)
+	path := "/api/v1/polls/" + idStr(poll.ID) + "/export/results"
+
+	w := doRequest(router, http.MethodGet, path+"?format=json", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var results APIResults
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &results))
+	if assert.Len(t, results.Questions, 1) {
+		assert.Equal(t, 4, results.Questions[0].TotalVotes)
+	}
+
+	w = doRequest(router, http.MethodGet, path+"?format=xlsx", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, exportContentTypes[exportXLSX], w.Header().Get("Content-Type"))
+	_, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	assert.NoError(t, err, "xlsx export should be a zip file")
+
+	w = doRequest(router, http.MethodGet, path+"?format=pdf", "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestExportEscapesFormulas(t *testing.T) {
+	setupTestDB(t)
+	owner := createTestAdmin(t, "owner@example.com")
+	poll := createTestPoll(t, owner, "Export")
+	q := poll.Questions[0]
+	assert.NoError(t, data.DB.Model(&q.Options[0]).Update("text", "+1").Error)
+	for _, voterID := range []string{`=HYPERLINK("http://evil.example.com","x")`, "@SUM(A1)", "-2", "\tcmd"} {
+		assert.NoError(t, data.DB.Create(&data.Vote{QuestionID: q.ID, OptionID: q.Options[0].ID, VoterID: voterID}).Error)
+	}
+	router := newTestAPIRouter(owner.Email)
+	path := "/api/v1/polls/" + idStr(poll.ID) + "/export/responses"
+
+	w := doRequest(router, http.MethodGet, path, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	records, err := csv.NewReader(w.Body).ReadAll()
+	assert.NoError(t, err)
+	voterIDs := []string{}
+	for _, record := range records[1:] {
+		voterIDs = append(voterIDs, record[1])
+		assert.Equal(t, "'+1", record[5])
+	}
+	assert.ElementsMatch(t, []string{`'=HYPERLINK("http://evil.example.com","x")`, "'@SUM(A1)", "'-2", "'\tcmd"}, voterIDs)
+
+	w = doRequest(router, http.MethodGet, path+"?format=xlsx", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	zr, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	if assert.NoError(t, err) {
+		f, err := zr.Open("xl/worksheets/sheet1.xml")
+		if assert.NoError(t, err) {
+			sheet, _ := io.ReadAll(f)
+			for _, text := range []string{">=HYPERLINK(&#34;", ">@SUM(A1)<", ">-2<", ">+1<"} {
+				assert.Contains(t, string(sheet), text, "text is stored unchanged as an inline string")
+			}
+			assert.NotContains(t, string(sheet), "&#39;")
+			assert.NotContains(t, string(sheet), "<f>", "no cell is a formula")
+		}
+	}
+
+	// The JSON export is data, not a spreadsheet, and stays as voted
+	w = doRequest(router, http.MethodGet, path+"?format=json", "")
+	assert.Contains(t, w.Body.String(), `"voterId":"@SUM(A1)"`)
+}
+
+func TestAPIPollExportResponsesPerRun(t *testing.T) {
+	setupTestDB(t)
+	owner := createTestAdmin(t, "owner@example.com")
+	poll := createTestPoll(t, owner, "Export")
+	run, err := data.StartPollRun(poll.ID)
+	assert.NoError(t, err)
+	createTestVotes(t, poll, 0)
+	createTestVotes(t, poll, run.ID)
+	router := newTestAPIRouter(owner.Email)
+
+	w := doRequest(router, http.MethodGet, "/api/v1/polls/"+idStr(poll.ID)+"/export/responses?format=json", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var all APIResponses
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &all))
+	assert.Len(t, all.Responses, 8)
+
+	w = doRequest(router, http.MethodGet, "/api/v1/polls/"+idStr(poll.ID)+"/runs/"+idStr(run.ID)+"/export/responses?format=json", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `attachment; filename="poll-`+idStr(poll.ID)+`-run-`+idStr(run.ID)+`-responses.json"`, w.Header().Get("Content-Disposition"))
+	var perRun APIResponses
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &perRun))
+	if assert.Len(t, perRun.Responses, 4) {
+		r := perRun.Responses[0]
+		assert.Equal(t, run.ID, r.PollRunID)
+		assert.Equal(t, "Question 1", r.QuestionText)
+		assert.Equal(t, "A", r.OptionText)
+		assert.False(t, r.VotedAt.IsZero())
+	}
+
+	w = doRequest(router, http.MethodGet, "/api/v1/polls/"+idStr(poll.ID)+"/runs/9999/export/responses", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -0,0 +1,100 @@
+package pages
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/aspcodenet/systementorlivepolls/data"
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-contrib/sessions/cookie"
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// setupTestDB points data.DB at a fresh in-memory SQLite database with all tables migrated.
+func setupTestDB(t *testing.T) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1) // Every connection to :memory: is a separate database
+	if err := data.Migrate(db); err != nil {
+		t.Fatalf("failed to migrate test database: %v", err)
+	}
+	data.DB = db
+	t.Cleanup(func() { sqlDB.Close() })
+}
+
+// newTestRouter returns a gin engine with a cookie session where the given email is logged in.
+// An empty email means no user is logged in.
+func newTestRouter(email string) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(sessions.Sessions("mysession", cookie.NewStore([]byte("test_secret"))))
+	router.Use(func(c *gin.Context) {
+		if email != "" {
+			session := sessions.Default(c)
+			session.Set(Userkey, email)
+		}
+		c.Next()
+	})
+	return router
+}
+
+func createTestAdmin(t *testing.T, email string) *data.AdminUser {
+	t.Helper()
+	adminUser := &data.AdminUser{Email: email, Active: true, Role: data.RoleAdmin}
+	if err := data.DB.Create(adminUser).Error; err != nil {
+		t.Fatalf("failed to create admin user: %v", err)
+	}
+	return adminUser
+}
+
+func createTestPoll(t *testing.T, owner *data.AdminUser, title string) *data.Poll {
+	t.Helper()
+	poll := &data.Poll{
+		Title:                title,
+		Status:               "setup",
+		CurrentQuestionIndex: -1,
+		AdminUserID:          int(owner.ID),
+		InviteID:             "invite-" + strings.ReplaceAll(title, " ", "-"),
+		Questions: []data.Question{
+			{Text: "Question 1", Type: "single-select", Options: []data.Option{{Text: "A"}, {Text: "B"}}},
+		},
+	}
+	if err := data.DB.Create(poll).Error; err != nil {
+		t.Fatalf("failed to create poll: %v", err)
+	}
+	return poll
+}
+
+func doRequest(router *gin.Engine, method, path string, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// doFormRequest posts an url encoded form, like a browser submitting a page.
+func doFormRequest(router *gin.Engine, path string, form url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func idStr(id uint) string {
+	return strconv.FormatUint(uint64(id), 10)
+}
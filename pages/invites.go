@@ -0,0 +1,104 @@
+package pages
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/aspcodenet/systementorlivepolls/data"
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+)
+
+// adminInviteKey is the session key of the invite code to use once the visitor has logged in.
+const adminInviteKey = "ADMIN-INVITE"
+
+// inviteValidity is how long new invites can be used, as offered on the admin management page.
+var inviteValidity = []struct {
+	Hours int
+	Label string
+}{
+	{24, "1 day"},
+	{7 * 24, "7 days"},
+	{30 * 24, "30 days"},
+}
+
+func invitePath(invite *data.AdminInvite) string {
+	return "/invite/" + invite.Code
+}
+
+// Invite is where an invitation link leads. The code is kept in the session until the visitor
+// has logged in, see redeemSessionInvite.
+func Invite(c *gin.Context) {
+	code := c.Param("code")
+	if _, err := data.GetUsableAdminInvite(code, time.Now()); err != nil {
+		if !errors.Is(err, data.ErrInviteInvalid) {
+			log.Printf("Error retrieving invite: %v", err)
+		}
+		renderLogin(c, http.StatusGone, "This invitation has expired or has already been used. You can still log in and ask an owner for access.")
+		return
+	}
+
+	session := sessions.Default(c)
+	session.Set(adminInviteKey, code)
+	session.Save()
+	if currentUser, _ := session.Get(Userkey).(string); currentUser != "" {
+		var adminUser data.AdminUser
+		if err := data.DB.First(&adminUser, "email=?", currentUser).Error; err == nil {
+			redeemSessionInvite(session, &adminUser)
+			session.Save()
+			c.Redirect(302, defaultLoginRedirect)
+			return
+		}
+	}
+	c.Redirect(302, "/loginv1")
+}
+
+// redeemSessionInvite uses the invite kept in the session, if any, for the user who just logged
+// in. Users who may already use the admin pages leave the invite for someone else. Without a
+// valid invite the user waits for an owner to give them a role.
+func redeemSessionInvite(session sessions.Session, adminUser *data.AdminUser) {
+	code, _ := session.Get(adminInviteKey).(string)
+	session.Delete(adminInviteKey)
+	if code == "" || adminUser.IsAdmin() {
+		return
+	}
+	if err := data.RedeemAdminInvite(code, adminUser, time.Now()); err != nil {
+		log.Printf("Invite for %s not used: %v", adminUser.Email, err)
+	}
+}
+
+// AdminInvitesCreatePOST creates an invitation link, shown on the admin management page.
+func AdminInvitesCreatePOST(c *gin.Context) {
+	adminUser := currentAdmin(c)
+	currentUser := adminUser.Email
+
+	hours, err := strconv.Atoi(c.PostForm("hours"))
+	if err != nil || hours < 1 || hours > 30*24 {
+		renderAdminUsers(c, http.StatusBadRequest, currentUser, adminUser, "Choose how long the invitation can be used.")
+		return
+	}
+	if _, err := data.CreateAdminInvite(adminUser, c.PostForm("role"), time.Duration(hours)*time.Hour); err != nil {
+		renderAdminUsers(c, http.StatusBadRequest, currentUser, adminUser, "Could not create the invitation: "+err.Error())
+		return
+	}
+	c.Redirect(302, "/admin/users")
+}
+
+// AdminInvitesRevokePOST stops an invitation link from working.
+func AdminInvitesRevokePOST(c *gin.Context) {
+
+	inviteID, err := strconv.Atoi(c.Param("inviteID"))
+	if err != nil {
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	if err := data.RevokeAdminInvite(uint(inviteID)); err != nil {
+		log.Printf("Error revoking invite %d: %v", inviteID, err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	c.Redirect(302, "/admin/users")
+}
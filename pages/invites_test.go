@@ -0,0 +1,105 @@
+package pages
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/aspcodenet/systementorlivepolls/auth"
+	"github.com/aspcodenet/systementorlivepolls/data"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdminInvitesCreateAndRevoke(t *testing.T) {
+	setupTestDB(t)
+	owner := createTestUser(t, "owner@example.com", data.RoleOwner)
+	router := newTestUsersRouter(owner.Email)
+	router.POST("/admin/invites", AdminRequired, OwnerRequired, AdminInvitesCreatePOST)
+	router.POST("/admin/invites/revoke/:inviteID", AdminRequired, OwnerRequired, AdminInvitesRevokePOST)
+
+	w := doFormRequest(router, "/admin/invites", url.Values{"role": {"viewer"}, "hours": {"24"}})
+	assert.Equal(t, http.StatusFound, w.Code)
+	invites, _ := data.GetAdminInvites(time.Now())
+	require.Len(t, invites, 1)
+	assert.Equal(t, data.RoleViewer, invites[0].Role)
+	assert.WithinDuration(t, time.Now().Add(24*time.Hour), invites[0].ExpiresAt, time.Minute)
+
+	w = doRequest(router, "GET", "/admin/users", "")
+	assert.Contains(t, w.Body.String(), "/invite/"+invites[0].Code)
+
+	w = doFormRequest(router, "/admin/invites", url.Values{"role": {"superuser"}, "hours": {"24"}})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = doFormRequest(router, "/admin/invites", url.Values{"role": {"admin"}, "hours": {"100000"}})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	admin := createTestUser(t, "admin@example.com", data.RoleAdmin)
+	w = doFormRequest(newTestUsersRouter(admin.Email), "/admin/users/"+idStr(admin.ID), url.Values{"role": {"owner"}, "active": {"on"}})
+	assert.Equal(t, http.StatusForbidden, w.Code, "only owners manage admins")
+
+	w = doFormRequest(router, "/admin/invites/revoke/"+idStr(invites[0].ID), url.Values{})
+	assert.Equal(t, http.StatusFound, w.Code)
+	invites, _ = data.GetAdminInvites(time.Now())
+	assert.Empty(t, invites)
+}
+
+func TestInviteLogin(t *testing.T) {
+	setupTestDB(t)
+	owner := createTestUser(t, "owner@example.com", data.RoleOwner)
+	invite, err := data.CreateAdminInvite(owner, data.RoleAdmin, time.Hour)
+	require.NoError(t, err)
+	server := newTestLoginServer(t, &auth.Dev{Email: "invited@example.com"})
+
+	resp, _ := get(t, newBrowser(t), server.URL+"/invite/"+invite.Code)
+	assert.Equal(t, http.StatusFound, resp.StatusCode)
+	assert.Equal(t, "/admin/polls", resp.Header.Get("Location"))
+	var invited data.AdminUser
+	require.NoError(t, data.DB.First(&invited, "email=?", "invited@example.com").Error)
+	assert.True(t, invited.CanEdit())
+	assert.Equal(t, invite.Code, invited.FromInvite)
+
+	// The link only works once
+	resp, body := get(t, newBrowser(t), server.URL+"/invite/"+invite.Code)
+	assert.Equal(t, http.StatusGone, resp.StatusCode)
+	assert.Contains(t, body, "has expired or has already been used")
+}
+
+func TestLoginWithoutInviteWaitsForApproval(t *testing.T) {
+	setupTestDB(t)
+	server := newTestLoginServer(t, &auth.Dev{Email: "stranger@example.com"})
+
+	resp, _ := get(t, newBrowser(t), server.URL+"/loginv1")
+	assert.Equal(t, http.StatusFound, resp.StatusCode)
+	var stranger data.AdminUser
+	require.NoError(t, data.DB.First(&stranger, "email=?", "stranger@example.com").Error)
+	assert.False(t, stranger.Active)
+	assert.Empty(t, stranger.Role)
+	assert.Empty(t, stranger.FromInvite)
+}
+
+func TestInviteForLoggedInUser(t *testing.T) {
+	setupTestDB(t)
+	owner := createTestUser(t, "owner@example.com", data.RoleOwner)
+	waiting := createTestUser(t, "waiting@example.com", "")
+	invite, err := data.CreateAdminInvite(owner, data.RoleViewer, time.Hour)
+	require.NoError(t, err)
+	router := newTestRouter(waiting.Email)
+	router.GET("/invite/:code", Invite)
+
+	w := doRequest(router, "GET", "/invite/"+invite.Code, "")
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "/admin/polls", w.Header().Get("Location"))
+	data.DB.First(waiting, waiting.ID)
+	assert.Equal(t, data.RoleViewer, waiting.Role)
+	assert.True(t, waiting.IsAdmin())
+
+	// Admins leave invites for someone else
+	second, _ := data.CreateAdminInvite(owner, data.RoleOwner, time.Hour)
+	ownerRouter := newTestRouter(owner.Email)
+	ownerRouter.GET("/invite/:code", Invite)
+	w = doRequest(ownerRouter, "GET", "/invite/"+second.Code, "")
+	assert.Equal(t, http.StatusFound, w.Code)
+	invites, _ := data.GetAdminInvites(time.Now())
+	assert.Len(t, invites, 1)
+}
@@ -0,0 +1,180 @@
+package pages
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/aspcodenet/systementorlivepolls/auth"
+	"github.com/aspcodenet/systementorlivepolls/data"
+	"github.com/aspcodenet/systementorlivepolls/utils"
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+)
+
+// LoginProviders are the ways admins can log in, in the order they are shown on the login page.
+var LoginProviders []auth.Provider
+
+// loginFlowKey is the session key of the login in progress, an auth.Flow as JSON.
+const loginFlowKey = "LOGIN-FLOW"
+
+// loginRedirectKey is the session key of the page to return to after logging in.
+const loginRedirectKey = "LOGIN-REDIRECT"
+
+// defaultLoginRedirect is where admins land after logging in when no page asked for the login.
+const defaultLoginRedirect = "/admin/polls"
+
+func Init(providers []auth.Provider) {
+	LoginProviders = providers
+	if len(providers) == 0 {
+		log.Println("No login providers are configured - admins cannot log in")
+	}
+}
+
+// loginCallbackPath is where a provider sends the browser back to after logging in.
+// /login/oauth2/code/github is registered with our GitHub OAuth app, so the path must stay.
+func loginCallbackPath(provider string) string {
+	return "/login/oauth2/code/" + provider
+}
+
+// renderLogin shows the login page with a button per provider, and an error if status is not 200.
+func renderLogin(c *gin.Context, status int, errorMessage string) {
+	type providerView struct {
+		Name, DisplayName string
+	}
+	providers := make([]providerView, 0, len(LoginProviders))
+	for _, p := range LoginProviders {
+		providers = append(providers, providerView{Name: p.Name(), DisplayName: p.DisplayName()})
+	}
+	c.HTML(status, "login.html", gin.H{
+		"Providers": providers,
+		"Error":     errorMessage,
+	})
+}
+
+// RememberLoginRedirect stores the page to return to after logging in. Only paths on this site
+// are stored, so the login cannot be used to send admins to another site.
+func RememberLoginRedirect(c *gin.Context, target string) {
+	if !utils.IsSafeRedirect(target) || strings.HasPrefix(target, "/login") {
+		return
+	}
+	session := sessions.Default(c)
+	session.Set(loginRedirectKey, target)
+	session.Save()
+}
+
+// takeLoginRedirect returns and forgets the page to return to after logging in.
+func takeLoginRedirect(session sessions.Session) string {
+	target, _ := session.Get(loginRedirectKey).(string)
+	session.Delete(loginRedirectKey)
+	if !utils.IsSafeRedirect(target) {
+		return defaultLoginRedirect
+	}
+	return target
+}
+
+// Login shows the login page. With a single provider there is nothing to pick, so the browser
+// is sent straight to it. A redirect_uri query parameter is the page to return to afterwards.
+func Login(c *gin.Context) {
+	if target := c.Query("redirect_uri"); target != "" {
+		RememberLoginRedirect(c, target)
+	}
+	if len(LoginProviders) == 1 {
+		c.Redirect(302, "/login/"+LoginProviders[0].Name())
+		return
+	}
+	renderLogin(c, http.StatusOK, "")
+}
+
+// LoginStart sends the browser to a provider to log in.
+func LoginStart(c *gin.Context) {
+	provider, err := auth.Find(LoginProviders, c.Param("provider"))
+	if err != nil {
+		renderLogin(c, http.StatusNotFound, "Unknown login method.")
+		return
+	}
+	flow, err := auth.NewFlow(provider.Name(), publicURL(c, loginCallbackPath(provider.Name())))
+	if err != nil {
+		log.Printf("Error starting login with %s: %v", provider.Name(), err)
+		renderLogin(c, http.StatusInternalServerError, "Could not start the login.")
+		return
+	}
+	redirectURL, err := provider.AuthCodeURL(c.Request.Context(), flow)
+	if errors.Is(err, auth.ErrDevLoginInRelease) {
+		renderLogin(c, http.StatusForbidden, "The development login is disabled in production.")
+		return
+	}
+	if err != nil {
+		log.Printf("Error starting login with %s: %v", provider.Name(), err)
+		renderLogin(c, http.StatusBadGateway, provider.DisplayName()+" cannot be reached. Try again later.")
+		return
+	}
+
+	flowJSON, _ := json.Marshal(flow)
+	session := sessions.Default(c)
+	session.Set(loginFlowKey, string(flowJSON))
+	session.Save()
+	c.Redirect(302, redirectURL)
+}
+
+// LoginCallback finishes a login when the provider sends the browser back with a code.
+func LoginCallback(c *gin.Context) {
+	session := sessions.Default(c)
+	var flow auth.Flow
+	flowJSON, _ := session.Get(loginFlowKey).(string)
+	session.Delete(loginFlowKey) // A flow can only be finished once
+	session.Save()
+
+	// Checking the state returned by the provider against the session prevents CSRF, per
+	// section 10.12 of https://www.rfc-editor.org/rfc/rfc6749.html
+	if flowJSON == "" || json.Unmarshal([]byte(flowJSON), &flow) != nil ||
+		flow.Provider != c.Param("provider") || flow.State == "" || c.Query("state") != flow.State {
+		renderLogin(c, http.StatusBadRequest, "The login has expired or was started in another browser. Please try again.")
+		return
+	}
+	provider, err := auth.Find(LoginProviders, flow.Provider)
+	if err != nil {
+		renderLogin(c, http.StatusNotFound, "Unknown login method.")
+		return
+	}
+	if reason := c.Query("error"); reason != "" {
+		log.Printf("Login with %s was not completed: %s %s", provider.Name(), reason, c.Query("error_description"))
+		renderLogin(c, http.StatusUnauthorized, "The login was cancelled or refused by "+provider.DisplayName()+".")
+		return
+	}
+
+	identity, err := provider.Exchange(c.Request.Context(), &flow, c.Query("code"))
+	if errors.Is(err, auth.ErrNoEmail) {
+		renderLogin(c, http.StatusUnauthorized, "Your "+provider.DisplayName()+" account has no verified primary email address. Add one and try again.")
+		return
+	}
+	if err != nil {
+		log.Printf("Login with %s failed: %v", provider.Name(), err)
+		renderLogin(c, http.StatusUnauthorized, "The login with "+provider.DisplayName()+" failed. Please try again.")
+		return
+	}
+
+	adminUser, err := data.LoginAdminUser(provider.Name(), identity.Subject, identity.Email, identity.EmailVerified)
+	if errors.Is(err, data.ErrEmailTaken) {
+		renderLogin(c, http.StatusConflict, identity.Email+" is already used by another "+provider.DisplayName()+" account.")
+		return
+	}
+	if errors.Is(err, data.ErrEmailNotVerified) {
+		renderLogin(c, http.StatusForbidden, provider.DisplayName()+" has not verified "+identity.Email+". Verify it there and log in again.")
+		return
+	}
+	if err != nil {
+		log.Printf("Error saving admin user after login with %s: %v", provider.Name(), err)
+		renderLogin(c, http.StatusInternalServerError, "Could not finish the login. Please try again.")
+		return
+	}
+
+	session.Set(Userkey, adminUser.Email)
+	session.Delete(csrfSessionKey) // A new login gets a new CSRF token
+	redeemSessionInvite(session, adminUser)
+	redirectURL := takeLoginRedirect(session)
+	session.Save()
+	c.Redirect(302, redirectURL)
+}
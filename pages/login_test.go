@@ -0,0 +1,292 @@
+package pages
+
+import (
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/aspcodenet/systementorlivepolls/auth"
+	"github.com/aspcodenet/systementorlivepolls/auth/authtest"
+	"github.com/aspcodenet/systementorlivepolls/data"
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestLoginServer serves the login and invite routes, and /whoami with the logged in user, over HTTP so
+// a browser-like client can follow the redirects to and from the provider.
+func newTestLoginServer(t *testing.T, providers ...auth.Provider) *httptest.Server {
+	t.Helper()
+	saved := LoginProviders
+	t.Cleanup(func() { LoginProviders = saved })
+	Init(providers)
+
+	router := newTestRouter("")
+	router.LoadHTMLGlob("../templates/**")
+	router.GET("/loginv1", Login)
+	router.GET("/login/:provider", LoginStart)
+	router.GET("/login/oauth2/code/:provider", LoginCallback)
+	router.GET("/invite/:code", Invite)
+	router.GET("/whoami", func(c *gin.Context) {
+		user, _ := sessions.Default(c).Get(Userkey).(string)
+		c.String(http.StatusOK, user)
+	})
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	return server
+}
+
+// newBrowser returns a client that keeps cookies and stops following redirects at /admin pages.
+func newBrowser(t *testing.T) *http.Client {
+	jar, err := cookiejar.New(nil)
+	require.NoError(t, err)
+	return &http.Client{Jar: jar, CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		if strings.HasPrefix(req.URL.Path, "/admin") {
+			return http.ErrUseLastResponse
+		}
+		return nil
+	}}
+}
+
+func get(t *testing.T, client *http.Client, url string) (*http.Response, string) {
+	t.Helper()
+	resp, err := client.Get(url)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return resp, string(body)
+}
+
+func TestLoginWithOIDC(t *testing.T) {
+	setupTestDB(t)
+	provider := authtest.NewOIDCServer("polls", "secret")
+	defer provider.Close()
+	provider.Email = "teacher@university.example"
+	server := newTestLoginServer(t,
+		&auth.GitHub{ClientID: "gh"},
+		&auth.OIDC{Label: "University login", Issuer: provider.URL, ClientID: "polls", ClientSecret: "secret"})
+	browser := newBrowser(t)
+
+	resp, body := get(t, browser, server.URL+"/loginv1")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, body, `href="/login/github"`)
+	assert.Contains(t, body, "Log in with University login")
+
+	resp, _ = get(t, browser, server.URL+"/login/oidc")
+	assert.Equal(t, http.StatusFound, resp.StatusCode)
+	assert.Equal(t, "/admin/polls", resp.Header.Get("Location"))
+
+	_, body = get(t, browser, server.URL+"/whoami")
+	assert.Equal(t, "teacher@university.example", body)
+	var adminUser data.AdminUser
+	assert.NoError(t, data.DB.First(&adminUser, "email=?", "teacher@university.example").Error)
+
+	// The flow is removed from the session once used
+	resp, body = get(t, browser, server.URL+"/login/oauth2/code/oidc?state=x&code=y")
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Contains(t, body, "The login has expired")
+}
+
+func TestLoginRejectsFailedLogins(t *testing.T) {
+	setupTestDB(t)
+	provider := authtest.NewOIDCServer("polls", "secret")
+	defer provider.Close()
+	provider.Claims = map[string]interface{}{"email_verified": false}
+	server := newTestLoginServer(t, &auth.OIDC{Issuer: provider.URL, ClientID: "polls", ClientSecret: "secret"})
+	browser := newBrowser(t)
+
+	// A single provider needs no picker
+	resp, body := get(t, browser, server.URL+"/loginv1")
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Contains(t, body, "The login with Single sign-on failed.")
+	_, body = get(t, browser, server.URL+"/whoami")
+	assert.Empty(t, body)
+
+	resp, _ = get(t, browser, server.URL+"/login/saml")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestLoginWithUnverifiedEmailCannotTakeOverAccount(t *testing.T) {
+	setupTestDB(t)
+	createTestAdmin(t, "owner@university.example")
+	provider := authtest.NewOIDCServer("polls", "secret")
+	defer provider.Close()
+	provider.Email = "owner@university.example"
+	provider.Claims = map[string]interface{}{"email_verified": nil}
+	server := newTestLoginServer(t, &auth.OIDC{Issuer: provider.URL, ClientID: "polls", ClientSecret: "secret"})
+	browser := newBrowser(t)
+
+	resp, body := get(t, browser, server.URL+"/login/oidc")
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	assert.Contains(t, body, "has not verified owner@university.example")
+	_, body = get(t, browser, server.URL+"/whoami")
+	assert.Empty(t, body)
+
+	// Nor can it claim an email nobody has used yet
+	provider.Email = "dean@university.example"
+	resp, _ = get(t, browser, server.URL+"/login/oidc")
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	_, err := data.GetAdminUserByEmail("dean@university.example")
+	assert.ErrorIs(t, err, data.ErrNoSuchAdmin)
+}
+
+func TestLoginChecksState(t *testing.T) {
+	setupTestDB(t)
+	provider := authtest.NewOIDCServer("polls", "secret")
+	defer provider.Close()
+	server := newTestLoginServer(t, &auth.OIDC{Issuer: provider.URL, ClientID: "polls", ClientSecret: "secret"})
+	browser := newBrowser(t)
+	browser.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+
+	resp, _ := get(t, browser, server.URL+"/login/oidc")
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+	resp, body := get(t, browser, server.URL+"/login/oauth2/code/oidc?state=forged&code=abc")
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Contains(t, body, "The login has expired")
+	_, body = get(t, browser, server.URL+"/whoami")
+	assert.Empty(t, body)
+}
+
+func TestLoginWithGitHub(t *testing.T) {
+	setupTestDB(t)
+	github := authtest.NewGitHubServer("gh", "gh-secret")
+	defer github.Close()
+	github.Emails = []authtest.GitHubEmail{{Email: "teacher@school.example", Primary: true, Verified: true}}
+	server := newTestLoginServer(t, &auth.GitHub{
+		ClientID: "gh", ClientSecret: "gh-secret", BaseURL: github.URL, APIURL: github.URL, HTTPClient: github.Client(),
+	})
+	github.CallbackURL = server.URL + "/login/oauth2/code/github"
+	browser := newBrowser(t)
+
+	resp, _ := get(t, browser, server.URL+"/loginv1")
+	assert.Equal(t, http.StatusFound, resp.StatusCode)
+	assert.Equal(t, "/admin/polls", resp.Header.Get("Location"))
+
+	_, body := get(t, browser, server.URL+"/whoami")
+	assert.Equal(t, "teacher@school.example", body)
+	var adminUser data.AdminUser
+	assert.NoError(t, data.DB.First(&adminUser, "email=?", "teacher@school.example").Error)
+}
+
+func TestLoginWithGitHubKeepsUserWhenEmailChanges(t *testing.T) {
+	setupTestDB(t)
+	github := authtest.NewGitHubServer("gh", "gh-secret")
+	defer github.Close()
+	server := newTestLoginServer(t, &auth.GitHub{
+		ClientID: "gh", ClientSecret: "gh-secret", BaseURL: github.URL, APIURL: github.URL, HTTPClient: github.Client(),
+	})
+	github.CallbackURL = server.URL + "/login/oauth2/code/github"
+
+	get(t, newBrowser(t), server.URL+"/login/github")
+	var before data.AdminUser
+	require.NoError(t, data.DB.First(&before, "email=?", "user@example.com").Error)
+
+	github.Emails = []authtest.GitHubEmail{{Email: "renamed@example.com", Primary: true, Verified: true}}
+	browser := newBrowser(t)
+	get(t, browser, server.URL+"/login/github")
+	_, body := get(t, browser, server.URL+"/whoami")
+	assert.Equal(t, "renamed@example.com", body)
+	var after data.AdminUser
+	require.NoError(t, data.DB.First(&after, "email=?", "renamed@example.com").Error)
+	assert.Equal(t, before.ID, after.ID)
+	assert.Equal(t, "1", after.LoginSubject)
+}
+
+func TestLoginWithGitHubWithoutVerifiedEmail(t *testing.T) {
+	setupTestDB(t)
+	github := authtest.NewGitHubServer("gh", "gh-secret")
+	defer github.Close()
+	github.Emails = []authtest.GitHubEmail{{Email: "unverified@example.com", Primary: true}}
+	server := newTestLoginServer(t, &auth.GitHub{
+		ClientID: "gh", ClientSecret: "gh-secret", BaseURL: github.URL, APIURL: github.URL, HTTPClient: github.Client(),
+	})
+	github.CallbackURL = server.URL + "/login/oauth2/code/github"
+	browser := newBrowser(t)
+
+	resp, body := get(t, browser, server.URL+"/login/github")
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Contains(t, body, "no verified primary email address")
+	_, body = get(t, browser, server.URL+"/whoami")
+	assert.Empty(t, body)
+}
+
+func TestLoginWithGitHubWrongSecret(t *testing.T) {
+	setupTestDB(t)
+	github := authtest.NewGitHubServer("gh", "gh-secret")
+	defer github.Close()
+	server := newTestLoginServer(t, &auth.GitHub{
+		ClientID: "gh", ClientSecret: "revoked", BaseURL: github.URL, APIURL: github.URL, HTTPClient: github.Client(),
+	})
+	github.CallbackURL = server.URL + "/login/oauth2/code/github"
+	browser := newBrowser(t)
+
+	resp, body := get(t, browser, server.URL+"/login/github")
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Contains(t, body, "The login with GitHub failed.")
+	_, body = get(t, browser, server.URL+"/whoami")
+	assert.Empty(t, body)
+}
+
+func TestLoginWithDev(t *testing.T) {
+	setupTestDB(t)
+	server := newTestLoginServer(t, &auth.Dev{Email: "dev@example.com"})
+	browser := newBrowser(t)
+
+	resp, _ := get(t, browser, server.URL+"/loginv1")
+	assert.Equal(t, http.StatusFound, resp.StatusCode)
+	_, body := get(t, browser, server.URL+"/whoami")
+	assert.Equal(t, "dev@example.com", body)
+
+	gin.SetMode(gin.ReleaseMode)
+	defer gin.SetMode(gin.TestMode)
+	browser = newBrowser(t)
+	resp, body = get(t, browser, server.URL+"/loginv1")
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	assert.Contains(t, body, "disabled in production")
+	_, body = get(t, browser, server.URL+"/whoami")
+	assert.Empty(t, body)
+}
+
+func TestLoginReturnsToRequestedPage(t *testing.T) {
+	setupTestDB(t)
+	server := newTestLoginServer(t, &auth.Dev{Email: "dev@example.com"})
+
+	resp, _ := get(t, newBrowser(t), server.URL+"/loginv1?redirect_uri="+url.QueryEscape("/admin/polls/edit/abc?tab=2"))
+	assert.Equal(t, http.StatusFound, resp.StatusCode)
+	assert.Equal(t, "/admin/polls/edit/abc?tab=2", resp.Header.Get("Location"))
+
+	// The page is only returned to once
+	browser := newBrowser(t)
+	get(t, browser, server.URL+"/loginv1?redirect_uri=/admin/profile")
+	resp, _ = get(t, browser, server.URL+"/loginv1")
+	assert.Equal(t, "/admin/polls", resp.Header.Get("Location"))
+}
+
+func TestLoginRefusesOpenRedirects(t *testing.T) {
+	setupTestDB(t)
+	server := newTestLoginServer(t, &auth.Dev{})
+
+	for _, target := range []string{"https://evil.example/admin", "//evil.example/admin", "/\\evil.example", "/loginv1"} {
+		t.Run(target, func(t *testing.T) {
+			resp, _ := get(t, newBrowser(t), server.URL+"/loginv1?redirect_uri="+url.QueryEscape(target))
+			assert.Equal(t, "/admin/polls", resp.Header.Get("Location"))
+		})
+	}
+
+	// The callback ignores a redirect_uri added by the provider or an attacker
+	browser := newBrowser(t)
+	browser.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+	resp, _ := get(t, browser, server.URL+"/login/dev")
+	callback, err := url.Parse(resp.Header.Get("Location"))
+	require.NoError(t, err)
+	query := callback.Query()
+	query.Set("redirect_uri", "https://evil.example")
+	resp, _ = get(t, browser, server.URL+callback.Path+"?"+query.Encode())
+	assert.Equal(t, http.StatusFound, resp.StatusCode)
+	assert.Equal(t, "/admin/polls", resp.Header.Get("Location"))
+}
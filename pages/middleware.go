@@ -0,0 +1,98 @@
+package pages
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/aspcodenet/systementorlivepolls/data"
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+)
+
+// Keys of the values the admin middleware keeps in the gin context.
+const (
+	adminUserContextKey = "ADMIN-USER"
+	pollContextKey      = "ADMIN-POLL"
+)
+
+// AdminRequired loads the logged in user into the gin context for the admin pages, see
+// currentAdmin. Users who may not use the admin pages get the noadmin page. Use it after
+// WebPageAuthRequired.
+func AdminRequired(c *gin.Context) {
+	currentUser, _ := sessions.Default(c).Get(Userkey).(string)
+	var adminUser data.AdminUser
+	if currentUser == "" || data.DB.First(&adminUser, "email=?", currentUser).Error != nil || !adminUser.IsAdmin() {
+		c.HTML(http.StatusForbidden, "noadmin.html", gin.H{"CurrentUser": currentUser})
+		c.Abort()
+		return
+	}
+	c.Set(adminUserContextKey, &adminUser)
+	c.Next()
+}
+
+// EditorRequired keeps viewers, who may look at the admin pages, away from the pages that
+// change polls. Use it after AdminRequired.
+func EditorRequired(c *gin.Context) {
+	adminUser := currentAdmin(c)
+	if !adminUser.CanEdit() {
+		c.HTML(http.StatusForbidden, "noadmin.html", gin.H{"CurrentUser": adminUser.Email, "ReadOnly": true})
+		c.Abort()
+		return
+	}
+	c.Next()
+}
+
+// OwnerRequired keeps the pages that manage the administrators to owners. Use it after AdminRequired.
+func OwnerRequired(c *gin.Context) {
+	adminUser := currentAdmin(c)
+	if !adminUser.IsOwner() {
+		c.HTML(http.StatusForbidden, "noadmin.html", gin.H{"CurrentUser": adminUser.Email, "ReadOnly": !adminUser.CanEdit()})
+		c.Abort()
+		return
+	}
+	c.Next()
+}
+
+// PollRequired loads the poll in the :pollID or :inviteID parameter, with questions and options,
+// into the gin context, see currentPoll. Unknown polls get a 404 page and polls the user may not
+// do need with a 403 page. Use it after AdminRequired.
+func PollRequired(need string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		adminUser := currentAdmin(c)
+		var poll *data.Poll
+		var err error
+		if inviteID := c.Param("inviteID"); inviteID != "" {
+			poll, err = data.GetPollWithDetails(inviteID)
+		} else {
+			var pollID int
+			if pollID, err = strconv.Atoi(c.Param("pollID")); err == nil {
+				poll, err = data.GetPollAndDetailsForAdmin(uint(pollID))
+			}
+		}
+		if err != nil {
+			c.HTML(http.StatusNotFound, "notfound.html", gin.H{
+				"CurrentUser": adminUser.Email,
+				"Message":     "The poll does not exist or has been deleted.",
+			})
+			c.Abort()
+			return
+		}
+		if !data.CanAccessPoll(poll, adminUser, need) {
+			c.HTML(http.StatusForbidden, "noadmin.html", gin.H{"CurrentUser": adminUser.Email, "NoPollAccess": true})
+			c.Abort()
+			return
+		}
+		c.Set(pollContextKey, poll)
+		c.Next()
+	}
+}
+
+// currentAdmin returns the user loaded by AdminRequired.
+func currentAdmin(c *gin.Context) *data.AdminUser {
+	return c.MustGet(adminUserContextKey).(*data.AdminUser)
+}
+
+// currentPoll returns the poll loaded by PollRequired.
+func currentPoll(c *gin.Context) *data.Poll {
+	return c.MustGet(pollContextKey).(*data.Poll)
+}
@@ -0,0 +1,134 @@
+package pages
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/aspcodenet/systementorlivepolls/data"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestAdminRouter registers the poll pages with the middleware main.go uses for them.
+func newTestAdminRouter(email string) *gin.Engine {
+	router := newTestRouter(email)
+	router.LoadHTMLGlob("../templates/**")
+	admin := router.Group("/admin", AdminRequired)
+	admin.GET("/polls", AdminPolls)
+	admin.POST("/polls/save", EditorRequired, AdminPollsSavePOST)
+	admin.GET("/polls/delete/:pollID", EditorRequired, PollRequired(data.AccessOwner), AdminPollsDelete)
+	admin.POST("/polls/delete/:pollID", EditorRequired, PollRequired(data.AccessOwner), AdminPollsDeletePOST)
+	admin.POST("/polls/restore/:pollID", EditorRequired, AdminPollsRestorePOST)
+	admin.GET("/polls/copy/:pollID", EditorRequired, PollRequired(data.AccessEdit), AdminPollsCopy)
+	admin.POST("/polls/copy/:pollID", EditorRequired, PollRequired(data.AccessEdit), AdminPollsCopyPOST)
+	admin.GET("/polls/edit/:pollID", EditorRequired, PollRequired(data.AccessEdit), AdminPollsEdit)
+	admin.GET("/polls/controlpanel/:inviteID", EditorRequired, PollRequired(data.AccessRun), AdminPollsControlPanel)
+	return router
+}
+
+func countPolls(t *testing.T) int64 {
+	t.Helper()
+	var count int64
+	require.NoError(t, data.DB.Model(&data.Poll{}).Count(&count).Error)
+	return count
+}
+
+func TestAdminRequired(t *testing.T) {
+	setupTestDB(t)
+	createTestUser(t, "waiting@example.com", "")
+	inactive := createTestAdmin(t, "inactive@example.com")
+	require.NoError(t, data.SetAdminRole(inactive, data.RoleAdmin, false))
+	createTestAdmin(t, "admin@example.com")
+
+	for _, email := range []string{"", "unknown@example.com", "waiting@example.com", "inactive@example.com"} {
+		w := doRequest(newTestAdminRouter(email), "GET", "/admin/polls", "")
+		assert.Equal(t, http.StatusForbidden, w.Code, email)
+		assert.Contains(t, w.Body.String(), "not registered as admin", email)
+	}
+	assert.Equal(t, http.StatusOK, doRequest(newTestAdminRouter("admin@example.com"), "GET", "/admin/polls", "").Code)
+}
+
+func TestEditorRequiredWithSharedPoll(t *testing.T) {
+	setupTestDB(t)
+	owner := createTestAdmin(t, "owner@example.com")
+	viewer := createTestUser(t, "viewer@example.com", data.RoleViewer)
+	poll := createTestPoll(t, owner, "Quiz")
+	require.NoError(t, data.SharePoll(poll.ID, viewer.ID, data.AccessEdit))
+
+	// A poll shared to edit does not let a viewer change it
+	w := doRequest(newTestAdminRouter(viewer.Email), "GET", "/admin/polls/edit/"+idStr(poll.ID), "")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "You are a viewer")
+}
+
+func TestPollRequiredRejectsOthers(t *testing.T) {
+	setupTestDB(t)
+	owner := createTestAdmin(t, "owner@example.com")
+	other := createTestAdmin(t, "other@example.com")
+	poll := createTestPoll(t, owner, "Quiz")
+	router := newTestAdminRouter(other.Email)
+
+	for _, path := range []string{
+		"/admin/polls/edit/" + idStr(poll.ID),
+		"/admin/polls/copy/" + idStr(poll.ID),
+		"/admin/polls/delete/" + idStr(poll.ID),
+		"/admin/polls/controlpanel/" + poll.InviteID,
+	} {
+		w := doRequest(router, "GET", path, "")
+		assert.Equal(t, http.StatusForbidden, w.Code, path)
+		assert.Contains(t, w.Body.String(), "You do not have access to this poll", path)
+	}
+
+	// Posting does not copy, delete or restore the poll either
+	assert.Equal(t, http.StatusForbidden, doRequest(router, "POST", "/admin/polls/copy/"+idStr(poll.ID), "").Code)
+	assert.Equal(t, int64(1), countPolls(t))
+	w := doFormRequest(router, "/admin/polls/delete/"+idStr(poll.ID), url.Values{"name": {"Quiz"}})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Equal(t, int64(1), countPolls(t))
+
+	require.NoError(t, data.SoftDeletePoll(poll.ID))
+	assert.Equal(t, http.StatusForbidden, doRequest(router, "POST", "/admin/polls/restore/"+idStr(poll.ID), "").Code)
+	assert.Equal(t, int64(0), countPolls(t))
+
+	w = doRequest(router, "POST", "/admin/polls/save", `{"databaseId": `+idStr(poll.ID)+`, "title": "Mine now"}`)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestPollRequiredNotFound(t *testing.T) {
+	setupTestDB(t)
+	owner := createTestAdmin(t, "owner@example.com")
+	router := newTestAdminRouter(owner.Email)
+
+	for _, path := range []string{
+		"/admin/polls/edit/999",
+		"/admin/polls/edit/not-a-number",
+		"/admin/polls/copy/999",
+		"/admin/polls/delete/999",
+		"/admin/polls/controlpanel/no-such-invite",
+	} {
+		w := doRequest(router, "GET", path, "")
+		assert.Equal(t, http.StatusNotFound, w.Code, path)
+		assert.Contains(t, w.Body.String(), "The poll does not exist or has been deleted.", path)
+	}
+	assert.Equal(t, http.StatusNotFound, doFormRequest(router, "/admin/polls/delete/999", url.Values{"name": {"Quiz"}}).Code)
+	assert.Equal(t, http.StatusNotFound, doRequest(router, "POST", "/admin/polls/restore/999", "").Code)
+
+	w := doRequest(router, "POST", "/admin/polls/save", `{"databaseId": 999, "title": "Quiz"}`)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestPollRequiredLetsOwnerProceed(t *testing.T) {
+	setupTestDB(t)
+	owner := createTestAdmin(t, "owner@example.com")
+	poll := createTestPoll(t, owner, "Quiz")
+	router := newTestAdminRouter(owner.Email)
+
+	assert.Contains(t, doRequest(router, "GET", "/admin/polls/edit/"+idStr(poll.ID), "").Body.String(), "Quiz")
+	assert.Equal(t, http.StatusOK, doRequest(router, "GET", "/admin/polls/controlpanel/"+poll.InviteID, "").Code)
+
+	w := doFormRequest(router, "/admin/polls/delete/"+idStr(poll.ID), url.Values{"name": {"Quiz"}})
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, int64(0), countPolls(t))
+}
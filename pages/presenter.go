@@ -0,0 +1,47 @@
+package pages
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/aspcodenet/systementorlivepolls/data"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// presenterPath is where the presenter view of a poll is opened, without logging in.
+func presenterPath(p *data.Poll) string {
+	return "/present/" + p.PresenterToken
+}
+
+// Presenter shows the read-only projector view of the poll the secret presenter link belongs to.
+func Presenter(c *gin.Context) {
+	poll, err := data.GetPollByPresenterToken(c.Param("token"))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.String(http.StatusNotFound, "The presenter link is invalid or has been reset.")
+		return
+	} else if err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	c.HTML(http.StatusOK, "presenter.html", gin.H{
+		"Poll":     poll,
+		"Token":    poll.PresenterToken,
+		"JoinURL":  publicURL(c, "/poll/"+poll.InviteID),
+		"StartURL": publicURL(c, "/"),
+	})
+}
+
+// AdminPollsPresenterResetPOST gives the poll a new presenter link. The old one stops working,
+// although presenter views that are already open stay connected until they are reloaded.
+func AdminPollsPresenterResetPOST(c *gin.Context) {
+	poll := currentPoll(c)
+	if err := data.ResetPresenterToken(poll); err != nil {
+		log.Printf("Error resetting presenter link of poll %d: %v", poll.ID, err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	c.Redirect(302, "/admin/polls/controlpanel/"+poll.InviteID)
+}
@@ -0,0 +1,61 @@
+package pages
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/aspcodenet/systementorlivepolls/data"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestPresenterRouter(email string) *gin.Engine {
+	router := newTestRouter(email)
+	router.LoadHTMLGlob("../templates/**")
+	router.GET("/present/:token", Presenter)
+	router.POST("/admin/polls/presenter/reset/:inviteID", AdminRequired, PollRequired(data.AccessRun), AdminPollsPresenterResetPOST)
+	return router
+}
+
+func TestPresenter(t *testing.T) {
+	setupTestDB(t)
+	owner := createTestAdmin(t, "owner@example.com")
+	poll := createTestPoll(t, owner, "Quiz")
+	require.NoError(t, data.EnsurePresenterToken(poll))
+	router := newTestPresenterRouter("")
+
+	w := doRequest(router, http.MethodGet, "/present/"+poll.PresenterToken, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `data-invite-id="`+poll.InviteID+`"`)
+	assert.Contains(t, w.Body.String(), `data-token="`+poll.PresenterToken+`"`)
+	assert.Contains(t, w.Body.String(), "/poll/"+poll.InviteID)
+	assert.NotContains(t, w.Body.String(), "sendAdminAction")
+
+	w = doRequest(router, http.MethodGet, "/present/wrong", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestAdminPollsPresenterResetPOST(t *testing.T) {
+	setupTestDB(t)
+	owner := createTestAdmin(t, "owner@example.com")
+	other := createTestAdmin(t, "other@example.com")
+	poll := createTestPoll(t, owner, "Quiz")
+	require.NoError(t, data.EnsurePresenterToken(poll))
+	oldToken := poll.PresenterToken
+
+	w := doRequest(newTestPresenterRouter(other.Email), http.MethodPost, "/admin/polls/presenter/reset/"+poll.InviteID, "")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = doRequest(newTestPresenterRouter(owner.Email), http.MethodPost, "/admin/polls/presenter/reset/"+poll.InviteID, "")
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "/admin/polls/controlpanel/"+poll.InviteID, w.Header().Get("Location"))
+
+	var saved data.Poll
+	require.NoError(t, data.DB.First(&saved, poll.ID).Error)
+	assert.NotEmpty(t, saved.PresenterToken)
+	assert.NotEqual(t, oldToken, saved.PresenterToken)
+
+	w = doRequest(newTestPresenterRouter(""), http.MethodGet, "/present/"+oldToken, "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -0,0 +1,86 @@
+package pages
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/aspcodenet/systementorlivepolls/data"
+	"github.com/gin-gonic/gin"
+)
+
+// APIKeySlot is one of the two access/secret key pairs shown on the profile page.
+type APIKeySlot struct {
+	Slot      int
+	AccessKey string
+	NewSecret string // Only set right after the key was generated, the secret is never shown again
+}
+
+func apiKeySlots(adminUser *data.AdminUser) []APIKeySlot {
+	return []APIKeySlot{
+		{Slot: 1, AccessKey: adminUser.AccessKey1},
+		{Slot: 2, AccessKey: adminUser.AccessKey2},
+	}
+}
+
+func AdminProfile(c *gin.Context) {
+	adminUser := currentAdmin(c)
+	currentUser := adminUser.Email
+
+	c.HTML(http.StatusOK, "adminprofile.html", gin.H{
+		"CSRFToken":   csrfToken(c),
+		"title":       "Profile",
+		"CurrentUser": currentUser,
+		"AdminUser":   adminUser,
+		"IsOwner":     adminUser.IsOwner(),
+		"Keys":        apiKeySlots(adminUser),
+	})
+}
+
+func AdminProfileKeyGeneratePOST(c *gin.Context) {
+	adminUser := currentAdmin(c)
+	currentUser := adminUser.Email
+
+	slot, err := strconv.Atoi(c.Param("slot"))
+	if err != nil || (slot != 1 && slot != 2) {
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	_, secretKey, err := data.GenerateAPIKey(adminUser, slot)
+	if err != nil {
+		log.Printf("Error generating api key for %s: %v", currentUser, err)
+		c.AbortWithError(500, errors.New("Failed to generate api key"))
+		return
+	}
+
+	keys := apiKeySlots(adminUser)
+	keys[slot-1].NewSecret = secretKey
+	c.HTML(http.StatusOK, "adminprofile.html", gin.H{
+		"CSRFToken":   csrfToken(c),
+		"title":       "Profile",
+		"CurrentUser": currentUser,
+		"AdminUser":   adminUser,
+		"IsOwner":     adminUser.IsOwner(),
+		"Keys":        keys,
+	})
+}
+
+func AdminProfileKeyRevokePOST(c *gin.Context) {
+	adminUser := currentAdmin(c)
+	currentUser := adminUser.Email
+
+	slot, err := strconv.Atoi(c.Param("slot"))
+	if err != nil || (slot != 1 && slot != 2) {
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	if err := data.RevokeAPIKey(adminUser, slot); err != nil {
+		log.Printf("Error revoking api key for %s: %v", currentUser, err)
+		c.AbortWithError(500, errors.New("Failed to revoke api key"))
+		return
+	}
+	c.Redirect(302, "/admin/profile")
+}
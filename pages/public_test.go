@@ -0,0 +1,60 @@
+package pages
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/aspcodenet/systementorlivepolls/data"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestPublicRouter() *gin.Engine {
+	router := newTestRouter("")
+	router.LoadHTMLGlob("../templates/**")
+	router.GET("/poll/:inviteID", Poll)
+	router.POST("/selectpoll", SelectPoll)
+	return router
+}
+
+func TestPollJoinPIN(t *testing.T) {
+	setupTestDB(t)
+	owner := createTestAdmin(t, "owner@example.com")
+	poll := createTestPoll(t, owner, "Quiz")
+	require.NoError(t, data.AllocateJoinPIN(poll))
+	router := newTestPublicRouter()
+
+	w := doRequest(router, http.MethodGet, "/poll/"+*poll.JoinPIN, "")
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "/poll/"+poll.InviteID, w.Header().Get("Location"))
+
+	w = doFormRequest(router, "/selectpoll", url.Values{"inviteID": {(*poll.JoinPIN)[:3] + " " + (*poll.JoinPIN)[3:]}})
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "/poll/"+poll.InviteID, w.Header().Get("Location"))
+
+	w = doFormRequest(router, "/selectpoll", url.Values{"inviteID": {poll.InviteID}})
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "/poll/"+poll.InviteID, w.Header().Get("Location"))
+
+	pin := *poll.JoinPIN
+	require.NoError(t, data.ReleaseJoinPIN(poll))
+	w = doRequest(router, http.MethodGet, "/poll/"+pin, "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestAdminPollsJoinShowsPIN(t *testing.T) {
+	setupTestDB(t)
+	owner := createTestAdmin(t, "owner@example.com")
+	poll := createTestPoll(t, owner, "Quiz")
+	router := newTestQRRouter(owner.Email)
+
+	w := doRequest(router, http.MethodGet, "/admin/polls/join/"+poll.InviteID, "")
+	assert.Contains(t, w.Body.String(), "A short join code is shown here when the poll starts.")
+
+	require.NoError(t, data.AllocateJoinPIN(poll))
+	w = doRequest(router, http.MethodGet, "/admin/polls/join/"+poll.InviteID, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `<p id="joinPin"><strong>`+*poll.JoinPIN+`</strong></p>`)
+}
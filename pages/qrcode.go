@@ -0,0 +1,101 @@
+package pages
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/aspcodenet/systementorlivepolls/data"
+	"github.com/aspcodenet/systementorlivepolls/utils"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// QR code sizes in pixels.
+const (
+	defaultQRSize = 512
+	minQRSize     = 64
+	maxQRSize     = 2048
+)
+
+// publicURL returns the absolute URL of path as participants reach it. PUBLIC_BASE_URL, like
+// https://polls.example.com, is used when set, for servers behind a proxy that rewrites the host.
+func publicURL(c *gin.Context, path string) string {
+	if base := os.Getenv("PUBLIC_BASE_URL"); base != "" {
+		return strings.TrimRight(base, "/") + path
+	}
+	return requestScheme(c.Request) + "://" + c.Request.Host + path
+}
+
+// requestScheme returns https for requests over TLS, or forwarded as https by a proxy, and http
+// for the rest.
+func requestScheme(r *http.Request) string {
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		return "https"
+	}
+	return "http"
+}
+
+// PollQRCode sends a QR code of the poll's invite link. The query parameters are format (png or
+// svg), size in pixels and ecc, the error correction level L, M, Q or H.
+func PollQRCode(c *gin.Context) {
+	inviteID := c.Param("inviteID")
+	err := data.DB.First(&data.Poll{}, "invite_id=?", inviteID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	} else if err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	size, err := strconv.Atoi(c.DefaultQuery("size", strconv.Itoa(defaultQRSize)))
+	if err != nil || size < minQRSize || size > maxQRSize {
+		c.String(http.StatusBadRequest, "size must be between %d and %d.", minQRSize, maxQRSize)
+		return
+	}
+	level, ok := utils.ParseQRRecoveryLevel(c.DefaultQuery("ecc", "M"))
+	if !ok {
+		c.String(http.StatusBadRequest, "ecc must be L, M, Q or H.")
+		return
+	}
+
+	content := publicURL(c, "/poll/"+inviteID)
+	c.Header("Cache-Control", "public, max-age=3600")
+	switch c.DefaultQuery("format", "png") {
+	case "png":
+		png, err := utils.QRCodePNG(content, size, level)
+		if err != nil {
+			log.Printf("Error creating QR code for poll %s: %v", inviteID, err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		c.Data(http.StatusOK, "image/png", png)
+	case "svg":
+		var buf bytes.Buffer
+		if err := utils.WriteQRCodeSVG(&buf, content, size, level); err != nil {
+			log.Printf("Error creating QR code for poll %s: %v", inviteID, err)
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		c.Data(http.StatusOK, "image/svg+xml", buf.Bytes())
+	default:
+		c.String(http.StatusBadRequest, "format must be png or svg.")
+	}
+}
+
+// AdminPollsJoin shows the invite link of a poll as a large QR code, for the projector.
+func AdminPollsJoin(c *gin.Context) {
+	poll := currentPoll(c)
+
+	c.HTML(http.StatusOK, "adminpollsjoin.html", gin.H{
+		"CSRFToken": csrfToken(c),
+		"Poll":      poll,
+		"JoinURL":   publicURL(c, "/poll/"+poll.InviteID),
+		"StartURL":  publicURL(c, "/"),
+	})
+}
@@ -0,0 +1,62 @@
+package pages
+
+import (
+	"image/png"
+	"net/http"
+	"testing"
+
+	"github.com/aspcodenet/systementorlivepolls/data"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestQRRouter(email string) *gin.Engine {
+	router := newTestRouter(email)
+	router.LoadHTMLGlob("../templates/**")
+	router.GET("/poll/:inviteID/qr", PollQRCode)
+	router.GET("/admin/polls/join/:inviteID", AdminRequired, PollRequired(data.AccessRun), AdminPollsJoin)
+	return router
+}
+
+func TestPollQRCode(t *testing.T) {
+	setupTestDB(t)
+	owner := createTestAdmin(t, "owner@example.com")
+	poll := createTestPoll(t, owner, "Quiz")
+	router := newTestQRRouter("")
+
+	w := doRequest(router, http.MethodGet, "/poll/"+poll.InviteID+"/qr?size=300", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "image/png", w.Header().Get("Content-Type"))
+	img, err := png.Decode(w.Body)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 300, img.Bounds().Dx())
+	}
+
+	w = doRequest(router, http.MethodGet, "/poll/"+poll.InviteID+"/qr?format=svg&ecc=h", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "image/svg+xml", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), `width="512"`)
+
+	for _, query := range []string{"?size=10", "?ecc=X", "?format=gif"} {
+		w = doRequest(router, http.MethodGet, "/poll/"+poll.InviteID+"/qr"+query, "")
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+	w = doRequest(router, http.MethodGet, "/poll/unknown/qr", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestAdminPollsJoin(t *testing.T) {
+	t.Setenv("PUBLIC_BASE_URL", "https://polls.example.com/")
+	setupTestDB(t)
+	owner := createTestAdmin(t, "owner@example.com")
+	other := createTestAdmin(t, "other@example.com")
+	poll := createTestPoll(t, owner, "Quiz")
+
+	w := doRequest(newTestQRRouter(owner.Email), http.MethodGet, "/admin/polls/join/"+poll.InviteID, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "https://polls.example.com/poll/"+poll.InviteID)
+	assert.Contains(t, w.Body.String(), `src="/poll/`+poll.InviteID+`/qr?format=svg&amp;ecc=Q"`)
+
+	w = doRequest(newTestQRRouter(other.Email), http.MethodGet, "/admin/polls/join/"+poll.InviteID, "")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
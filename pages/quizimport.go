@@ -0,0 +1,145 @@
+package pages
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/aspcodenet/systementorlivepolls/data"
+	"github.com/aspcodenet/systementorlivepolls/quizformat"
+	"github.com/aspcodenet/systementorlivepolls/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// quizImportForm is what the question import page posts back, so the page can be shown again as filled in.
+type quizImportForm struct {
+	Format string
+	Text   string
+	Target string // "new" or the ID of an existing poll
+	Title  string // Title of the new poll
+}
+
+func AdminPollsQuestionsImport(c *gin.Context) {
+	adminUser := currentAdmin(c)
+
+	form := quizImportForm{Format: quizformat.Markdown, Target: c.DefaultQuery("pollID", "new")}
+	renderQuizImport(c, http.StatusOK, adminUser, form, nil, "")
+}
+
+// AdminPollsQuestionsImportPOST previews the questions found in the posted text, or with
+// action=import adds them to a new or existing poll.
+func AdminPollsQuestionsImportPOST(c *gin.Context) {
+	adminUser := currentAdmin(c)
+
+	form := quizImportForm{
+		Format: c.PostForm("format"),
+		Text:   c.PostForm("text"),
+		Target: c.DefaultPostForm("target", "new"),
+		Title:  strings.TrimSpace(c.PostForm("title")),
+	}
+	// An uploaded file wins over the text area
+	if file, err := c.FormFile("file"); err == nil {
+		if file.Size > maxDefinitionSize {
+			renderQuizImport(c, http.StatusBadRequest, adminUser, form, nil, "The file is too large.")
+			return
+		}
+		f, err := file.Open()
+		if err == nil {
+			var raw []byte
+			raw, err = io.ReadAll(f)
+			f.Close()
+			form.Text = string(raw)
+		}
+		if err != nil {
+			renderQuizImport(c, http.StatusBadRequest, adminUser, form, nil, "Failed to read the uploaded file.")
+			return
+		}
+	}
+
+	result, err := quizformat.Parse(form.Format, form.Text)
+	if err != nil {
+		renderQuizImport(c, http.StatusBadRequest, adminUser, form, nil, "Choose one of the formats.")
+		return
+	}
+	if c.PostForm("action") != "import" {
+		renderQuizImport(c, http.StatusOK, adminUser, form, result, "")
+		return
+	}
+	if len(result.Errors) > 0 || len(result.Questions) == 0 {
+		renderQuizImport(c, http.StatusBadRequest, adminUser, form, result, "Fix the problems below before importing.")
+		return
+	}
+
+	var poll *data.Poll
+	if form.Target == "new" {
+		limitReached, err := data.PollLimitReached(adminUser)
+		if err != nil {
+			log.Printf("Error checking poll limit: %v", err)
+			renderQuizImport(c, http.StatusInternalServerError, adminUser, form, result, "Failed to save the questions.")
+			return
+		}
+		if limitReached {
+			renderQuizImport(c, http.StatusConflict, adminUser, form, result, errMaxPolls.Error())
+			return
+		}
+		if form.Title == "" {
+			renderQuizImport(c, http.StatusBadRequest, adminUser, form, result, "Enter a title for the new poll.")
+			return
+		}
+		randString, _ := utils.RandString(16)
+		poll = &data.Poll{
+			Title:                form.Title,
+			CurrentQuestionIndex: -1, // No question active yet
+			Status:               "setup",
+			AdminUserID:          int(adminUser.ID),
+			InviteID:             randString,
+		}
+	} else {
+		pollID, err := strconv.Atoi(form.Target)
+		if err != nil {
+			c.AbortWithStatus(http.StatusBadRequest)
+			return
+		}
+		poll, err = data.GetPollAndDetailsForAdmin(uint(pollID))
+		if err != nil || !data.CanAccessPoll(poll, adminUser, data.AccessEdit) {
+			c.HTML(http.StatusForbidden, "noadmin.html", gin.H{"CurrentUser": adminUser.Email, "NoPollAccess": true})
+			return
+		}
+		if poll.Status == "active" || poll.Status == "results" {
+			renderQuizImport(c, http.StatusConflict, adminUser, form, result, "Questions cannot be added while the poll is running.")
+			return
+		}
+	}
+
+	if len(poll.Questions)+len(result.Questions) > data.MaxDefinitionQuestions {
+		renderQuizImport(c, http.StatusBadRequest, adminUser, form, result, fmt.Sprintf("A poll can have at most %d questions.", data.MaxDefinitionQuestions))
+		return
+	}
+	poll.Questions = append(poll.Questions, result.Questions...)
+	if err := data.DB.Save(poll).Error; err != nil {
+		log.Printf("Error importing questions into poll: %v", err)
+		renderQuizImport(c, http.StatusInternalServerError, adminUser, form, result, "Failed to save the questions.")
+		return
+	}
+	c.Redirect(302, "/admin/polls/edit/"+strconv.Itoa(int(poll.ID)))
+}
+
+func renderQuizImport(c *gin.Context, status int, adminUser *data.AdminUser, form quizImportForm, result *quizformat.Result, message string) {
+	polls, err := data.GetAccessiblePolls(adminUser, data.AccessEdit)
+	if err != nil {
+		log.Printf("Error retrieving polls of admin %d: %v", adminUser.ID, err)
+	}
+	c.HTML(status, "adminpollsquestionsimport.html", gin.H{
+		"CSRFToken": csrfToken(c),
+		"title":     "Import questions",
+		"AdminUser": adminUser,
+		"Form":      form,
+		"Formats":   quizformat.Formats,
+		"Polls":     polls,
+		"Result":    result,
+		"Message":   message,
+	})
+}
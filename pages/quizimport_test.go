@@ -0,0 +1,95 @@
+package pages
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/aspcodenet/systementorlivepolls/data"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestQuizImportRouter(email string) *gin.Engine {
+	router := newTestRouter(email)
+	router.LoadHTMLGlob("../templates/**")
+	admin := router.Group("/admin", AdminRequired)
+	admin.GET("/polls/questions/import", AdminPollsQuestionsImport)
+	admin.POST("/polls/questions/import", AdminPollsQuestionsImportPOST)
+	return router
+}
+
+const aikenQuiz = "What is 2 + 2?\nA. 3\nB. 4\nANSWER: B\n"
+
+func TestAdminPollsQuestionsImportPreview(t *testing.T) {
+	setupTestDB(t)
+	owner := createTestAdmin(t, "owner@example.com")
+	router := newTestQuizImportRouter(owner.Email)
+
+	form := url.Values{"format": {"aiken"}, "text": {aikenQuiz + "\nBroken\nA. x\n"}, "target": {"new"}, "title": {"Quiz"}}
+	w := doFormRequest(router, "/admin/polls/questions/import", form)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "4 <mark>right answer</mark>")
+	assert.Contains(t, w.Body.String(), "Line 6:")
+	assert.NotContains(t, w.Body.String(), `value="import"`, "import should not be offered while there are errors")
+
+	form.Set("action", "import")
+	w = doFormRequest(router, "/admin/polls/questions/import", form)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	var count int64
+	data.DB.Model(&data.Poll{}).Count(&count)
+	assert.Equal(t, int64(0), count)
+}
+
+func TestAdminPollsQuestionsImportIntoPolls(t *testing.T) {
+	setupTestDB(t)
+	owner := createTestAdmin(t, "owner@example.com")
+	existing := createTestPoll(t, owner, "Existing")
+	router := newTestQuizImportRouter(owner.Email)
+
+	form := url.Values{"format": {"aiken"}, "text": {aikenQuiz}, "target": {"new"}, "title": {"Quiz"}, "action": {"import"}}
+	w := doFormRequest(router, "/admin/polls/questions/import", form)
+	assert.Equal(t, http.StatusFound, w.Code, w.Body.String())
+	var created data.Poll
+	assert.NoError(t, data.DB.Preload("Questions.Options").Where("title = ?", "Quiz").First(&created).Error)
+	assert.Equal(t, int(owner.ID), created.AdminUserID)
+	if assert.Len(t, created.Questions, 1) && assert.Len(t, created.Questions[0].Options, 2) {
+		assert.True(t, created.Questions[0].Options[1].Correct)
+	}
+
+	form.Set("target", idStr(existing.ID))
+	w = doFormRequest(router, "/admin/polls/questions/import", form)
+	assert.Equal(t, http.StatusFound, w.Code)
+	updated, _ := data.GetPollAndDetailsForAdmin(existing.ID)
+	assert.Len(t, updated.Questions, 2, "the imported question should be added after the existing one")
+
+	// Polls of other admins cannot be targeted
+	other := createTestAdmin(t, "other@example.com")
+	w = doFormRequest(newTestQuizImportRouter(other.Email), "/admin/polls/questions/import", form)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "other@example.com")
+	updated, _ = data.GetPollAndDetailsForAdmin(existing.ID)
+	assert.Len(t, updated.Questions, 2)
+}
+
+func TestAdminPollsQuestionsImportLimitsQuestions(t *testing.T) {
+	setupTestDB(t)
+	owner := createTestAdmin(t, "owner@example.com")
+	existing := createTestPoll(t, owner, "Existing")
+	router := newTestQuizImportRouter(owner.Email)
+
+	// The poll already has a question, so the limit is one question less
+	form := url.Values{"format": {"aiken"}, "text": {strings.Repeat(aikenQuiz+"\n", data.MaxDefinitionQuestions)}, "target": {idStr(existing.ID)}, "action": {"import"}}
+	w := doFormRequest(router, "/admin/polls/questions/import", form)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "at most 100 questions")
+	updated, _ := data.GetPollAndDetailsForAdmin(existing.ID)
+	assert.Len(t, updated.Questions, 1)
+
+	form.Set("text", strings.Repeat(aikenQuiz+"\n", data.MaxDefinitionQuestions-1))
+	w = doFormRequest(router, "/admin/polls/questions/import", form)
+	assert.Equal(t, http.StatusFound, w.Code)
+	updated, _ = data.GetPollAndDetailsForAdmin(existing.ID)
+	assert.Len(t, updated.Questions, data.MaxDefinitionQuestions)
+}
@@ -0,0 +1,146 @@
+package pages
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/aspcodenet/systementorlivepolls/data"
+	"github.com/gin-gonic/gin"
+)
+
+// AdminPollsShare shows whom a poll is shared with and lets its owner share it, move it to a
+// workspace or transfer it to a colleague. Use it, and the other sharing pages, after
+// PollRequired(data.AccessOwner).
+func AdminPollsShare(c *gin.Context) {
+	adminUser, poll := currentAdmin(c), currentPoll(c)
+	currentUser := adminUser.Email
+	renderPollSharing(c, http.StatusOK, currentUser, adminUser, poll, "")
+}
+
+// AdminPollsSharePOST shares a poll with an admin, changes their access or, with an empty access,
+// stops sharing it with them.
+func AdminPollsSharePOST(c *gin.Context) {
+	adminUser, poll := currentAdmin(c), currentPoll(c)
+	currentUser := adminUser.Email
+
+	access := c.PostForm("access")
+	if access != "" && access != data.AccessEdit && access != data.AccessRun {
+		renderPollSharing(c, http.StatusBadRequest, currentUser, adminUser, poll, "Unknown access.")
+		return
+	}
+	target, err := data.GetAdminUserByEmail(c.PostForm("email"))
+	if errors.Is(err, data.ErrNoSuchAdmin) {
+		renderPollSharing(c, http.StatusBadRequest, currentUser, adminUser, poll, "Nobody with that email address has logged in yet.")
+		return
+	} else if err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	if poll.WorkspaceID == nil && poll.AdminUserID == int(target.ID) {
+		renderPollSharing(c, http.StatusBadRequest, currentUser, adminUser, poll, target.Email+" owns the poll.")
+		return
+	}
+
+	if err := data.SharePoll(poll.ID, target.ID, access); err != nil {
+		log.Printf("Error sharing poll %d with admin user %d: %v", poll.ID, target.ID, err)
+		renderPollSharing(c, http.StatusInternalServerError, currentUser, adminUser, poll, "Failed to save the change.")
+		return
+	}
+	c.Redirect(302, "/admin/polls/share/"+strconv.Itoa(int(poll.ID)))
+}
+
+// AdminPollsMovePOST moves a poll into one of the current user's workspaces or, with workspace 0,
+// out of its workspace to be a personal poll of the current user.
+func AdminPollsMovePOST(c *gin.Context) {
+	adminUser, poll := currentAdmin(c), currentPoll(c)
+	currentUser := adminUser.Email
+
+	workspaceID, err := strconv.Atoi(c.PostForm("workspaceID"))
+	if err != nil || workspaceID < 0 {
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	var target *uint
+	if workspaceID != 0 {
+		id := uint(workspaceID)
+		if !data.HasAccess(data.WorkspaceAccess(id, adminUser.ID), data.AccessEdit) {
+			renderPollSharing(c, http.StatusForbidden, currentUser, adminUser, poll, "You cannot add polls to that workspace.")
+			return
+		}
+		target = &id
+	}
+
+	if err := data.MovePoll(poll, target, adminUser.ID); err != nil {
+		log.Printf("Error moving poll %d: %v", poll.ID, err)
+		renderPollSharing(c, http.StatusInternalServerError, currentUser, adminUser, poll, "Failed to move the poll.")
+		return
+	}
+	c.Redirect(302, "/admin/polls/share/"+strconv.Itoa(int(poll.ID)))
+}
+
+// AdminPollsTransferPOST makes a colleague the owner of a personal poll.
+func AdminPollsTransferPOST(c *gin.Context) {
+	adminUser, poll := currentAdmin(c), currentPoll(c)
+	currentUser := adminUser.Email
+	if poll.WorkspaceID != nil {
+		renderPollSharing(c, http.StatusConflict, currentUser, adminUser, poll, "The poll belongs to a workspace. Change the workspace members instead, or move the poll out of the workspace first.")
+		return
+	}
+
+	target, err := data.GetAdminUserByEmail(c.PostForm("email"))
+	if errors.Is(err, data.ErrNoSuchAdmin) {
+		renderPollSharing(c, http.StatusBadRequest, currentUser, adminUser, poll, "Nobody with that email address has logged in yet.")
+		return
+	} else if err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	if !target.CanEdit() {
+		renderPollSharing(c, http.StatusBadRequest, currentUser, adminUser, poll, target.Email+" may not create polls.")
+		return
+	}
+
+	if err := data.TransferPoll(poll, target.ID); err != nil {
+		log.Printf("Error transferring poll %d to admin user %d: %v", poll.ID, target.ID, err)
+		renderPollSharing(c, http.StatusInternalServerError, currentUser, adminUser, poll, "Failed to transfer the poll.")
+		return
+	}
+	c.Redirect(302, "/admin/polls")
+}
+
+func renderPollSharing(c *gin.Context, status int, currentUser string, adminUser *data.AdminUser, poll *data.Poll, message string) {
+	shares, err := data.GetPollShares(poll.ID)
+	if err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	workspaces, err := data.GetWorkspacesForAdmin(adminUser.ID)
+	if err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	// Polls can only be moved to workspaces the user may add polls to
+	targets := []data.WorkspaceWithAccess{}
+	for _, w := range workspaces {
+		if data.HasAccess(w.Access, data.AccessEdit) {
+			targets = append(targets, w)
+		}
+	}
+	var workspaceID uint
+	if poll.WorkspaceID != nil {
+		workspaceID = *poll.WorkspaceID
+	}
+	c.HTML(status, "adminpollsshare.html", gin.H{
+		"CSRFToken":   csrfToken(c),
+		"title":       "Share " + poll.Title,
+		"CurrentUser": currentUser,
+		"Poll":        poll,
+		"WorkspaceID": workspaceID,
+		"Personal":    poll.WorkspaceID == nil,
+		"Shares":      shares,
+		"Workspaces":  targets,
+		"Message":     message,
+	})
+}
@@ -0,0 +1,138 @@
+package pages
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/aspcodenet/systementorlivepolls/data"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestSharingRouter(email string) *gin.Engine {
+	router := newTestRouter(email)
+	router.LoadHTMLGlob("../templates/**")
+	admin := router.Group("/admin", AdminRequired)
+	admin.GET("/polls", AdminPolls)
+	admin.GET("/polls/edit/:pollID", PollRequired(data.AccessEdit), AdminPollsEdit)
+	admin.GET("/polls/controlpanel/:inviteID", PollRequired(data.AccessRun), AdminPollsControlPanel)
+	admin.GET("/polls/delete/:pollID", PollRequired(data.AccessOwner), AdminPollsDelete)
+	admin.POST("/polls/copy/:pollID", PollRequired(data.AccessEdit), AdminPollsCopyPOST)
+	admin.GET("/polls/share/:pollID", PollRequired(data.AccessOwner), AdminPollsShare)
+	admin.POST("/polls/share/:pollID", PollRequired(data.AccessOwner), AdminPollsSharePOST)
+	admin.POST("/polls/move/:pollID", PollRequired(data.AccessOwner), AdminPollsMovePOST)
+	admin.POST("/polls/transfer/:pollID", PollRequired(data.AccessOwner), AdminPollsTransferPOST)
+	admin.POST("/users/:userID/transfer", OwnerRequired, AdminUsersTransferPOST)
+	return router
+}
+
+func TestSharePollToRun(t *testing.T) {
+	setupTestDB(t)
+	alice := createTestAdmin(t, "alice@example.com")
+	bob := createTestAdmin(t, "bob@example.com")
+	poll := createTestPoll(t, alice, "Quiz")
+	asBob := newTestSharingRouter(bob.Email)
+
+	assert.Equal(t, http.StatusForbidden, doRequest(asBob, "GET", "/admin/polls/controlpanel/"+poll.InviteID, "").Code)
+	assert.NotContains(t, doRequest(asBob, "GET", "/admin/polls", "").Body.String(), "Quiz")
+
+	// Only the owner shares
+	w := doFormRequest(asBob, "/admin/polls/share/"+idStr(poll.ID), url.Values{"email": {bob.Email}, "access": {"edit"}})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = doFormRequest(newTestSharingRouter(alice.Email), "/admin/polls/share/"+idStr(poll.ID), url.Values{"email": {bob.Email}, "access": {"run"}})
+	assert.Equal(t, http.StatusFound, w.Code)
+
+	assert.Contains(t, doRequest(asBob, "GET", "/admin/polls", "").Body.String(), "Quiz")
+	assert.Equal(t, http.StatusOK, doRequest(asBob, "GET", "/admin/polls/controlpanel/"+poll.InviteID, "").Code)
+	assert.Equal(t, http.StatusForbidden, doRequest(asBob, "GET", "/admin/polls/edit/"+idStr(poll.ID), "").Code)
+	assert.Equal(t, http.StatusForbidden, doRequest(asBob, "GET", "/admin/polls/delete/"+idStr(poll.ID), "").Code)
+
+	// Unknown people cannot be shared with
+	w = doFormRequest(newTestSharingRouter(alice.Email), "/admin/polls/share/"+idStr(poll.ID), url.Values{"email": {"nobody@example.com"}, "access": {"run"}})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// An empty access stops sharing
+	w = doFormRequest(newTestSharingRouter(alice.Email), "/admin/polls/share/"+idStr(poll.ID), url.Values{"email": {bob.Email}})
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, http.StatusForbidden, doRequest(asBob, "GET", "/admin/polls/controlpanel/"+poll.InviteID, "").Code)
+}
+
+func TestSharePollToEdit(t *testing.T) {
+	setupTestDB(t)
+	alice := createTestAdmin(t, "alice@example.com")
+	bob := createTestAdmin(t, "bob@example.com")
+	poll := createTestPoll(t, alice, "Quiz")
+	require.NoError(t, data.SharePoll(poll.ID, bob.ID, data.AccessEdit))
+	asBob := newTestSharingRouter(bob.Email)
+
+	assert.Contains(t, doRequest(asBob, "GET", "/admin/polls/edit/"+idStr(poll.ID), "").Body.String(), "Quiz")
+
+	// The copy is bob's own
+	w := doRequest(asBob, "POST", "/admin/polls/copy/"+idStr(poll.ID), "")
+	assert.Equal(t, http.StatusFound, w.Code)
+	var pollCopy data.Poll
+	require.NoError(t, data.DB.First(&pollCopy, "title = ?", "Copy of Quiz").Error)
+	assert.Equal(t, int(bob.ID), pollCopy.AdminUserID)
+}
+
+func TestMovePollToWorkspace(t *testing.T) {
+	setupTestDB(t)
+	alice := createTestAdmin(t, "alice@example.com")
+	bob := createTestAdmin(t, "bob@example.com")
+	poll := createTestPoll(t, alice, "Quiz")
+	workspace, err := data.CreateWorkspace("Teachers", alice)
+	require.NoError(t, err)
+	require.NoError(t, data.SetWorkspaceMember(workspace.ID, bob.ID, data.AccessEdit))
+	other, err := data.CreateWorkspace("Other", bob)
+	require.NoError(t, err)
+	asAlice := newTestSharingRouter(alice.Email)
+
+	w := doFormRequest(asAlice, "/admin/polls/move/"+idStr(poll.ID), url.Values{"workspaceID": {idStr(other.ID)}})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = doFormRequest(asAlice, "/admin/polls/move/"+idStr(poll.ID), url.Values{"workspaceID": {idStr(workspace.ID)}})
+	assert.Equal(t, http.StatusFound, w.Code)
+	body := doRequest(newTestSharingRouter(bob.Email), "GET", "/admin/polls", "").Body.String()
+	assert.Contains(t, body, "Quiz")
+	assert.Contains(t, body, "Teachers")
+
+	// Workspace polls are not transferred, the workspace owns them
+	w = doFormRequest(asAlice, "/admin/polls/transfer/"+idStr(poll.ID), url.Values{"email": {bob.Email}})
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
+
+func TestTransferPoll(t *testing.T) {
+	setupTestDB(t)
+	alice := createTestAdmin(t, "alice@example.com")
+	bob := createTestAdmin(t, "bob@example.com")
+	createTestUser(t, "viewer@example.com", data.RoleViewer)
+	poll := createTestPoll(t, alice, "Quiz")
+	asAlice := newTestSharingRouter(alice.Email)
+
+	w := doFormRequest(asAlice, "/admin/polls/transfer/"+idStr(poll.ID), url.Values{"email": {"viewer@example.com"}})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = doFormRequest(asAlice, "/admin/polls/transfer/"+idStr(poll.ID), url.Values{"email": {bob.Email}})
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, http.StatusForbidden, doRequest(asAlice, "GET", "/admin/polls/controlpanel/"+poll.InviteID, "").Code)
+	assert.Equal(t, http.StatusOK, doRequest(newTestSharingRouter(bob.Email), "GET", "/admin/polls/share/"+idStr(poll.ID), "").Code)
+}
+
+func TestTransferPollsOfLeavingColleague(t *testing.T) {
+	setupTestDB(t)
+	owner := createTestUser(t, "owner@example.com", data.RoleOwner)
+	leaving := createTestAdmin(t, "leaving@example.com")
+	poll := createTestPoll(t, leaving, "Quiz")
+
+	w := doFormRequest(newTestSharingRouter(leaving.Email), "/admin/users/"+idStr(leaving.ID)+"/transfer", url.Values{"email": {leaving.Email}})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = doFormRequest(newTestSharingRouter(owner.Email), "/admin/users/"+idStr(leaving.ID)+"/transfer", url.Values{"email": {owner.Email}})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "1 polls of leaving@example.com now belong to owner@example.com")
+	data.DB.First(poll, poll.ID)
+	assert.Equal(t, int(owner.ID), poll.AdminUserID)
+}
@@ -0,0 +1,134 @@
+package pages
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/aspcodenet/systementorlivepolls/data"
+	"github.com/gin-gonic/gin"
+)
+
+// adminUserRow is a user on the admin management page.
+type adminUserRow struct {
+	data.AdminUser
+	IsCurrentUser bool
+}
+
+// adminInviteRow is an invitation that can still be used, on the admin management page.
+type adminInviteRow struct {
+	*data.AdminInvite
+	URL string
+}
+
+// AdminUsers lists everyone who has logged in, so owners can give them a role.
+func AdminUsers(c *gin.Context) {
+	adminUser := currentAdmin(c)
+	currentUser := adminUser.Email
+
+	renderAdminUsers(c, http.StatusOK, currentUser, adminUser, "")
+}
+
+// AdminUsersUpdatePOST changes the role and active flag of a user.
+func AdminUsersUpdatePOST(c *gin.Context) {
+	adminUser := currentAdmin(c)
+	currentUser := adminUser.Email
+
+	userID, err := strconv.Atoi(c.Param("userID"))
+	if err != nil {
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	var target data.AdminUser
+	if err := data.DB.First(&target, userID).Error; err != nil {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	role := c.PostForm("role")
+	if role != "" && !data.ValidRole(role) {
+		renderAdminUsers(c, http.StatusBadRequest, currentUser, adminUser, "Unknown role.")
+		return
+	}
+
+	err = data.SetAdminRole(&target, role, c.PostForm("active") == "on")
+	if errors.Is(err, data.ErrLastOwner) {
+		renderAdminUsers(c, http.StatusConflict, currentUser, adminUser, target.Email+" is the last owner. Make someone else owner first.")
+		return
+	} else if err != nil {
+		log.Printf("Error changing the role of admin user %d: %v", target.ID, err)
+		renderAdminUsers(c, http.StatusInternalServerError, currentUser, adminUser, "Failed to save the change.")
+		return
+	}
+	c.Redirect(302, "/admin/users")
+}
+
+// AdminUsersTransferPOST gives all personal polls and webhooks of a user, e.g. a colleague who
+// leaves, to the admin with the posted email address.
+func AdminUsersTransferPOST(c *gin.Context) {
+	adminUser := currentAdmin(c)
+	currentUser := adminUser.Email
+
+	userID, err := strconv.Atoi(c.Param("userID"))
+	if err != nil {
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+	var from data.AdminUser
+	if err := data.DB.First(&from, userID).Error; err != nil {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+	to, err := data.GetAdminUserByEmail(c.PostForm("email"))
+	if errors.Is(err, data.ErrNoSuchAdmin) {
+		renderAdminUsers(c, http.StatusBadRequest, currentUser, adminUser, "Nobody with that email address has logged in yet.")
+		return
+	} else if err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	if to.ID == from.ID || !to.CanEdit() {
+		renderAdminUsers(c, http.StatusBadRequest, currentUser, adminUser, "Transfer the polls to another active owner or admin.")
+		return
+	}
+
+	count, err := data.TransferAdminPolls(from.ID, to.ID)
+	if err != nil {
+		log.Printf("Error transferring the polls of admin user %d to %d: %v", from.ID, to.ID, err)
+		renderAdminUsers(c, http.StatusInternalServerError, currentUser, adminUser, "Failed to transfer the polls.")
+		return
+	}
+	renderAdminUsers(c, http.StatusOK, currentUser, adminUser, strconv.Itoa(count)+" polls of "+from.Email+" now belong to "+to.Email+".")
+}
+
+func renderAdminUsers(c *gin.Context, status int, currentUser string, adminUser *data.AdminUser, message string) {
+	users, err := data.GetAdminUsers()
+	if err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	rows := make([]adminUserRow, 0, len(users))
+	for _, u := range users {
+		rows = append(rows, adminUserRow{AdminUser: u, IsCurrentUser: u.ID == adminUser.ID})
+	}
+	invites, err := data.GetAdminInvites(time.Now())
+	if err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	inviteRows := make([]adminInviteRow, 0, len(invites))
+	for _, invite := range invites {
+		inviteRows = append(inviteRows, adminInviteRow{AdminInvite: invite, URL: publicURL(c, invitePath(invite))})
+	}
+	c.HTML(status, "adminusers.html", gin.H{
+		"CSRFToken":      csrfToken(c),
+		"title":          "Administrators",
+		"CurrentUser":    currentUser,
+		"Users":          rows,
+		"Invites":        inviteRows,
+		"Roles":          data.Roles,
+		"InviteValidity": inviteValidity,
+		"Message":        message,
+	})
+}
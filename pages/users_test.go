@@ -0,0 +1,111 @@
+package pages
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/aspcodenet/systementorlivepolls/data"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestUsersRouter(email string) *gin.Engine {
+	router := newTestRouter(email)
+	router.LoadHTMLGlob("../templates/**")
+	admin := router.Group("/admin", AdminRequired)
+	admin.GET("/users", OwnerRequired, AdminUsers)
+	admin.POST("/users/:userID", OwnerRequired, AdminUsersUpdatePOST)
+	admin.GET("/polls", AdminPolls)
+	admin.GET("/polls/new", EditorRequired, AdminPollsNew)
+	return router
+}
+
+// createTestUser creates a user with a role, "" for a user who has only logged in.
+func createTestUser(t *testing.T, email, role string) *data.AdminUser {
+	t.Helper()
+	user := createTestAdmin(t, email)
+	require.NoError(t, data.SetAdminRole(user, role, true))
+	return user
+}
+
+func TestAdminUsersIsForOwners(t *testing.T) {
+	setupTestDB(t)
+	createTestUser(t, "owner@example.com", data.RoleOwner)
+	createTestUser(t, "admin@example.com", data.RoleAdmin)
+	createTestUser(t, "new@example.com", "")
+
+	w := doRequest(newTestUsersRouter("owner@example.com"), "GET", "/admin/users", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "admin@example.com")
+	assert.Contains(t, w.Body.String(), "new@example.com")
+
+	w = doRequest(newTestUsersRouter("admin@example.com"), "GET", "/admin/users", "")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.NotContains(t, w.Body.String(), "new@example.com")
+	w = doFormRequest(newTestUsersRouter("admin@example.com"), "/admin/users/3", url.Values{"role": {"owner"}, "active": {"on"}})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	var user data.AdminUser
+	data.DB.First(&user, 3)
+	assert.Empty(t, user.Role)
+}
+
+func TestAdminUsersUpdate(t *testing.T) {
+	setupTestDB(t)
+	owner := createTestUser(t, "owner@example.com", data.RoleOwner)
+	newcomer := createTestUser(t, "new@example.com", "")
+	router := newTestUsersRouter(owner.Email)
+
+	assert.Contains(t, doRequest(newTestUsersRouter(newcomer.Email), "GET", "/admin/polls", "").Body.String(), "not registered as admin")
+
+	w := doFormRequest(router, "/admin/users/"+idStr(newcomer.ID), url.Values{"role": {"viewer"}, "active": {"on"}})
+	assert.Equal(t, http.StatusFound, w.Code)
+	data.DB.First(newcomer, newcomer.ID)
+	assert.Equal(t, data.RoleViewer, newcomer.Role)
+	assert.True(t, newcomer.Active)
+	assert.Equal(t, http.StatusOK, doRequest(newTestUsersRouter(newcomer.Email), "GET", "/admin/polls", "").Code)
+
+	// Unchecking active keeps the role but takes the access away
+	w = doFormRequest(router, "/admin/users/"+idStr(newcomer.ID), url.Values{"role": {"viewer"}})
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Contains(t, doRequest(newTestUsersRouter(newcomer.Email), "GET", "/admin/polls", "").Body.String(), "not registered as admin")
+
+	w = doFormRequest(router, "/admin/users/"+idStr(newcomer.ID), url.Values{"role": {"superuser"}, "active": {"on"}})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = doFormRequest(router, "/admin/users/"+idStr(owner.ID), url.Values{"role": {"admin"}, "active": {"on"}})
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), "is the last owner")
+	data.DB.First(owner, owner.ID)
+	assert.Equal(t, data.RoleOwner, owner.Role)
+}
+
+func TestEditorRequired(t *testing.T) {
+	setupTestDB(t)
+	createTestUser(t, "admin@example.com", data.RoleAdmin)
+	createTestUser(t, "viewer@example.com", data.RoleViewer)
+	createTestUser(t, "new@example.com", "")
+
+	assert.Equal(t, http.StatusOK, doRequest(newTestUsersRouter("admin@example.com"), "GET", "/admin/polls/new", "").Code)
+
+	w := doRequest(newTestUsersRouter("viewer@example.com"), "GET", "/admin/polls/new", "")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "You are a viewer")
+	assert.Equal(t, http.StatusOK, doRequest(newTestUsersRouter("viewer@example.com"), "GET", "/admin/polls", "").Code)
+
+	w = doRequest(newTestUsersRouter("new@example.com"), "GET", "/admin/polls/new", "")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "not registered as admin")
+}
+
+func TestAPIViewerCannotChangePolls(t *testing.T) {
+	setupTestDB(t)
+	viewer := createTestUser(t, "viewer@example.com", data.RoleViewer)
+	router := newTestAPIRouter(viewer.Email)
+
+	assert.Equal(t, http.StatusOK, doRequest(router, "GET", "/api/v1/polls", "").Code)
+	w := doRequest(router, "POST", "/api/v1/polls", `{"title":"Quiz","questions":[]}`)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "Viewers cannot change polls")
+}
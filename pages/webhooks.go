@@ -0,0 +1,176 @@
+package pages
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/aspcodenet/systementorlivepolls/data"
+	"github.com/aspcodenet/systementorlivepolls/webhooks"
+	"github.com/gin-gonic/gin"
+)
+
+// maxWebhookDeliveries is how many delivery attempts the delivery log page shows.
+const maxWebhookDeliveries = 100
+
+// webhookForm is what the new webhook form posts back, so the page can be shown again as filled in.
+type webhookForm struct {
+	URL    string
+	PollID uint
+	Events []string
+}
+
+func AdminWebhooks(c *gin.Context) {
+	adminUser := currentAdmin(c)
+	currentUser := adminUser.Email
+
+	renderWebhooks(c, http.StatusOK, currentUser, adminUser, webhookForm{}, nil, "")
+}
+
+// AdminWebhooksCreatePOST subscribes a URL to events of one or all of the admin's polls.
+// The secret is shown once, on the page rendered after creating.
+func AdminWebhooksCreatePOST(c *gin.Context) {
+	adminUser := currentAdmin(c)
+	currentUser := adminUser.Email
+
+	form := webhookForm{URL: strings.TrimSpace(c.PostForm("url")), Events: c.PostFormArray("events")}
+	if pollID, err := strconv.Atoi(c.DefaultPostForm("pollID", "0")); err == nil && pollID > 0 {
+		form.PollID = uint(pollID)
+	}
+
+	if err := webhooks.ValidateURL(form.URL); err != nil || len(form.URL) > 500 {
+		message := "Enter an http or https URL."
+		if errors.Is(err, webhooks.ErrPrivateAddress) {
+			message = "Webhooks cannot be sent to private, loopback or link-local addresses."
+		}
+		renderWebhooks(c, http.StatusBadRequest, currentUser, adminUser, form, nil, message)
+		return
+	}
+	for _, event := range form.Events {
+		if !slices.Contains(webhooks.Events, event) {
+			renderWebhooks(c, http.StatusBadRequest, currentUser, adminUser, form, nil, "Unknown event "+event+".")
+			return
+		}
+	}
+	if len(form.Events) == len(webhooks.Events) {
+		form.Events = nil // All events, including those added later
+	}
+	if form.PollID != 0 {
+		var poll data.Poll
+		if err := data.DB.First(&poll, form.PollID).Error; err != nil || !data.CanAccessPoll(&poll, adminUser, data.AccessEdit) {
+			c.HTML(http.StatusForbidden, "noadmin.html", gin.H{"CurrentUser": adminUser.Email, "NoPollAccess": true})
+			return
+		}
+	}
+
+	hook, err := data.CreateWebhook(adminUser.ID, form.PollID, form.URL, form.Events)
+	if err != nil {
+		log.Printf("Error creating webhook for %s: %v", currentUser, err)
+		c.AbortWithError(500, errors.New("Failed to create webhook"))
+		return
+	}
+	renderWebhooks(c, http.StatusOK, currentUser, adminUser, webhookForm{}, hook, "")
+}
+
+func AdminWebhooksDeletePOST(c *gin.Context) {
+	currentUser, hook, ok := adminOwnedWebhook(c)
+	if !ok {
+		return
+	}
+	if err := data.DeleteWebhook(hook.ID); err != nil {
+		log.Printf("Error deleting webhook %d for %s: %v", hook.ID, currentUser, err)
+		c.AbortWithError(500, errors.New("Failed to delete webhook"))
+		return
+	}
+	c.Redirect(302, "/admin/webhooks")
+}
+
+// AdminWebhooksTestPOST sends a webhook.test event and shows the delivery log with the outcome.
+func AdminWebhooksTestPOST(c *gin.Context) {
+	currentUser, hook, ok := adminOwnedWebhook(c)
+	if !ok {
+		return
+	}
+	if _, err := webhooks.SendTest(hook); err != nil {
+		log.Printf("Error sending test event to webhook %d for %s: %v", hook.ID, currentUser, err)
+	}
+	c.Redirect(302, "/admin/webhooks/"+strconv.Itoa(int(hook.ID)))
+}
+
+// AdminWebhookDeliveries shows the latest delivery attempts of a webhook.
+func AdminWebhookDeliveries(c *gin.Context) {
+	currentUser, hook, ok := adminOwnedWebhook(c)
+	if !ok {
+		return
+	}
+	deliveries, err := data.GetWebhookDeliveries(hook.ID, maxWebhookDeliveries)
+	if err != nil {
+		log.Printf("Error retrieving deliveries of webhook %d: %v", hook.ID, err)
+		c.AbortWithError(500, errors.New("Failed to retrieve deliveries"))
+		return
+	}
+	c.HTML(http.StatusOK, "adminwebhookdeliveries.html", gin.H{
+		"CSRFToken":   csrfToken(c),
+		"title":       "Webhook deliveries",
+		"CurrentUser": currentUser,
+		"Webhook":     hook,
+		"Deliveries":  deliveries,
+	})
+}
+
+// adminOwnedWebhook loads the webhook in the :webhookID parameter for the logged in admin.
+// Returns false after writing the response if there is no such webhook of theirs.
+func adminOwnedWebhook(c *gin.Context) (string, *data.Webhook, bool) {
+	adminUser := currentAdmin(c)
+
+	webhookID, err := strconv.Atoi(c.Param("webhookID"))
+	if err != nil {
+		c.AbortWithStatus(http.StatusBadRequest)
+		return "", nil, false
+	}
+	hook, err := data.GetWebhook(uint(webhookID))
+	if err != nil || hook.AdminUserID != adminUser.ID {
+		c.HTML(http.StatusNotFound, "notfound.html", gin.H{
+			"CurrentUser": adminUser.Email,
+			"Message":     "The webhook does not exist or has been deleted.",
+		})
+		return "", nil, false
+	}
+	return adminUser.Email, hook, true
+}
+
+// webhookEventChoice is a checkbox of the new webhook form.
+type webhookEventChoice struct {
+	Event   string
+	Checked bool
+}
+
+func renderWebhooks(c *gin.Context, status int, currentUser string, adminUser *data.AdminUser, form webhookForm, created *data.Webhook, message string) {
+	hooks, err := data.GetWebhooksForAdmin(adminUser.ID)
+	if err != nil {
+		log.Printf("Error retrieving webhooks of admin %d: %v", adminUser.ID, err)
+	}
+	polls, err := data.GetAccessiblePolls(adminUser, data.AccessEdit)
+	if err != nil {
+		log.Printf("Error retrieving polls of admin %d: %v", adminUser.ID, err)
+	}
+	choices := []webhookEventChoice{}
+	for _, event := range webhooks.Events {
+		checked := len(form.Events) == 0 || slices.Contains(form.Events, event)
+		choices = append(choices, webhookEventChoice{Event: event, Checked: checked})
+	}
+	c.HTML(status, "adminwebhooks.html", gin.H{
+		"CSRFToken":   csrfToken(c),
+		"title":       "Webhooks",
+		"CurrentUser": currentUser,
+		"Webhooks":    hooks,
+		"Polls":       polls,
+		"Events":      choices,
+		"Form":        form,
+		"Created":     created,
+		"Message":     message,
+	})
+}
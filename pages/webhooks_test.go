@@ -0,0 +1,105 @@
+package pages
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/aspcodenet/systementorlivepolls/data"
+	"github.com/aspcodenet/systementorlivepolls/webhooks"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestWebhooksRouter(email string) *gin.Engine {
+	router := newTestRouter(email)
+	router.LoadHTMLGlob("../templates/**")
+	admin := router.Group("/admin", AdminRequired)
+	admin.GET("/webhooks", AdminWebhooks)
+	admin.POST("/webhooks", AdminWebhooksCreatePOST)
+	admin.GET("/webhooks/:webhookID", AdminWebhookDeliveries)
+	admin.POST("/webhooks/test/:webhookID", AdminWebhooksTestPOST)
+	admin.POST("/webhooks/delete/:webhookID", AdminWebhooksDeletePOST)
+	return router
+}
+
+func TestAdminWebhooksCreate(t *testing.T) {
+	setupTestDB(t)
+	owner := createTestAdmin(t, "owner@example.com")
+	poll := createTestPoll(t, owner, "Quiz")
+	router := newTestWebhooksRouter(owner.Email)
+
+	w := doFormRequest(router, "/admin/webhooks", url.Values{"url": {"ftp://example.com"}})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = doFormRequest(router, "/admin/webhooks", url.Values{"url": {"http://169.254.169.254/latest/meta-data/"}})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "cannot be sent to private")
+
+	form := url.Values{
+		"url":    {"https://example.com/hook"},
+		"pollID": {idStr(poll.ID)},
+		"events": {webhooks.EventPollStarted, webhooks.EventPollFinished},
+	}
+	w = doFormRequest(router, "/admin/webhooks", form)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	hooks, err := data.GetWebhooksForAdmin(owner.ID)
+	assert.NoError(t, err)
+	if assert.Len(t, hooks, 1) {
+		assert.Equal(t, poll.ID, hooks[0].PollID)
+		assert.Equal(t, []string{webhooks.EventPollStarted, webhooks.EventPollFinished}, hooks[0].EventList())
+		assert.Contains(t, w.Body.String(), hooks[0].Secret, "the secret is shown right after creating")
+	}
+
+	w = doRequest(router, http.MethodGet, "/admin/webhooks", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "https://example.com/hook")
+	assert.NotContains(t, w.Body.String(), hooks[0].Secret)
+}
+
+func TestAdminWebhooksOtherAdminsPoll(t *testing.T) {
+	setupTestDB(t)
+	owner := createTestAdmin(t, "owner@example.com")
+	other := createTestAdmin(t, "other@example.com")
+	poll := createTestPoll(t, owner, "Quiz")
+	hook, err := data.CreateWebhook(owner.ID, 0, "https://example.com/hook", nil)
+	assert.NoError(t, err)
+	router := newTestWebhooksRouter(other.Email)
+
+	w := doFormRequest(router, "/admin/webhooks", url.Values{"url": {"https://example.com/x"}, "pollID": {idStr(poll.ID)}})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "You do not have access to this poll")
+	w = doFormRequest(router, "/admin/webhooks/delete/"+idStr(hook.ID), url.Values{})
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), "The webhook does not exist")
+
+	hooks, _ := data.GetWebhooksForAdmin(owner.ID)
+	assert.Len(t, hooks, 1)
+	hooks, _ = data.GetWebhooksForAdmin(other.ID)
+	assert.Empty(t, hooks)
+}
+
+func TestAdminWebhooksSendTestEvent(t *testing.T) {
+	setupTestDB(t)
+	owner := createTestAdmin(t, "owner@example.com")
+	var event string
+	webhooks.AllowPrivateAddresses = true // The receiver is on 127.0.0.1
+	t.Cleanup(func() { webhooks.AllowPrivateAddresses = false })
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		event = r.Header.Get(webhooks.HeaderEvent)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+	hook, err := data.CreateWebhook(owner.ID, 0, receiver.URL, nil)
+	assert.NoError(t, err)
+	router := newTestWebhooksRouter(owner.Email)
+
+	w := doFormRequest(router, "/admin/webhooks/test/"+idStr(hook.ID), url.Values{})
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, webhooks.EventTest, event)
+
+	w = doRequest(router, http.MethodGet, "/admin/webhooks/"+idStr(hook.ID), "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "<mark>204</mark>")
+	assert.Contains(t, w.Body.String(), webhooks.EventTest)
+}
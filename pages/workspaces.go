@@ -0,0 +1,138 @@
+package pages
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/aspcodenet/systementorlivepolls/data"
+	"github.com/gin-gonic/gin"
+)
+
+// AdminWorkspaces lists the workspaces of the current user and lets them create one.
+func AdminWorkspaces(c *gin.Context) {
+	adminUser := currentAdmin(c)
+	currentUser := adminUser.Email
+
+	renderWorkspaces(c, http.StatusOK, currentUser, adminUser, "")
+}
+
+// AdminWorkspacesCreatePOST creates a workspace with the current user as its owner.
+func AdminWorkspacesCreatePOST(c *gin.Context) {
+	adminUser := currentAdmin(c)
+	currentUser := adminUser.Email
+
+	name := strings.TrimSpace(c.PostForm("name"))
+	if name == "" || len(name) > 100 {
+		renderWorkspaces(c, http.StatusBadRequest, currentUser, adminUser, "Enter a name of at most 100 characters.")
+		return
+	}
+	workspace, err := data.CreateWorkspace(name, adminUser)
+	if err != nil {
+		log.Printf("Error creating workspace for %s: %v", currentUser, err)
+		renderWorkspaces(c, http.StatusInternalServerError, currentUser, adminUser, "Failed to create the workspace.")
+		return
+	}
+	c.Redirect(302, "/admin/workspaces/"+strconv.Itoa(int(workspace.ID)))
+}
+
+func renderWorkspaces(c *gin.Context, status int, currentUser string, adminUser *data.AdminUser, message string) {
+	workspaces, err := data.GetWorkspacesForAdmin(adminUser.ID)
+	if err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	c.HTML(status, "adminworkspaces.html", gin.H{
+		"CSRFToken":   csrfToken(c),
+		"title":       "Workspaces",
+		"CurrentUser": currentUser,
+		"Workspaces":  workspaces,
+		"Message":     message,
+	})
+}
+
+// AdminWorkspace shows the members and polls of a workspace. Owners of the workspace may change
+// the members here.
+func AdminWorkspace(c *gin.Context) {
+	currentUser, adminUser, workspace, ok := adminMemberWorkspace(c)
+	if !ok {
+		return
+	}
+	renderWorkspace(c, http.StatusOK, currentUser, adminUser, workspace, "")
+}
+
+// AdminWorkspaceMembersPOST adds a member to a workspace, changes their access or, with an empty
+// access, removes them.
+func AdminWorkspaceMembersPOST(c *gin.Context) {
+	currentUser, adminUser, workspace, ok := adminMemberWorkspace(c)
+	if !ok {
+		return
+	}
+	if data.WorkspaceAccess(workspace.ID, adminUser.ID) != data.AccessOwner {
+		c.HTML(http.StatusForbidden, "noadmin.html", gin.H{"CurrentUser": currentUser})
+		return
+	}
+
+	access := c.PostForm("access")
+	if access != "" && !data.ValidAccess(access) {
+		renderWorkspace(c, http.StatusBadRequest, currentUser, adminUser, workspace, "Unknown access.")
+		return
+	}
+	member, err := data.GetAdminUserByEmail(c.PostForm("email"))
+	if errors.Is(err, data.ErrNoSuchAdmin) {
+		renderWorkspace(c, http.StatusBadRequest, currentUser, adminUser, workspace, "Nobody with that email address has logged in yet.")
+		return
+	} else if err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	err = data.SetWorkspaceMember(workspace.ID, member.ID, access)
+	if errors.Is(err, data.ErrLastWorkspaceOwner) {
+		renderWorkspace(c, http.StatusConflict, currentUser, adminUser, workspace, member.Email+" is the last owner of the workspace. Make someone else owner first.")
+		return
+	} else if err != nil {
+		log.Printf("Error changing member %d of workspace %d: %v", member.ID, workspace.ID, err)
+		renderWorkspace(c, http.StatusInternalServerError, currentUser, adminUser, workspace, "Failed to save the change.")
+		return
+	}
+	c.Redirect(302, "/admin/workspaces/"+strconv.Itoa(int(workspace.ID)))
+}
+
+// adminMemberWorkspace loads the workspace in the :workspaceID parameter, making sure the current
+// user is a member. Writes the response and returns false otherwise.
+func adminMemberWorkspace(c *gin.Context) (string, *data.AdminUser, *data.Workspace, bool) {
+	adminUser := currentAdmin(c)
+
+	workspaceID, err := strconv.Atoi(c.Param("workspaceID"))
+	if err != nil {
+		c.AbortWithStatus(http.StatusBadRequest)
+		return "", nil, nil, false
+	}
+	workspace, err := data.GetWorkspace(uint(workspaceID))
+	if err != nil || data.WorkspaceAccess(workspace.ID, adminUser.ID) == "" {
+		c.HTML(http.StatusOK, "noadmin.html", gin.H{})
+		return "", nil, nil, false
+	}
+	return adminUser.Email, adminUser, workspace, true
+}
+
+func renderWorkspace(c *gin.Context, status int, currentUser string, adminUser *data.AdminUser, workspace *data.Workspace, message string) {
+	polls := []*data.Poll{}
+	if err := data.DB.Where("workspace_id = ?", workspace.ID).Order("id").Find(&polls).Error; err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	c.HTML(status, "adminworkspace.html", gin.H{
+		"CSRFToken":   csrfToken(c),
+		"title":       workspace.Name,
+		"CurrentUser": currentUser,
+		"Workspace":   workspace,
+		"Polls":       polls,
+		"IsOwner":     data.WorkspaceAccess(workspace.ID, adminUser.ID) == data.AccessOwner,
+		"Access":      data.AccessLevels,
+		"Message":     message,
+	})
+}
@@ -0,0 +1,77 @@
+package pages
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/aspcodenet/systementorlivepolls/data"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestWorkspacesRouter(email string) *gin.Engine {
+	router := newTestRouter(email)
+	router.LoadHTMLGlob("../templates/**")
+	admin := router.Group("/admin", AdminRequired)
+	admin.GET("/workspaces", AdminWorkspaces)
+	admin.POST("/workspaces", AdminWorkspacesCreatePOST)
+	admin.GET("/workspaces/:workspaceID", AdminWorkspace)
+	admin.POST("/workspaces/:workspaceID/members", AdminWorkspaceMembersPOST)
+	return router
+}
+
+func TestCreateWorkspace(t *testing.T) {
+	setupTestDB(t)
+	alice := createTestAdmin(t, "alice@example.com")
+	router := newTestWorkspacesRouter(alice.Email)
+
+	w := doFormRequest(router, "/admin/workspaces", url.Values{"name": {" "}})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = doFormRequest(router, "/admin/workspaces", url.Values{"name": {"Java teachers"}})
+	require.Equal(t, http.StatusFound, w.Code)
+	workspaces, err := data.GetWorkspacesForAdmin(alice.ID)
+	require.NoError(t, err)
+	require.Len(t, workspaces, 1)
+	assert.Equal(t, data.AccessOwner, workspaces[0].Access)
+	assert.Equal(t, "/admin/workspaces/"+idStr(workspaces[0].ID), w.Header().Get("Location"))
+
+	assert.Contains(t, doRequest(router, "GET", "/admin/workspaces", "").Body.String(), "Java teachers")
+}
+
+func TestWorkspaceMembers(t *testing.T) {
+	setupTestDB(t)
+	alice := createTestAdmin(t, "alice@example.com")
+	bob := createTestAdmin(t, "bob@example.com")
+	carol := createTestAdmin(t, "carol@example.com")
+	workspace, err := data.CreateWorkspace("Teachers", alice)
+	require.NoError(t, err)
+	path := "/admin/workspaces/" + idStr(workspace.ID)
+	asAlice := newTestWorkspacesRouter(alice.Email)
+
+	// Not a member yet
+	assert.Contains(t, doRequest(newTestWorkspacesRouter(bob.Email), "GET", path, "").Body.String(), "not registered as admin")
+
+	w := doFormRequest(asAlice, path+"/members", url.Values{"email": {bob.Email}, "access": {"edit"}})
+	assert.Equal(t, http.StatusFound, w.Code)
+	body := doRequest(newTestWorkspacesRouter(bob.Email), "GET", path, "").Body.String()
+	assert.Contains(t, body, "alice@example.com")
+	assert.NotContains(t, body, "Add or change member")
+
+	// Only owners change the members
+	w = doFormRequest(newTestWorkspacesRouter(bob.Email), path+"/members", url.Values{"email": {carol.Email}, "access": {"run"}})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Empty(t, data.WorkspaceAccess(workspace.ID, carol.ID))
+
+	w = doFormRequest(asAlice, path+"/members", url.Values{"email": {"nobody@example.com"}, "access": {"run"}})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = doFormRequest(asAlice, path+"/members", url.Values{"email": {alice.Email}})
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), "last owner")
+
+	w = doFormRequest(asAlice, path+"/members", url.Values{"email": {bob.Email}})
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Empty(t, data.WorkspaceAccess(workspace.ID, bob.ID))
+}
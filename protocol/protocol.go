@@ -0,0 +1,251 @@
+// Package protocol defines the messages exchanged over the poll WebSocket (/ws/:inviteID).
+//
+// Every frame is a JSON object with a "type" field; each type has its own struct below.
+// The protocol version is negotiated with the Sec-WebSocket-Protocol header: clients offer
+// "livepolls.v2" and the server answers with a WelcomeMessage carrying the version in use.
+// Client messages may carry a correlationId that the server copies into the reply it sends back:
+// vote_accepted or vote_rejected for submit_vote, ack or error for everything else.
+package protocol
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Version is the newest protocol version the server speaks.
+const Version = 2
+
+// SupportedVersions lists the protocol versions the server accepts, newest first.
+var SupportedVersions = []int{2}
+
+const subprotocolPrefix = "livepolls.v"
+
+// Subprotocol returns the Sec-WebSocket-Protocol value for a protocol version, e.g. "livepolls.v2".
+func Subprotocol(version int) string {
+	return subprotocolPrefix + strconv.Itoa(version)
+}
+
+// Subprotocols returns the Sec-WebSocket-Protocol values for all supported versions.
+func Subprotocols() []string {
+	result := make([]string, 0, len(SupportedVersions))
+	for _, v := range SupportedVersions {
+		result = append(result, Subprotocol(v))
+	}
+	return result
+}
+
+// NegotiateVersion picks the protocol version from the subprotocol selected during the upgrade.
+// Clients that offer no subprotocol get the newest version. Returns false if the client
+// offered subprotocols but none of them is supported.
+func NegotiateVersion(selected string, offered []string) (int, bool) {
+	if selected == "" {
+		return Version, len(offered) == 0
+	}
+	v, err := strconv.Atoi(strings.TrimPrefix(selected, subprotocolPrefix))
+	if err != nil {
+		return 0, false
+	}
+	for _, supported := range SupportedVersions {
+		if v == supported {
+			return v, true
+		}
+	}
+	return 0, false
+}
+
+// Message types sent by clients.
+const (
+	TypeSubmitVote  = "submit_vote"
+	TypeAdminAction = "admin_action"
+)
+
+// Message types sent by the server.
+const (
+	TypeWelcome       = "welcome"
+	TypePollState     = "poll_state"
+	TypeResultsUpdate = "results_update"
+	TypeVoteAccepted  = "vote_accepted"
+	TypeVoteRejected  = "vote_rejected"
+	TypeAck           = "ack"
+	TypeError         = "error"
+)
+
+// Admin actions.
+const (
+	ActionStart       = "start"
+	ActionNext        = "next"
+	ActionShowResults = "show_results"
+	ActionDone        = "done"
+)
+
+// Connection roles, selected with the role query parameter. Participants send no role.
+// Presenters also send the presenter token of the poll in the token query parameter.
+const (
+	RoleAdmin     = "admin"     // The control panel
+	RolePresenter = "presenter" // The read-only projector view
+)
+
+// ErrorCode identifies an error in an ErrorMessage. Codes are stable, the message text is not.
+type ErrorCode string
+
+const (
+	ErrPollNotFound       ErrorCode = "poll_not_found"
+	ErrInternal           ErrorCode = "internal_error"
+	ErrInvalidMessage     ErrorCode = "invalid_message"
+	ErrUnknownMessageType ErrorCode = "unknown_message_type"
+	ErrUnsupportedVersion ErrorCode = "unsupported_protocol_version"
+	ErrVotingClosed       ErrorCode = "voting_closed"
+	ErrNoActiveQuestion   ErrorCode = "no_active_question"
+	ErrWrongQuestion      ErrorCode = "wrong_question"
+	ErrInvalidOption      ErrorCode = "invalid_option"
+	ErrTooManyOptions     ErrorCode = "too_many_options"
+	ErrVoteFailed         ErrorCode = "vote_failed"
+	ErrUnknownAction      ErrorCode = "unknown_action"
+	ErrActionNotAllowed   ErrorCode = "action_not_allowed"
+	ErrActionFailed       ErrorCode = "action_failed"
+	ErrInvalidToken       ErrorCode = "invalid_token"
+	ErrReadOnly           ErrorCode = "read_only"
+)
+
+// SubmitVoteMessage is sent by a participant to vote on the current question.
+// A client that retries a submission sends the same IdempotencyKey, so the vote is only counted once.
+type SubmitVoteMessage struct {
+	Type           string `json:"type"`
+	CorrelationID  string `json:"correlationId,omitempty"`
+	IdempotencyKey string `json:"idempotencyKey,omitempty"`
+	QuestionID     uint   `json:"questionId"`
+	OptionIDs      []uint `json:"optionIds"`
+	VoterID        string `json:"voterId,omitempty"` // Generated by the server when empty
+}
+
+// AdminActionMessage is sent by the admin to move the poll to another state.
+type AdminActionMessage struct {
+	Type          string `json:"type"`
+	CorrelationID string `json:"correlationId,omitempty"`
+	Action        string `json:"action"` // One of the Action constants
+}
+
+// WelcomeMessage is the first message on every connection.
+type WelcomeMessage struct {
+	Type            string `json:"type"`
+	ProtocolVersion int    `json:"protocolVersion"`
+	PollID          uint   `json:"pollId"`
+	InviteID        string `json:"inviteId"`
+}
+
+// Option is an answer option of a question.
+type Option struct {
+	ID   uint   `json:"id"`
+	Text string `json:"text"`
+}
+
+// Question is a poll question with its options. Votes maps option ID to vote count and is
+// only set when results are included and, for participants, when the question's result
+// visibility allows them to see the votes.
+type Question struct {
+	ID      uint           `json:"id"`
+	Text    string         `json:"text"`
+	Type    string         `json:"type"` // "single-select" or "multi-select"
+	Options []Option       `json:"options"`
+	Votes   map[string]int `json:"votes,omitempty"`
+}
+
+// PollStateMessage is sent on connect and after every state change.
+type PollStateMessage struct {
+	Type            string     `json:"type"`
+	PollID          uint       `json:"pollId"`
+	InviteID        string     `json:"inviteId"`
+	JoinPIN         string     `json:"joinPin,omitempty"`         // Short join code, set while the poll is live
+	Status          string     `json:"status"`                    // "setup", "active", "results" or "finished"
+	CurrentQuestion *Question  `json:"currentQuestion,omitempty"` // Set when status is "active" or "results"
+	AllQuestions    []Question `json:"allQuestions,omitempty"`    // Set with votes when status is "results" or "finished"
+}
+
+// ResultsUpdateMessage carries the live vote counts of the current question to admins.
+type ResultsUpdateMessage struct {
+	Type       string         `json:"type"`
+	PollID     uint           `json:"pollId"`
+	QuestionID uint           `json:"questionId"`
+	Votes      map[string]int `json:"votes"`
+	TotalVotes int            `json:"totalVotes"`
+}
+
+// VoteAcceptedMessage answers a submit_vote that was stored, or that was a retry of one that was.
+// OptionIDs is the voter's current selection for the question.
+type VoteAcceptedMessage struct {
+	Type           string `json:"type"`
+	CorrelationID  string `json:"correlationId,omitempty"`
+	IdempotencyKey string `json:"idempotencyKey,omitempty"`
+	QuestionID     uint   `json:"questionId"`
+	VoterID        string `json:"voterId"` // Send this with later votes to keep the same identity
+	OptionIDs      []uint `json:"optionIds"`
+	Duplicate      bool   `json:"duplicate"` // The idempotency key was seen before and nothing new was stored
+}
+
+// VoteRejectedMessage answers a submit_vote that was not stored.
+type VoteRejectedMessage struct {
+	Type           string    `json:"type"`
+	CorrelationID  string    `json:"correlationId,omitempty"`
+	IdempotencyKey string    `json:"idempotencyKey,omitempty"`
+	QuestionID     uint      `json:"questionId"`
+	Code           ErrorCode `json:"code"`
+	Message        string    `json:"message"`
+}
+
+// AckMessage confirms that a client message was handled.
+type AckMessage struct {
+	Type          string `json:"type"`
+	CorrelationID string `json:"correlationId,omitempty"`
+}
+
+// ErrorMessage reports that a client message could not be handled.
+type ErrorMessage struct {
+	Type          string    `json:"type"`
+	CorrelationID string    `json:"correlationId,omitempty"`
+	Code          ErrorCode `json:"code"`
+	Message       string    `json:"message"`
+}
+
+// NewError builds an ErrorMessage.
+func NewError(correlationID string, code ErrorCode, message string) ErrorMessage {
+	return ErrorMessage{Type: TypeError, CorrelationID: correlationID, Code: code, Message: message}
+}
+
+// NewAck builds an AckMessage.
+func NewAck(correlationID string) AckMessage {
+	return AckMessage{Type: TypeAck, CorrelationID: correlationID}
+}
+
+// envelope holds the fields common to all client messages.
+type envelope struct {
+	Type          string `json:"type"`
+	CorrelationID string `json:"correlationId"`
+}
+
+// DecodeClientMessage parses a frame sent by a client into *SubmitVoteMessage or *AdminActionMessage.
+// The returned correlation ID is set whenever the frame had one, even if decoding failed.
+func DecodeClientMessage(raw []byte) (interface{}, string, *ErrorMessage) {
+	var env envelope
+	if err := json.Unmarshal(raw, &env); err != nil {
+		e := NewError("", ErrInvalidMessage, "Message is not valid JSON.")
+		return nil, "", &e
+	}
+
+	var msg interface{}
+	switch env.Type {
+	case TypeSubmitVote:
+		msg = &SubmitVoteMessage{}
+	case TypeAdminAction:
+		msg = &AdminActionMessage{}
+	default:
+		e := NewError(env.CorrelationID, ErrUnknownMessageType, fmt.Sprintf("Unknown message type %q.", env.Type))
+		return nil, env.CorrelationID, &e
+	}
+	if err := json.Unmarshal(raw, msg); err != nil {
+		e := NewError(env.CorrelationID, ErrInvalidMessage, fmt.Sprintf("Invalid %s message: %v", env.Type, err))
+		return nil, env.CorrelationID, &e
+	}
+	return msg, env.CorrelationID, nil
+}
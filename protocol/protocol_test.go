@@ -0,0 +1,52 @@
+package protocol
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNegotiateVersion(t *testing.T) {
+	v, ok := NegotiateVersion("", nil)
+	assert.True(t, ok, "clients without a subprotocol get the newest version")
+	assert.Equal(t, Version, v)
+
+	v, ok = NegotiateVersion("livepolls.v2", []string{"livepolls.v2"})
+	assert.True(t, ok)
+	assert.Equal(t, 2, v)
+
+	_, ok = NegotiateVersion("", []string{"livepolls.v1"})
+	assert.False(t, ok, "only unsupported versions offered")
+}
+
+func TestDecodeClientMessage(t *testing.T) {
+	msg, corr, errMsg := DecodeClientMessage([]byte(`{"type":"submit_vote","correlationId":"c1","questionId":3,"optionIds":[7,8]}`))
+	assert.Nil(t, errMsg)
+	assert.Equal(t, "c1", corr)
+	vote, ok := msg.(*SubmitVoteMessage)
+	if assert.True(t, ok) {
+		assert.Equal(t, uint(3), vote.QuestionID)
+		assert.Equal(t, []uint{7, 8}, vote.OptionIDs)
+	}
+
+	msg, _, errMsg = DecodeClientMessage([]byte(`{"type":"admin_action","action":"next"}`))
+	assert.Nil(t, errMsg)
+	assert.Equal(t, ActionNext, msg.(*AdminActionMessage).Action)
+
+	_, corr, errMsg = DecodeClientMessage([]byte(`{"type":"dance","correlationId":"c2"}`))
+	if assert.NotNil(t, errMsg) {
+		assert.Equal(t, ErrUnknownMessageType, errMsg.Code)
+		assert.Equal(t, "c2", corr)
+		assert.Equal(t, "c2", errMsg.CorrelationID)
+	}
+
+	_, _, errMsg = DecodeClientMessage([]byte(`{"type":"submit_vote","questionId":"three"}`))
+	if assert.NotNil(t, errMsg) {
+		assert.Equal(t, ErrInvalidMessage, errMsg.Code)
+	}
+
+	_, _, errMsg = DecodeClientMessage([]byte(`not json`))
+	if assert.NotNil(t, errMsg) {
+		assert.Equal(t, ErrInvalidMessage, errMsg.Code)
+	}
+}
@@ -0,0 +1,94 @@
+package quizformat
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/aspcodenet/systementorlivepolls/data"
+)
+
+var (
+	aikenOption = regexp.MustCompile(`^([A-Za-z])[.)]\s+(.*)$`)
+	aikenAnswer = regexp.MustCompile(`^(?i)ANSWER:\s*(.*)$`)
+)
+
+// parseAiken reads the Aiken format: the question on one line, options as "A. text" or
+// "A) text", and "ANSWER: B" ending the question. Questions are separated by blank lines.
+// Several letters separated by commas in the ANSWER line make a multi-select question.
+//
+//	What is 2 + 2?
+//	A. 3
+//	B. 4
+//	ANSWER: B
+func parseAiken(lines []string) *Result {
+	result := &Result{}
+
+	var question string
+	questionLine := 0
+	var options []data.Option
+	var letters []string
+	reset := func() {
+		question, questionLine, options, letters = "", 0, nil, nil
+	}
+	// unfinished reports a question that ended without an ANSWER line.
+	unfinished := func() {
+		if questionLine != 0 {
+			result.addError(questionLine, "question %q has no ANSWER line", question)
+		}
+		reset()
+	}
+
+	for i, raw := range lines {
+		lineNo := i + 1
+		line := strings.TrimSpace(raw)
+
+		if line == "" {
+			unfinished()
+			continue
+		}
+		if m := aikenAnswer.FindStringSubmatch(line); m != nil {
+			if questionLine == 0 {
+				result.addError(lineNo, "ANSWER line without a question")
+				continue
+			}
+			valid := true
+			for _, letter := range strings.Split(m[1], ",") {
+				letter = strings.ToUpper(strings.TrimSpace(letter))
+				found := false
+				for j := range letters {
+					if letters[j] == letter {
+						options[j].Correct = true
+						found = true
+					}
+				}
+				if !found {
+					result.addError(lineNo, "ANSWER %q is not one of the options", letter)
+					valid = false
+				}
+			}
+			if valid {
+				result.addQuestion(questionLine, question, options)
+			}
+			reset()
+			continue
+		}
+		if questionLine == 0 {
+			question, questionLine = line, lineNo
+			continue
+		}
+		m := aikenOption.FindStringSubmatch(line)
+		if m == nil {
+			result.addError(lineNo, "expected an option like \"A. text\" or an ANSWER line")
+			continue
+		}
+		text := strings.TrimSpace(m[2])
+		if text == "" {
+			result.addError(lineNo, "option %s has no text", strings.ToUpper(m[1]))
+			continue
+		}
+		options = append(options, data.Option{Text: text})
+		letters = append(letters, strings.ToUpper(m[1]))
+	}
+	unfinished()
+	return result
+}
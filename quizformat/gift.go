@@ -0,0 +1,237 @@
+package quizformat
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/aspcodenet/systementorlivepolls/data"
+)
+
+var (
+	giftTextFormat = regexp.MustCompile(`^\[(html|moodle|plain|markdown)\]`)
+	giftWeight     = regexp.MustCompile(`^%(-?\d+(?:\.\d+)?)%`)
+)
+
+// giftBlock is one question of a GIFT file, with the line it starts on.
+type giftBlock struct {
+	line int
+	text string
+}
+
+// parseGIFT reads the multiple choice and true/false questions of Moodle's GIFT format.
+// Answers go in braces, "=" marks a right and "~" a wrong answer, "~%50%" gives partial credit
+// (counted as right when positive) and "#" starts feedback, which is dropped.
+//
+//	::Q1:: Which are prime numbers? {
+//	  ~%50%2
+//	  ~%50%3
+//	  ~%-100%4
+//	}
+//	Grass is green. {T}
+func parseGIFT(lines []string) *Result {
+	result := &Result{}
+	blocks, errs := splitGIFT(lines)
+	result.Errors = append(result.Errors, errs...)
+	for _, b := range blocks {
+		parseGIFTQuestion(result, b)
+	}
+	return result
+}
+
+// splitGIFT groups lines into questions, which are separated by blank lines outside braces.
+// Comments and $CATEGORY lines are skipped.
+func splitGIFT(lines []string) ([]giftBlock, []ParseError) {
+	var blocks []giftBlock
+	var errs []ParseError
+	var current []string
+	start := 0
+	depth := 0
+	flush := func() {
+		if len(current) > 0 {
+			blocks = append(blocks, giftBlock{line: start, text: strings.Join(current, "\n")})
+		}
+		current, start = nil, 0
+	}
+
+	for i, raw := range lines {
+		lineNo := i + 1
+		line := strings.TrimSpace(raw)
+		if depth == 0 && (strings.HasPrefix(line, "//") || strings.HasPrefix(line, "$CATEGORY:")) {
+			continue
+		}
+		if line == "" {
+			if depth == 0 {
+				flush()
+			}
+			continue
+		}
+		if start == 0 {
+			start = lineNo
+		}
+		current = append(current, line)
+		for j := 0; j < len(line); j++ {
+			switch line[j] {
+			case '\\':
+				j++ // Skip the escaped character
+			case '{':
+				depth++
+			case '}':
+				depth--
+			}
+		}
+		if depth < 0 {
+			errs = append(errs, ParseError{Line: lineNo, Message: "unexpected }"})
+			depth = 0
+		}
+	}
+	if depth > 0 {
+		errs = append(errs, ParseError{Line: start, Message: "answer block is not closed with }"})
+		current = nil
+	}
+	flush()
+	return blocks, errs
+}
+
+// indexUnescaped returns the index of the first c in s that is not escaped with a backslash, or -1.
+func indexUnescaped(s string, c byte) int {
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' {
+			i++
+			continue
+		}
+		if s[i] == c {
+			return i
+		}
+	}
+	return -1
+}
+
+// unescapeGIFT removes the backslashes from \~ \= \# \{ \} \: and \\ and turns \n into a space.
+func unescapeGIFT(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+			if s[i] == 'n' {
+				b.WriteByte(' ')
+			} else {
+				b.WriteByte(s[i])
+			}
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return strings.TrimSpace(b.String())
+}
+
+func parseGIFTQuestion(result *Result, b giftBlock) {
+	text := b.text
+
+	// Optional title: ::Title::
+	if strings.HasPrefix(text, "::") {
+		if end := strings.Index(text[2:], "::"); end >= 0 {
+			text = text[end+4:]
+		}
+	}
+
+	open := indexUnescaped(text, '{')
+	if open < 0 {
+		result.addError(b.line, "question has no answers in { }")
+		return
+	}
+	closing := open + indexUnescaped(text[open:], '}')
+	if closing < open {
+		result.addError(b.line, "answer block is not closed with }")
+		return
+	}
+
+	question := strings.TrimSpace(text[:open])
+	if after := strings.TrimSpace(text[closing+1:]); after != "" {
+		question += " _____ " + after // Fill in the blank: the answers replace the blank
+	}
+	question = giftTextFormat.ReplaceAllString(question, "")
+	question = unescapeGIFT(strings.ReplaceAll(question, "\n", " "))
+
+	answers := strings.TrimSpace(text[open+1 : closing])
+	options, ok := parseGIFTAnswers(result, b.line, answers)
+	if !ok {
+		return
+	}
+	result.addQuestion(b.line, question, options)
+}
+
+// parseGIFTAnswers reads the content of the braces. Returns false after reporting an error for
+// question types a poll cannot ask.
+func parseGIFTAnswers(result *Result, line int, answers string) ([]data.Option, bool) {
+	if strings.HasPrefix(answers, "#") {
+		result.addError(line, "numerical questions are not supported")
+		return nil, false
+	}
+	// True/false answers may be followed by feedback after #
+	switch strings.ToUpper(strings.TrimSpace(strings.SplitN(answers, "#", 2)[0])) {
+	case "T", "TRUE":
+		return []data.Option{{Text: "True", Correct: true}, {Text: "False"}}, true
+	case "F", "FALSE":
+		return []data.Option{{Text: "True"}, {Text: "False", Correct: true}}, true
+	case "":
+		result.addError(line, "essay questions are not supported")
+		return nil, false
+	}
+
+	// Split at unescaped = and ~
+	type answer struct {
+		marker byte
+		text   string
+	}
+	var parts []answer
+	startPart := -1
+	for i := 0; i <= len(answers); i++ {
+		if i+1 < len(answers) && answers[i] == '\\' {
+			i++
+			continue
+		}
+		if i == len(answers) || answers[i] == '=' || answers[i] == '~' {
+			if startPart >= 0 {
+				parts = append(parts, answer{marker: answers[startPart], text: answers[startPart+1 : i]})
+			} else if strings.TrimSpace(answers[:i]) != "" {
+				result.addError(line, "answer %q must start with = or ~", strings.TrimSpace(answers[:i]))
+				return nil, false
+			}
+			startPart = i
+		}
+	}
+
+	var options []data.Option
+	wrong := 0
+	for _, p := range parts {
+		text := p.text
+		if i := indexUnescaped(text, '#'); i >= 0 {
+			text = text[:i] // Feedback
+		}
+		if strings.Contains(text, "->") {
+			result.addError(line, "matching questions are not supported")
+			return nil, false
+		}
+		correct := p.marker == '='
+		if m := giftWeight.FindStringSubmatch(text); m != nil {
+			weight, _ := strconv.ParseFloat(m[1], 64)
+			correct = weight > 0
+			text = text[len(m[0]):]
+		}
+		if p.marker == '~' {
+			wrong++
+		}
+		text = unescapeGIFT(text)
+		if text == "" {
+			result.addError(line, "answer has no text")
+			return nil, false
+		}
+		options = append(options, data.Option{Text: text, Correct: correct})
+	}
+	if wrong == 0 {
+		result.addError(line, "short answer questions are not supported, mark wrong answers with ~")
+		return nil, false
+	}
+	return options, true
+}
@@ -0,0 +1,89 @@
+package quizformat
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/aspcodenet/systementorlivepolls/data"
+)
+
+var (
+	markdownHeading  = regexp.MustCompile(`^#{1,6}\s+(.*)$`)
+	markdownNumbered = regexp.MustCompile(`^\d+[.)]\s+(.*)$`)
+	markdownBullet   = regexp.MustCompile(`^\s*[-*+]\s+(.*)$`)
+	markdownCheckbox = regexp.MustCompile(`^\[([ xX])\]\s*(.*)$`)
+)
+
+// parseMarkdown reads questions written as headings, numbered items or plain lines, each
+// followed by a bullet list of options. Task list checkboxes mark the correct options.
+// Headings without options of their own, like the title of the document, are skipped.
+//
+//	1. Which are prime numbers?
+//	   - [x] 2
+//	   - [x] 3
+//	   - [ ] 4
+func parseMarkdown(lines []string) *Result {
+	result := &Result{}
+
+	var question string
+	questionLine := 0
+	var options []data.Option
+	questionIsHeading := false
+	finish := func() {
+		if questionLine != 0 {
+			result.addQuestion(questionLine, question, options)
+		}
+		question, questionLine, options = "", 0, nil
+	}
+
+	for i, raw := range lines {
+		lineNo := i + 1
+		if strings.TrimSpace(raw) == "" {
+			continue
+		}
+
+		if m := markdownBullet.FindStringSubmatch(raw); m != nil {
+			if questionLine == 0 {
+				result.addError(lineNo, "option without a question before it")
+				continue
+			}
+			text := strings.TrimSpace(m[1])
+			correct := false
+			if c := markdownCheckbox.FindStringSubmatch(text); c != nil {
+				correct = c[1] != " "
+				text = strings.TrimSpace(c[2])
+			}
+			if text == "" {
+				result.addError(lineNo, "option has no text")
+				continue
+			}
+			options = append(options, data.Option{Text: text, Correct: correct})
+			continue
+		}
+
+		// Any other line is question text. Headings and numbered items always start a new
+		// question, plain lines only after options.
+		line := strings.TrimSpace(raw)
+		marked := false
+		if m := markdownHeading.FindStringSubmatch(line); m != nil {
+			line, marked = m[1], true
+		} else if m := markdownNumbered.FindStringSubmatch(line); m != nil {
+			line, marked = m[1], true
+		}
+		if marked && len(options) == 0 && questionIsHeading {
+			// A heading directly followed by a question is a section title, not a question
+			question, questionLine = "", 0
+		}
+		if marked || len(options) > 0 {
+			finish()
+		}
+		if questionLine == 0 {
+			question, questionLine = line, lineNo
+			questionIsHeading = markdownHeading.MatchString(strings.TrimSpace(raw))
+		} else {
+			question += " " + line
+		}
+	}
+	finish()
+	return result
+}
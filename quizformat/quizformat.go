@@ -0,0 +1,95 @@
+// Package quizformat parses question banks written in Markdown, Moodle GIFT or Aiken into
+// data.Question trees, so instructors can import existing quizzes into a poll.
+//
+// Only multiple choice and true/false questions can be imported, since those are the only
+// kinds a poll can ask. Options marked as the right answer get data.Option.Correct set.
+// Questions with more than one right answer become multi-select questions.
+package quizformat
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aspcodenet/systementorlivepolls/data"
+)
+
+// Supported formats.
+const (
+	Markdown = "markdown"
+	GIFT     = "gift"
+	Aiken    = "aiken"
+)
+
+// Formats lists the supported formats in the order they are offered to users.
+var Formats = []string{Markdown, GIFT, Aiken}
+
+// minOptions is the least number of options a question needs to be useful in a poll.
+const minOptions = 2
+
+// ParseError is a problem with the input, at a 1-based line number.
+type ParseError struct {
+	Line    int
+	Message string
+}
+
+func (e ParseError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Message)
+}
+
+// Result is what Parse found. Questions holds every question that could be parsed even when
+// there are errors, so a preview can show both.
+type Result struct {
+	Questions []data.Question
+	Errors    []ParseError
+}
+
+// Parse reads text in the given format. Only an unknown format returns an error; problems in the
+// text are reported in Result.Errors.
+func Parse(format, text string) (*Result, error) {
+	lines := splitLines(text)
+	switch format {
+	case Markdown:
+		return parseMarkdown(lines), nil
+	case GIFT:
+		return parseGIFT(lines), nil
+	case Aiken:
+		return parseAiken(lines), nil
+	}
+	return nil, fmt.Errorf("unknown format %q", format)
+}
+
+func splitLines(text string) []string {
+	text = strings.TrimPrefix(text, "\ufeff") // Byte order mark from Windows editors
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	return strings.Split(text, "\n")
+}
+
+// addError records a problem found at a line.
+func (r *Result) addError(line int, format string, args ...interface{}) {
+	r.Errors = append(r.Errors, ParseError{Line: line, Message: fmt.Sprintf(format, args...)})
+}
+
+// addQuestion checks a parsed question and adds it to the result, deciding between
+// single-select and multi-select from the number of correct options.
+func (r *Result) addQuestion(line int, text string, options []data.Option) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		r.addError(line, "question has no text")
+		return
+	}
+	if len(options) < minOptions {
+		r.addError(line, "question %q needs at least %d options, found %d", text, minOptions, len(options))
+		return
+	}
+	correct := 0
+	for _, o := range options {
+		if o.Correct {
+			correct++
+		}
+	}
+	questionType := "single-select"
+	if correct > 1 {
+		questionType = "multi-select"
+	}
+	r.Questions = append(r.Questions, data.Question{Text: text, Type: questionType, Options: options})
+}
@@ -0,0 +1,156 @@
+package quizformat
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aspcodenet/systementorlivepolls/data"
+)
+
+// summary describes a question as "type: text [option, *correct option]" for compact comparisons.
+func summary(q data.Question) string {
+	options := make([]string, 0, len(q.Options))
+	for _, o := range q.Options {
+		if o.Correct {
+			options = append(options, "*"+o.Text)
+		} else {
+			options = append(options, o.Text)
+		}
+	}
+	return q.Type + ": " + q.Text + " [" + strings.Join(options, ", ") + "]"
+}
+
+func assertParsed(t *testing.T, format, text string, wantQuestions []string, wantErrors []string) {
+	t.Helper()
+	result, err := Parse(format, text)
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+	var got []string
+	for _, q := range result.Questions {
+		got = append(got, summary(q))
+	}
+	if strings.Join(got, "\n") != strings.Join(wantQuestions, "\n") {
+		t.Errorf("questions:\n got: %q\nwant: %q", got, wantQuestions)
+	}
+	var gotErrors []string
+	for _, e := range result.Errors {
+		gotErrors = append(gotErrors, e.Error())
+	}
+	if strings.Join(gotErrors, "\n") != strings.Join(wantErrors, "\n") {
+		t.Errorf("errors:\n got: %q\nwant: %q", gotErrors, wantErrors)
+	}
+}
+
+func TestParseAiken(t *testing.T) {
+	text := `What is 2 + 2?
+A. 3
+B) 4
+ANSWER: B
+
+Which are colours?
+A. Red
+B. Blue
+C. Seven
+ANSWER: A, B
+
+Missing answer
+A. Yes
+B. No
+
+Bad answer
+A. Yes
+B. No
+ANSWER: D`
+	assertParsed(t, Aiken, text,
+		[]string{
+			"single-select: What is 2 + 2? [3, *4]",
+			"multi-select: Which are colours? [*Red, *Blue, Seven]",
+		},
+		[]string{
+			`line 12: question "Missing answer" has no ANSWER line`,
+			`line 19: ANSWER "D" is not one of the options`,
+		})
+}
+
+func TestParseGIFT(t *testing.T) {
+	text := `// A comment
+$CATEGORY: Maths
+
+::Q1:: What is 2 + 2? {=4 ~3 ~5#Too much}
+
+::Q2:: Which are prime numbers? {
+  ~%50%2
+  ~%50%3
+  ~%-100%4
+}
+
+Grass is green. {T}
+
+The sun is a {~planet =star} in our solar system.
+
+What is 1\=1? {=true ~false}
+
+Name a colour. {=red =blue}
+
+Write an essay. {}
+
+How much is 2 + 2? {#4}`
+	assertParsed(t, GIFT, text,
+		[]string{
+			"single-select: What is 2 + 2? [*4, 3, 5]",
+			"multi-select: Which are prime numbers? [*2, *3, 4]",
+			"single-select: Grass is green. [*True, False]",
+			"single-select: The sun is a _____ in our solar system. [planet, *star]",
+			"single-select: What is 1=1? [*true, false]",
+		},
+		[]string{
+			"line 18: short answer questions are not supported, mark wrong answers with ~",
+			"line 20: essay questions are not supported",
+			"line 22: numerical questions are not supported",
+		})
+}
+
+func TestParseGIFTUnclosedBrace(t *testing.T) {
+	assertParsed(t, GIFT, "Fine? {=yes ~no}\n\nBroken {=a ~b\n", []string{"single-select: Fine? [*yes, no]"},
+		[]string{"line 3: answer block is not closed with }"})
+}
+
+func TestParseMarkdown(t *testing.T) {
+	text := `# Friday quiz
+
+1. Which are prime numbers?
+   - [x] 2
+   - [x] 3
+   - [ ] 4
+
+## Favourite language?
+- Go
+- Rust
+
+A plain question
+that spans two lines
+* yes
+* no
+
+Only one option
+- lonely`
+	assertParsed(t, Markdown, text,
+		[]string{
+			"multi-select: Which are prime numbers? [*2, *3, 4]",
+			"single-select: Favourite language? [Go, Rust]",
+			"single-select: A plain question that spans two lines [yes, no]",
+		},
+		[]string{
+			`line 17: question "Only one option" needs at least 2 options, found 1`,
+		})
+
+	assertParsed(t, Markdown, "- orphan\n\nQuestion\n- a\n- b\n", []string{"single-select: Question [a, b]"},
+		[]string{"line 1: option without a question before it"})
+}
+
+func TestParseUnknownFormat(t *testing.T) {
+	if _, err := Parse("qti", "x"); err == nil {
+		t.Error("expected an unknown format to be rejected")
+	}
+}
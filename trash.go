@@ -0,0 +1,24 @@
+package main
+
+import (
+	"log"
+	"time"
+
+	"github.com/aspcodenet/systementorlivepolls/data"
+)
+
+// startTrashPurger permanently removes polls that have been in the trash longer than
+// the retention period. Runs once at startup and then every interval.
+func startTrashPurger(retention time.Duration, interval time.Duration) {
+	go func() {
+		for {
+			purged, err := data.PurgeDeletedPolls(time.Now().Add(-retention))
+			if err != nil {
+				log.Printf("Error purging deleted polls: %v", err)
+			} else if purged > 0 {
+				log.Printf("Purged %d deleted polls older than %v.", purged, retention)
+			}
+			time.Sleep(interval)
+		}
+	}()
+}
@@ -0,0 +1,66 @@
+package utils
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// QRRecoveryLevels maps the QR error correction levels, by the letters they are known by, to
+// how much of a code can be covered or damaged and still be read: L 7%, M 15%, Q 25%, H 30%.
+// Higher levels make denser codes.
+var QRRecoveryLevels = map[string]qrcode.RecoveryLevel{
+	"L": qrcode.Low,
+	"M": qrcode.Medium,
+	"Q": qrcode.High,
+	"H": qrcode.Highest,
+}
+
+// ParseQRRecoveryLevel looks up an error correction level by its letter, in either case.
+func ParseQRRecoveryLevel(s string) (qrcode.RecoveryLevel, bool) {
+	level, ok := QRRecoveryLevels[strings.ToUpper(s)]
+	return level, ok
+}
+
+// QRCodePNG encodes content as a size by size pixel PNG QR code, including the quiet zone.
+func QRCodePNG(content string, size int, level qrcode.RecoveryLevel) ([]byte, error) {
+	q, err := qrcode.New(content, level)
+	if err != nil {
+		return nil, err
+	}
+	return q.PNG(size)
+}
+
+// WriteQRCodeSVG encodes content as an SVG QR code that is size pixels wide, including the
+// quiet zone. The dark modules are drawn as a single path so the code scales without seams.
+func WriteQRCodeSVG(w io.Writer, content string, size int, level qrcode.RecoveryLevel) error {
+	q, err := qrcode.New(content, level)
+	if err != nil {
+		return err
+	}
+	modules := q.Bitmap()
+	n := len(modules)
+
+	b := bufio.NewWriter(w)
+	fmt.Fprintf(b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`+"\n", size, size, n, n)
+	fmt.Fprintf(b, `<rect width="%d" height="%d" fill="#ffffff"/>`+"\n", n, n)
+	b.WriteString(`<path fill="#000000" d="`)
+	for y, row := range modules {
+		for x := 0; x < len(row); x++ {
+			if !row[x] {
+				continue
+			}
+			run := 1
+			for x+run < len(row) && row[x+run] {
+				run++
+			}
+			fmt.Fprintf(b, "M%d %dh%dv1h-%dz", x, y, run, run)
+			x += run - 1
+		}
+	}
+	b.WriteString(`"/>` + "\n</svg>\n")
+	return b.Flush()
+}
@@ -0,0 +1,62 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/xml"
+	"image/png"
+	"strings"
+	"testing"
+
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+func TestParseQRRecoveryLevel(t *testing.T) {
+	if level, ok := ParseQRRecoveryLevel("q"); !ok || level != qrcode.High {
+		t.Errorf("ParseQRRecoveryLevel(q) = %v, %v, want High", level, ok)
+	}
+	if _, ok := ParseQRRecoveryLevel("X"); ok {
+		t.Error("ParseQRRecoveryLevel(X) should fail")
+	}
+}
+
+func TestQRCodePNG(t *testing.T) {
+	raw, err := QRCodePNG("https://example.com/poll/abc", 256, qrcode.Medium)
+	if err != nil {
+		t.Fatalf("QRCodePNG returned an error: %v", err)
+	}
+	img, err := png.Decode(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatalf("not a PNG: %v", err)
+	}
+	if img.Bounds().Dx() != 256 || img.Bounds().Dy() != 256 {
+		t.Errorf("size = %v, want 256x256", img.Bounds().Size())
+	}
+}
+
+func TestWriteQRCodeSVG(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteQRCodeSVG(&buf, "https://example.com/poll/abc", 300, qrcode.Low); err != nil {
+		t.Fatalf("WriteQRCodeSVG returned an error: %v", err)
+	}
+	var doc struct {
+		Width   string `xml:"width,attr"`
+		ViewBox string `xml:"viewBox,attr"`
+		Path    struct {
+			D string `xml:"d,attr"`
+		} `xml:"path"`
+	}
+	if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("SVG is not well formed: %v", err)
+	}
+	if doc.Width != "300" {
+		t.Errorf("width = %q, want 300", doc.Width)
+	}
+	// Version 2 at level L: 25 modules plus a 4 module quiet zone on each side
+	if doc.ViewBox != "0 0 33 33" {
+		t.Errorf("viewBox = %q, want 0 0 33 33", doc.ViewBox)
+	}
+	// The top left finder pattern starts after the quiet zone with a row of 7 dark modules
+	if !strings.HasPrefix(doc.Path.D, "M4 4h7v1h-7z") {
+		t.Errorf("path starts with %q, want the finder pattern", doc.Path.D[:20])
+	}
+}
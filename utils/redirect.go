@@ -0,0 +1,28 @@
+package utils
+
+import (
+	"net/url"
+	"strings"
+)
+
+// maxRedirectLength keeps a redirect target from filling up a cookie session.
+const maxRedirectLength = 1024
+
+// IsSafeRedirect reports whether target can be redirected to without leaving the site: a path
+// relative to the site root, like "/admin/polls?tab=2". Absolute URLs, protocol-relative
+// "//host" and "/\host", which browsers treat the same, and control characters are refused.
+func IsSafeRedirect(target string) bool {
+	if target == "" || len(target) > maxRedirectLength || target[0] != '/' {
+		return false
+	}
+	if strings.HasPrefix(target, "//") || strings.ContainsRune(target, '\\') {
+		return false
+	}
+	for _, r := range target {
+		if r < 0x20 || r == 0x7f {
+			return false
+		}
+	}
+	u, err := url.Parse(target)
+	return err == nil && u.Scheme == "" && u.Host == "" && u.User == nil
+}
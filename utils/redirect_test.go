@@ -0,0 +1,36 @@
+package utils
+
+import "testing"
+
+func TestIsSafeRedirect(t *testing.T) {
+	safe := []string{
+		"/",
+		"/admin/polls",
+		"/admin/polls/edit/abc?tab=2#questions",
+		"/admin/polls?next=https://example.com",
+	}
+	for _, target := range safe {
+		if !IsSafeRedirect(target) {
+			t.Errorf("IsSafeRedirect(%q) = false, expected true", target)
+		}
+	}
+
+	unsafe := []string{
+		"",
+		"admin/polls",
+		"https://evil.example",
+		"//evil.example/admin",
+		"/\\evil.example",
+		"/\\/evil.example",
+		"\\\\evil.example",
+		"javascript:alert(1)",
+		"/admin\r\nLocation: https://evil.example",
+		"/admin\tpolls",
+		"/" + string(make([]byte, maxRedirectLength)),
+	}
+	for _, target := range unsafe {
+		if IsSafeRedirect(target) {
+			t.Errorf("IsSafeRedirect(%q) = true, expected false", target)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// SignRequest computes the hex encoded HMAC-SHA256 signature of an API request.
+// The signed string is the method, path (with query), timestamp, nonce and the SHA-256
+// of the body, separated by newlines.
+func SignRequest(secret, method, pathAndQuery, timestamp, nonce string, body []byte) string {
+	bodyHash := sha256.Sum256(body)
+	stringToSign := strings.Join([]string{
+		strings.ToUpper(method),
+		pathAndQuery,
+		timestamp,
+		nonce,
+		hex.EncodeToString(bodyHash[:]),
+	}, "\n")
+	return Sign(secret, []byte(stringToSign))
+}
+
+// Sign returns the hex encoded HMAC-SHA256 of payload using secret as key.
+func Sign(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifySignature compares a hex encoded signature against the expected one in constant time.
+func VerifySignature(expected, given string) bool {
+	return hmac.Equal([]byte(expected), []byte(strings.ToLower(given)))
+}
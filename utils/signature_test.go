@@ -0,0 +1,50 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestSignRequest_Deterministic tests that the same request always gives the same signature.
+func TestSignRequest_Deterministic(t *testing.T) {
+	a := SignRequest("secret", "get", "/api/v1/polls?page=1", "1700000000", "nonce", []byte(""))
+	b := SignRequest("secret", "GET", "/api/v1/polls?page=1", "1700000000", "nonce", nil)
+	if a != b {
+		t.Errorf("SignRequest gave different signatures for the same request: %s, %s", a, b)
+	}
+	if len(a) != 64 {
+		t.Errorf("SignRequest returned signature of length %d, expected 64 hex characters", len(a))
+	}
+}
+
+// TestSignRequest_CoversAllParts tests that changing any part of the request changes the signature.
+func TestSignRequest_CoversAllParts(t *testing.T) {
+	base := SignRequest("secret", "POST", "/api/v1/polls", "1700000000", "nonce", []byte(`{"title":"x"}`))
+	variants := map[string]string{
+		"secret":    SignRequest("other", "POST", "/api/v1/polls", "1700000000", "nonce", []byte(`{"title":"x"}`)),
+		"method":    SignRequest("secret", "PUT", "/api/v1/polls", "1700000000", "nonce", []byte(`{"title":"x"}`)),
+		"path":      SignRequest("secret", "POST", "/api/v1/polls/1", "1700000000", "nonce", []byte(`{"title":"x"}`)),
+		"timestamp": SignRequest("secret", "POST", "/api/v1/polls", "1700000001", "nonce", []byte(`{"title":"x"}`)),
+		"nonce":     SignRequest("secret", "POST", "/api/v1/polls", "1700000000", "nonce2", []byte(`{"title":"x"}`)),
+		"body":      SignRequest("secret", "POST", "/api/v1/polls", "1700000000", "nonce", []byte(`{"title":"y"}`)),
+	}
+	for part, sig := range variants {
+		if sig == base {
+			t.Errorf("changing the %s did not change the signature", part)
+		}
+	}
+}
+
+// TestVerifySignature tests constant time comparison, accepting upper case hex.
+func TestVerifySignature(t *testing.T) {
+	sig := Sign("secret", []byte("payload"))
+	if !VerifySignature(sig, sig) {
+		t.Errorf("VerifySignature rejected a matching signature")
+	}
+	if !VerifySignature(sig, strings.ToUpper(sig)) {
+		t.Errorf("VerifySignature rejected an upper case signature")
+	}
+	if VerifySignature(sig, Sign("secret", []byte("other"))) {
+		t.Errorf("VerifySignature accepted a different signature")
+	}
+}
@@ -0,0 +1,56 @@
+package utils
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestXLSXColumnName(t *testing.T) {
+	cases := map[int]string{0: "A", 25: "Z", 26: "AA", 27: "AB", 701: "ZZ", 702: "AAA"}
+	for index, want := range cases {
+		if got := XLSXColumnName(index); got != want {
+			t.Errorf("XLSXColumnName(%d) = %s, want %s", index, got, want)
+		}
+	}
+}
+
+func TestWriteXLSX(t *testing.T) {
+	var buf bytes.Buffer
+	err := WriteXLSX(&buf, []XLSXSheet{
+		{Name: "Results", Rows: [][]interface{}{{"Option", "Count", "Percentage"}, {"Fish & <chips>", 3, 37.5}}},
+		{Name: "Responses", Rows: [][]interface{}{{"Voter"}}},
+	})
+	if err != nil {
+		t.Fatalf("WriteXLSX returned error: %v", err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("output is not a zip file: %v", err)
+	}
+	files := map[string]string{}
+	for _, f := range zr.File {
+		rc, _ := f.Open()
+		content, _ := io.ReadAll(rc)
+		rc.Close()
+		files[f.Name] = string(content)
+	}
+
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels", "xl/worksheets/sheet1.xml", "xl/worksheets/sheet2.xml"} {
+		if _, ok := files[name]; !ok {
+			t.Errorf("workbook is missing %s", name)
+		}
+	}
+	sheet := files["xl/worksheets/sheet1.xml"]
+	for _, want := range []string{`Fish &amp; &lt;chips&gt;`, `<c r="B2"><v>3</v></c>`, `<c r="C2"><v>37.5</v></c>`} {
+		if !strings.Contains(sheet, want) {
+			t.Errorf("sheet1 should contain %s, got %s", want, sheet)
+		}
+	}
+	if !strings.Contains(files["xl/workbook.xml"], `name="Responses"`) {
+		t.Errorf("workbook should list the Responses sheet")
+	}
+}
@@ -0,0 +1,89 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrPrivateAddress is returned for webhook URLs that point into a private network, so
+// editors cannot use deliveries and their logged status to probe the server's network.
+var ErrPrivateAddress = errors.New("webhooks cannot be sent to private, loopback or link-local addresses")
+
+// AllowPrivateAddresses lets deliveries go to private addresses, for tests with a local receiver.
+var AllowPrivateAddresses = false
+
+// carrierGradeNAT is 100.64.0.0/10, shared address space that is not reachable from the internet.
+var carrierGradeNAT = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// IsPublicIP reports whether ip may receive deliveries: not loopback, private (RFC 1918 and
+// unique local), link-local like the 169.254.169.254 cloud metadata service, multicast or
+// unspecified.
+func IsPublicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() || carrierGradeNAT.Contains(ip))
+}
+
+// ValidateURL checks a webhook URL before it is stored: http or https with a host that is not
+// obviously private. Host names are checked again against the addresses they resolve to when
+// a delivery connects, see Client.
+func ValidateURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return errors.New("enter an http or https URL")
+	}
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	if AllowPrivateAddresses {
+		return nil
+	}
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return ErrPrivateAddress
+	}
+	if ip := net.ParseIP(host); ip != nil && !IsPublicIP(ip) {
+		return ErrPrivateAddress
+	}
+	return nil
+}
+
+// publicDialer connects only to public addresses. The check runs on the resolved address of
+// each connection, so a host name that resolves to a private address, now or after the URL was
+// checked, is refused too.
+var publicDialer = &net.Dialer{
+	Timeout: 5 * time.Second,
+	Control: func(network, address string, _ syscall.RawConn) error {
+		if AllowPrivateAddresses {
+			return nil
+		}
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return err
+		}
+		if ip := net.ParseIP(host); ip == nil || !IsPublicIP(ip) {
+			return ErrPrivateAddress
+		}
+		return nil
+	},
+}
+
+// Client sends the deliveries. It only connects to public addresses and does not follow
+// redirects, which could lead to a private address; a redirect is logged as a failed delivery.
+var Client = &http.Client{
+	Timeout: 10 * time.Second,
+	Transport: &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return publicDialer.DialContext(ctx, network, addr)
+		},
+		ForceAttemptHTTP2:   true,
+		TLSHandshakeTimeout: 5 * time.Second,
+		MaxIdleConns:        10,
+		IdleConnTimeout:     90 * time.Second,
+	},
+	CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
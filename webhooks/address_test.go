@@ -0,0 +1,64 @@
+package webhooks
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aspcodenet/systementorlivepolls/data"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsPublicIP(t *testing.T) {
+	for _, ip := range []string{"127.0.0.1", "10.1.2.3", "172.16.0.1", "192.168.1.1", "169.254.169.254", "100.64.0.1", "0.0.0.0", "::1", "fd00:ec2::254", "fe80::1", "::ffff:127.0.0.1"} {
+		assert.False(t, IsPublicIP(net.ParseIP(ip)), ip)
+	}
+	for _, ip := range []string{"8.8.8.8", "93.184.216.34", "2606:4700::1111"} {
+		assert.True(t, IsPublicIP(net.ParseIP(ip)), ip)
+	}
+}
+
+func TestValidateURL(t *testing.T) {
+	assert.NoError(t, ValidateURL("https://example.com/hook"))
+	assert.NoError(t, ValidateURL("http://203.0.113.10:8080/hook"))
+	for _, raw := range []string{"ftp://example.com", "https://", "not a url"} {
+		err := ValidateURL(raw)
+		assert.Error(t, err, raw)
+		assert.NotErrorIs(t, err, ErrPrivateAddress, raw)
+	}
+	for _, raw := range []string{"http://localhost/hook", "http://api.localhost/", "http://127.0.0.1:9000/", "http://169.254.169.254/latest/meta-data/", "http://[::1]/", "http://10.0.0.5/"} {
+		assert.ErrorIs(t, ValidateURL(raw), ErrPrivateAddress, raw)
+	}
+}
+
+func TestClientRefusesPrivateAddresses(t *testing.T) {
+	setupTestDB(t)
+	reached := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { reached = true }))
+	defer server.Close()
+	hook, err := data.CreateWebhook(1, 0, server.URL, nil)
+	assert.NoError(t, err)
+
+	delivery, err := SendTest(hook)
+	assert.NoError(t, err)
+	assert.False(t, delivery.Success)
+	assert.Zero(t, delivery.StatusCode)
+	assert.Contains(t, delivery.Error, ErrPrivateAddress.Error())
+	assert.False(t, reached, "the local server should not be connected to")
+}
+
+func TestClientDoesNotFollowRedirects(t *testing.T) {
+	setupTestDB(t)
+	target, received := newReceiver(t)
+	redirector := httptest.NewServer(http.RedirectHandler(target.URL, http.StatusTemporaryRedirect))
+	defer redirector.Close()
+	hook, err := data.CreateWebhook(1, 0, redirector.URL, nil)
+	assert.NoError(t, err)
+
+	delivery, err := SendTest(hook)
+	assert.NoError(t, err)
+	assert.False(t, delivery.Success)
+	assert.Equal(t, http.StatusTemporaryRedirect, delivery.StatusCode)
+	assert.Empty(t, received(), "the redirect should not be followed")
+}
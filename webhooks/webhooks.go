@@ -0,0 +1,244 @@
+// Package webhooks sends poll lifecycle events to the URLs admins subscribe with data.Webhook.
+//
+// Each delivery is an HTTP POST of a JSON Payload. The receiver can check that it came from us
+// with the X-LivePolls-Signature header: "sha256=" followed by the hex HMAC-SHA256 of
+// "<X-LivePolls-Timestamp>.<body>", keyed with the webhook secret. Deliveries that fail or get a
+// non-2xx answer are retried after the delays in Backoff, and every attempt is logged as a
+// data.WebhookDelivery.
+package webhooks
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aspcodenet/systementorlivepolls/data"
+	"github.com/aspcodenet/systementorlivepolls/utils"
+)
+
+// Event types.
+const (
+	EventPollStarted     = "poll.started"
+	EventQuestionChanged = "poll.question_changed"
+	EventResultsShown    = "poll.results_shown"
+	EventPollFinished    = "poll.finished"
+	EventTest            = "webhook.test" // Sent with the "Send test event" button, to that webhook only
+)
+
+// Events lists the event types a webhook can subscribe to.
+var Events = []string{EventPollStarted, EventQuestionChanged, EventResultsShown, EventPollFinished}
+
+// Request headers.
+const (
+	HeaderEvent     = "X-LivePolls-Event"
+	HeaderDelivery  = "X-LivePolls-Delivery"
+	HeaderTimestamp = "X-LivePolls-Timestamp"
+	HeaderSignature = "X-LivePolls-Signature"
+)
+
+// Backoff holds the delays before each retry; a delivery is attempted len(Backoff)+1 times.
+var Backoff = []time.Duration{10 * time.Second, time.Minute, 5 * time.Minute, 30 * time.Minute}
+
+// Payload is the JSON body of a delivery.
+type Payload struct {
+	ID        string    `json:"id"` // Unique per event, the same for all retries
+	Event     string    `json:"event"`
+	CreatedAt time.Time `json:"createdAt"`
+	Poll      Poll      `json:"poll"`
+	Question  *Question `json:"question,omitempty"` // The current question, if any
+}
+
+// Poll describes the poll the event is about.
+type Poll struct {
+	ID                   uint   `json:"id"`
+	InviteID             string `json:"inviteId"`
+	Title                string `json:"title"`
+	Status               string `json:"status"`
+	CurrentQuestionIndex int    `json:"currentQuestionIndex"`
+	QuestionCount        int    `json:"questionCount"`
+}
+
+// Question is the current question of the poll. Votes is only set for poll.results_shown.
+type Question struct {
+	ID      uint           `json:"id"`
+	Text    string         `json:"text"`
+	Type    string         `json:"type"`
+	Options []Option       `json:"options"`
+	Votes   map[string]int `json:"votes,omitempty"`
+}
+
+// Option is an answer option of a question.
+type Option struct {
+	ID   uint   `json:"id"`
+	Text string `json:"text"`
+}
+
+var pending sync.WaitGroup
+
+// NewPayload describes an event for a poll. votes, keyed by option ID like data.Question.Votes,
+// is included with the current question when not nil.
+func NewPayload(event string, p *data.Poll, votes map[string]int) (*Payload, error) {
+	id, err := utils.RandString(16)
+	if err != nil {
+		return nil, err
+	}
+	payload := &Payload{
+		ID:        "evt_" + id,
+		Event:     event,
+		CreatedAt: time.Now().UTC(),
+		Poll: Poll{
+			ID:                   p.ID,
+			InviteID:             p.InviteID,
+			Title:                p.Title,
+			Status:               p.Status,
+			CurrentQuestionIndex: p.CurrentQuestionIndex,
+			QuestionCount:        len(p.Questions),
+		},
+	}
+	if p.CurrentQuestionIndex >= 0 && p.CurrentQuestionIndex < len(p.Questions) {
+		q := &p.Questions[p.CurrentQuestionIndex]
+		question := &Question{ID: q.ID, Text: q.Text, Type: q.Type, Options: make([]Option, 0, len(q.Options)), Votes: votes}
+		for _, opt := range q.Options {
+			question.Options = append(question.Options, Option{ID: opt.ID, Text: opt.Text})
+		}
+		payload.Question = question
+	}
+	return payload, nil
+}
+
+// Dispatch sends an event to every active webhook that subscribes to it, see data.GetWebhooksForEvent.
+// The deliveries run in the background; Dispatch only reads the poll, so the caller may hold p.Mu.
+func Dispatch(event string, p *data.Poll, votes map[string]int) {
+	hooks, err := data.GetWebhooksForEvent(p, event)
+	if err != nil {
+		log.Printf("Error retrieving webhooks for poll %d: %v", p.ID, err)
+		return
+	}
+	if len(hooks) == 0 {
+		return
+	}
+	payload, err := NewPayload(event, p, votes)
+	if err != nil {
+		log.Printf("Error creating webhook payload for poll %d: %v", p.ID, err)
+		return
+	}
+	for _, hook := range hooks {
+		pending.Add(1)
+		go func(hook *data.Webhook) {
+			defer pending.Done()
+			Deliver(hook, payload)
+		}(hook)
+	}
+}
+
+// Wait blocks until all deliveries started by Dispatch are done, including their retries.
+func Wait() {
+	pending.Wait()
+}
+
+// SendTest sends a webhook.test event to a webhook once, without retries, so the admin sees
+// the outcome right away. The payload describes the webhook's poll, or a made up poll for
+// webhooks on all polls.
+func SendTest(hook *data.Webhook) (*data.WebhookDelivery, error) {
+	p := &data.Poll{Title: "Test poll", Status: "setup", CurrentQuestionIndex: -1}
+	if hook.Poll != nil {
+		p = hook.Poll
+	}
+	payload, err := NewPayload(EventTest, p, nil)
+	if err != nil {
+		return nil, err
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	delivery := attemptDelivery(hook, payload, body, 1)
+	if err := data.RecordWebhookDelivery(delivery); err != nil {
+		return delivery, err
+	}
+	return delivery, nil
+}
+
+// Deliver sends a payload to a webhook, retrying with Backoff until it succeeds.
+// Returns the last attempt.
+func Deliver(hook *data.Webhook, payload *Payload) *data.WebhookDelivery {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("Error encoding webhook payload %s: %v", payload.ID, err)
+		return nil
+	}
+
+	var delivery *data.WebhookDelivery
+	for attempt := 1; ; attempt++ {
+		delivery = attemptDelivery(hook, payload, body, attempt)
+		if err := data.RecordWebhookDelivery(delivery); err != nil {
+			log.Printf("Error logging delivery of %s to webhook %d: %v", payload.ID, hook.ID, err)
+		}
+		if delivery.Success || attempt > len(Backoff) {
+			break
+		}
+		time.Sleep(Backoff[attempt-1])
+	}
+	if !delivery.Success {
+		log.Printf("Giving up delivering %s to webhook %d after %d attempts", payload.ID, hook.ID, delivery.Attempt)
+	}
+	return delivery
+}
+
+func attemptDelivery(hook *data.Webhook, payload *Payload, body []byte, attempt int) *data.WebhookDelivery {
+	delivery := &data.WebhookDelivery{
+		WebhookID: hook.ID,
+		EventID:   payload.ID,
+		Event:     payload.Event,
+		Attempt:   attempt,
+		Payload:   string(body),
+	}
+
+	req, err := http.NewRequest(http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		delivery.Error = err.Error()
+		return delivery
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "LivePolls-Webhooks/1")
+	req.Header.Set(HeaderEvent, payload.Event)
+	req.Header.Set(HeaderDelivery, payload.ID)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, Signature(hook.Secret, timestamp, body))
+
+	start := time.Now()
+	resp, err := Client.Do(req)
+	delivery.DurationMs = time.Since(start).Milliseconds()
+	if err != nil {
+		delivery.Error = truncate(err.Error(), 500)
+		return delivery
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10)) // Drain so the connection can be reused
+
+	delivery.StatusCode = resp.StatusCode
+	delivery.Success = resp.StatusCode >= 200 && resp.StatusCode < 300
+	if !delivery.Success {
+		delivery.Error = fmt.Sprintf("unexpected status %s", resp.Status)
+	}
+	return delivery
+}
+
+// Signature returns the X-LivePolls-Signature value for a body sent at timestamp.
+func Signature(secret, timestamp string, body []byte) string {
+	return "sha256=" + utils.Sign(secret, []byte(timestamp+"."+string(body)))
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
@@ -0,0 +1,264 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aspcodenet/systementorlivepolls/data"
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// setupTestDB points data.DB at a fresh in-memory SQLite database with all tables migrated.
+func setupTestDB(t *testing.T) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1) // Every connection to :memory: is a separate database
+	if err := data.Migrate(db); err != nil {
+		t.Fatalf("failed to migrate test database: %v", err)
+	}
+	data.DB = db
+	t.Cleanup(func() { sqlDB.Close() })
+}
+
+// noBackoff makes retries immediate for the duration of a test.
+func noBackoff(t *testing.T) {
+	saved := Backoff
+	Backoff = []time.Duration{0, 0, 0}
+	t.Cleanup(func() { Backoff = saved })
+}
+
+// allowLocalReceivers lets deliveries go to test servers on 127.0.0.1 for the duration of a test.
+func allowLocalReceivers(t *testing.T) {
+	AllowPrivateAddresses = true
+	t.Cleanup(func() { AllowPrivateAddresses = false })
+}
+
+// receivedRequest is a delivery as seen by the test receiver.
+type receivedRequest struct {
+	Path   string
+	Header http.Header
+	Body   []byte
+}
+
+// newReceiver starts an HTTP server that answers the given status codes in turn, then 200,
+// and records what it receives. Deliveries to local addresses are allowed while the test runs.
+func newReceiver(t *testing.T, statuses ...int) (*httptest.Server, func() []receivedRequest) {
+	allowLocalReceivers(t)
+	var mu sync.Mutex
+	var received []receivedRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		received = append(received, receivedRequest{Path: r.URL.Path, Header: r.Header.Clone(), Body: body})
+		status := http.StatusOK
+		if len(received) <= len(statuses) {
+			status = statuses[len(received)-1]
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server, func() []receivedRequest {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]receivedRequest(nil), received...)
+	}
+}
+
+func createTestAdmin(t *testing.T, email string) *data.AdminUser {
+	t.Helper()
+	adminUser := &data.AdminUser{Email: email, Active: true, Role: data.RoleAdmin}
+	if err := data.DB.Create(adminUser).Error; err != nil {
+		t.Fatalf("failed to create admin user: %v", err)
+	}
+	return adminUser
+}
+
+func createTestPoll(t *testing.T, adminUserID uint) *data.Poll {
+	t.Helper()
+	poll := &data.Poll{
+		Title:                "Quiz",
+		Status:               "active",
+		CurrentQuestionIndex: 0,
+		AdminUserID:          int(adminUserID),
+		InviteID:             "invite",
+		Questions: []data.Question{
+			{Text: "Best color?", Type: "single-select", Options: []data.Option{{Text: "Red"}, {Text: "Blue"}}},
+		},
+	}
+	if err := data.DB.Create(poll).Error; err != nil {
+		t.Fatalf("failed to create poll: %v", err)
+	}
+	return poll
+}
+
+func TestDeliverSignsPayload(t *testing.T) {
+	setupTestDB(t)
+	server, received := newReceiver(t)
+	poll := createTestPoll(t, 1)
+	hook, err := data.CreateWebhook(1, 0, server.URL, nil)
+	assert.NoError(t, err)
+
+	payload, err := NewPayload(EventResultsShown, poll, map[string]int{"1": 3})
+	assert.NoError(t, err)
+	delivery := Deliver(hook, payload)
+	assert.True(t, delivery.Success)
+	assert.Equal(t, http.StatusOK, delivery.StatusCode)
+
+	requests := received()
+	if !assert.Len(t, requests, 1) {
+		return
+	}
+	r := requests[0]
+	assert.Equal(t, EventResultsShown, r.Header.Get(HeaderEvent))
+	assert.Equal(t, payload.ID, r.Header.Get(HeaderDelivery))
+	assert.Equal(t, Signature(hook.Secret, r.Header.Get(HeaderTimestamp), r.Body), r.Header.Get(HeaderSignature))
+	assert.NotEqual(t, Signature("wrong", r.Header.Get(HeaderTimestamp), r.Body), r.Header.Get(HeaderSignature))
+
+	var got Payload
+	assert.NoError(t, json.Unmarshal(r.Body, &got))
+	assert.Equal(t, poll.ID, got.Poll.ID)
+	assert.Equal(t, "invite", got.Poll.InviteID)
+	if assert.NotNil(t, got.Question) {
+		assert.Equal(t, "Best color?", got.Question.Text)
+		assert.Len(t, got.Question.Options, 2)
+		assert.Equal(t, 3, got.Question.Votes["1"])
+	}
+}
+
+func TestDeliverRetriesUntilSuccess(t *testing.T) {
+	setupTestDB(t)
+	noBackoff(t)
+	server, received := newReceiver(t, http.StatusInternalServerError, http.StatusServiceUnavailable)
+	poll := createTestPoll(t, 1)
+	hook, err := data.CreateWebhook(1, 0, server.URL, nil)
+	assert.NoError(t, err)
+
+	payload, err := NewPayload(EventPollStarted, poll, nil)
+	assert.NoError(t, err)
+	delivery := Deliver(hook, payload)
+	assert.True(t, delivery.Success)
+	assert.Equal(t, 3, delivery.Attempt)
+
+	requests := received()
+	assert.Len(t, requests, 3)
+	for _, r := range requests {
+		assert.Equal(t, payload.ID, r.Header.Get(HeaderDelivery), "retries should keep the delivery ID")
+	}
+
+	deliveries, err := data.GetWebhookDeliveries(hook.ID, 10)
+	assert.NoError(t, err)
+	if assert.Len(t, deliveries, 3) {
+		assert.True(t, deliveries[0].Success)
+		assert.False(t, deliveries[2].Success)
+		assert.Equal(t, http.StatusInternalServerError, deliveries[2].StatusCode)
+		assert.Contains(t, deliveries[2].Error, "500")
+	}
+}
+
+func TestDeliverGivesUp(t *testing.T) {
+	setupTestDB(t)
+	noBackoff(t)
+	server, received := newReceiver(t, 500, 500, 500, 500, 500)
+	poll := createTestPoll(t, 1)
+	hook, err := data.CreateWebhook(1, 0, server.URL, nil)
+	assert.NoError(t, err)
+
+	payload, err := NewPayload(EventPollStarted, poll, nil)
+	assert.NoError(t, err)
+	delivery := Deliver(hook, payload)
+	assert.False(t, delivery.Success)
+	assert.Len(t, received(), len(Backoff)+1)
+}
+
+func TestDispatchFiltersWebhooks(t *testing.T) {
+	setupTestDB(t)
+	createTestAdmin(t, "one@example.com")
+	createTestAdmin(t, "two@example.com")
+	server, received := newReceiver(t)
+	poll := createTestPoll(t, 1)
+	other := createTestPoll(t, 1)
+
+	_, err := data.CreateWebhook(1, 0, server.URL+"/all", nil)
+	assert.NoError(t, err)
+	_, err = data.CreateWebhook(1, poll.ID, server.URL+"/poll", []string{EventPollFinished})
+	assert.NoError(t, err)
+	_, err = data.CreateWebhook(1, other.ID, server.URL+"/other", nil)
+	assert.NoError(t, err)
+	_, err = data.CreateWebhook(2, 0, server.URL+"/someone-else", nil)
+	assert.NoError(t, err)
+
+	Dispatch(EventPollStarted, poll, nil)
+	Wait()
+	assert.Len(t, received(), 1, "only the webhook for all polls subscribes to poll.started")
+
+	Dispatch(EventPollFinished, poll, nil)
+	Wait()
+	assert.Len(t, received(), 3)
+}
+
+func TestDispatchSkipsWebhooksAfterLosingAccess(t *testing.T) {
+	setupTestDB(t)
+	server, received := newReceiver(t)
+	owner := createTestAdmin(t, "owner@example.com")
+	colleague := createTestAdmin(t, "colleague@example.com")
+	poll := createTestPoll(t, owner.ID)
+	assert.NoError(t, data.SharePoll(poll.ID, colleague.ID, data.AccessEdit))
+
+	_, err := data.CreateWebhook(owner.ID, 0, server.URL+"/owner-all", nil)
+	assert.NoError(t, err)
+	_, err = data.CreateWebhook(owner.ID, poll.ID, server.URL+"/owner-poll", nil)
+	assert.NoError(t, err)
+	_, err = data.CreateWebhook(colleague.ID, poll.ID, server.URL+"/colleague-poll", nil)
+	assert.NoError(t, err)
+
+	Dispatch(EventPollStarted, poll, nil)
+	Wait()
+	assert.Len(t, received(), 3)
+
+	// Unsharing silences the colleague's webhook
+	assert.NoError(t, data.SharePoll(poll.ID, colleague.ID, ""))
+	Dispatch(EventPollStarted, poll, nil)
+	Wait()
+	assert.Len(t, received(), 5)
+
+	// After a transfer only the new owner's webhooks fire
+	assert.NoError(t, data.TransferPoll(poll, colleague.ID))
+	_, err = data.CreateWebhook(colleague.ID, 0, server.URL+"/colleague-all", nil)
+	assert.NoError(t, err)
+	Dispatch(EventPollStarted, poll, nil)
+	Wait()
+	paths := []string{}
+	for _, r := range received()[5:] {
+		paths = append(paths, r.Path)
+	}
+	assert.ElementsMatch(t, []string{"/colleague-poll", "/colleague-all"}, paths)
+}
+
+func TestSendTest(t *testing.T) {
+	setupTestDB(t)
+	noBackoff(t)
+	server, received := newReceiver(t, http.StatusNotFound)
+	hook, err := data.CreateWebhook(1, 0, server.URL, nil)
+	assert.NoError(t, err)
+
+	delivery, err := SendTest(hook)
+	assert.NoError(t, err)
+	assert.False(t, delivery.Success)
+	assert.Equal(t, http.StatusNotFound, delivery.StatusCode)
+	if requests := received(); assert.Len(t, requests, 1, "test events are not retried") {
+		assert.Equal(t, EventTest, requests[0].Header.Get(HeaderEvent))
+	}
+}
@@ -1,106 +1,137 @@
 package main
 
 import (
+	"crypto/subtle"
 	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
-	"strconv"
+	"strings"
 	"sync"
 
 	"github.com/aspcodenet/systementorlivepolls/data"
+	"github.com/aspcodenet/systementorlivepolls/pages"
+	"github.com/aspcodenet/systementorlivepolls/protocol"
 	"github.com/aspcodenet/systementorlivepolls/utils"
+	"github.com/aspcodenet/systementorlivepolls/webhooks"
+	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
 )
 
-// import (
-// 	"encoding/json"
-// 	"fmt"
-// 	"log"
-// 	"net/http"
-// 	"strconv"
-// 	"sync"
-
-// 	"github.com/aspcodenet/systementorlivepolls/data"
-// 	"github.com/gin-gonic/gin"
-// 	"github.com/gorilla/websocket"
-// )
-
 var (
-	// connections stores WebSocket connections, keyed by PollID, then by connection pointer.
-	connections = make(map[string]map[*websocket.Conn]bool)
+	// connections stores WebSocket clients, keyed by invite ID, then by connection pointer.
+	connections = make(map[string]map[*websocket.Conn]*wsClient)
 	// mutex for protecting access to connections map. Polls are now managed via DB and their internal mutex.
 	globalMutex = &sync.Mutex{}
 
-	// WebSocket upgrader.
+	// WebSocket upgrader. Other sites may only open sockets when ALLOWED_ORIGINS lists them, see originPolicy.
 	upgrader = websocket.Upgrader{
 		CheckOrigin: func(r *http.Request) bool {
-			return true // Allow all origins for simplicity in this example
+			return allowedOrigins.checkOrigin(r)
 		},
+		Subprotocols: protocol.Subprotocols(),
 	}
 )
 
-type WebSocketMessage struct {
-	Type   string `json:"type"` // e.g., "submit_vote", "admin_action", "poll_state_update", "admin_results_update"
-	PollID string `json:"pollId"`
-	Status string `json:"status,omitempty"`
-
-	QuestionID      string                    `json:"questionId,omitempty"`
-	SelectedOptions []string                  `json:"selectedOptions,omitempty"` // For user votes
-	Action          string                    `json:"action,omitempty"`          // For admin actions: "next", "show_results", "done"
-	CurrentQuestion *data.Question            `json:"currentQuestion,omitempty"` // For poll state updates
-	Results         map[string]map[string]int `json:"results,omitempty"`         // For poll state updates (overall results)
-	Votes           map[string]int            `json:"votes,omitempty"`           // For admin results update (current question votes)
-	TotalVotes      int                       `json:"totalVotes,omitempty"`      // For admin results update
-	Message         string                    `json:"message,omitempty"`
-	AllQuestions    []data.Question           `json:"allQuestions,omitempty"` // For final results, includes all question details
-	VoterID         string                    `json:"voterId,omitempty"`      // Identifier for the voter
+// wsClient is one connected browser. Writes go through send because a websocket.Conn
+// supports only one concurrent writer, and broadcasts come from other connections' goroutines.
+type wsClient struct {
+	conn            *websocket.Conn
+	role            string // protocol.RoleAdmin, protocol.RolePresenter or empty for participants
+	canControl      bool   // The admin may send admin_action, viewers may only watch
+	protocolVersion int
+	mu              sync.Mutex
+}
+
+// seesLiveResults reports whether the client gets results_update messages while voting is open.
+func (c *wsClient) seesLiveResults() bool {
+	return c.role == protocol.RoleAdmin || c.role == protocol.RolePresenter
+}
+
+func (c *wsClient) send(msg interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.conn.WriteJSON(msg)
 }
 
 func handleWebSocket(c *gin.Context) {
-	pollIDStr := c.Param("inviteID")
+	inviteID := c.Param("inviteID")
 
 	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
-		log.Printf("Failed to upgrade websocket for poll %s: %v", pollIDStr, err)
+		log.Printf("Failed to upgrade websocket for poll %s: %v", inviteID, err)
 		return
 	}
 	defer conn.Close()
 
+	client := &wsClient{conn: conn}
+	if role := c.Query("role"); role == protocol.RolePresenter {
+		client.role = role
+	}
+	version, ok := protocol.NegotiateVersion(conn.Subprotocol(), websocket.Subprotocols(c.Request))
+	if !ok {
+		log.Printf("WebSocket client for poll %s offered unsupported protocols %v", inviteID, websocket.Subprotocols(c.Request))
+		client.send(protocol.NewError("", protocol.ErrUnsupportedVersion,
+			"Unsupported protocol version. Supported: "+strings.Join(protocol.Subprotocols(), ", ")))
+		return
+	}
+	client.protocolVersion = version
+
 	// Retrieve poll from DB
-	p, err := data.GetPollWithDetails(pollIDStr)
+	p, err := data.GetPollWithDetails(inviteID)
 	if err != nil {
-		log.Printf("WebSocket connection attempted for non-existent poll or DB error: %s, %v", pollIDStr, err)
-		conn.WriteJSON(WebSocketMessage{Type: "error", Message: "Poll does not exist or internal error."})
+		log.Printf("WebSocket connection attempted for non-existent poll or DB error: %s, %v", inviteID, err)
+		client.send(protocol.NewError("", protocol.ErrPollNotFound, "Poll does not exist or internal error."))
+		return
+	}
+	if client.role == protocol.RolePresenter && !validPresenterToken(p, c.Query("token")) {
+		log.Printf("Presenter connection to poll %s with an invalid token.", inviteID)
+		client.send(protocol.NewError("", protocol.ErrInvalidToken, "The presenter link is invalid or has been reset."))
 		return
 	}
+	if c.Query("role") == protocol.RoleAdmin {
+		// Only admins who may run the poll get the admin role, anyone else is a participant
+		if adminUser := sessionAdminUser(c); adminUser != nil && data.CanAccessPoll(p, adminUser, data.AccessRun) {
+			client.role = protocol.RoleAdmin
+			client.canControl = adminUser.CanEdit()
+		} else {
+			log.Printf("Admin connection to poll %s without access, connected as participant.", inviteID)
+		}
+	}
 
 	// Add connection to global map
 	globalMutex.Lock()
-	if _, ok := connections[pollIDStr]; !ok {
-		connections[pollIDStr] = make(map[*websocket.Conn]bool)
+	if _, ok := connections[inviteID]; !ok {
+		connections[inviteID] = make(map[*websocket.Conn]*wsClient)
 	}
-	connections[pollIDStr][conn] = true
+	connections[inviteID][conn] = client
 	globalMutex.Unlock()
 
-	log.Printf("Client connected to poll %s via WebSocket.", pollIDStr)
+	log.Printf("Client connected to poll %s via WebSocket (protocol v%d).", inviteID, version)
+
+	if err := client.send(protocol.WelcomeMessage{Type: protocol.TypeWelcome, ProtocolVersion: version, PollID: p.ID, InviteID: p.InviteID}); err != nil {
+		log.Printf("Error sending welcome to new client for poll %s: %v", inviteID, err)
+	}
 
 	// Send initial poll state to the newly connected client
 	p.Mu.Lock() // Lock the specific poll's mutex
 	initialStateMsg := getPollStateMessage(p)
+	if !client.seesLiveResults() {
+		initialStateMsg = participantPollState(p, initialStateMsg)
+	}
 	p.Mu.Unlock()
-	if err := conn.WriteJSON(initialStateMsg); err != nil {
-		log.Printf("Error sending initial state to new client for poll %s: %v", pollIDStr, err)
+	if err := client.send(initialStateMsg); err != nil {
+		log.Printf("Error sending initial state to new client for poll %s: %v", inviteID, err)
 	}
 
-	// If admin, send initial real-time results
-	if c.Request.URL.Query().Get("role") == "admin" {
+	// If admin or presenter, send initial real-time results
+	if client.seesLiveResults() {
 		p.Mu.Lock() // Lock the specific poll's mutex
 		adminResultsMsg := getAdminResultsUpdateMessage(p)
 		p.Mu.Unlock()
-		if err := conn.WriteJSON(adminResultsMsg); err != nil {
-			log.Printf("Error sending initial admin results to new client for poll %s: %v", pollIDStr, err)
+		if err := client.send(adminResultsMsg); err != nil {
+			log.Printf("Error sending initial admin results to new client for poll %s: %v", inviteID, err)
 		}
 	}
 
@@ -108,228 +139,320 @@ func handleWebSocket(c *gin.Context) {
 		_, message, err := conn.ReadMessage()
 		if err != nil {
 			if websocket.IsCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
-				log.Printf("Client disconnected from poll %s.", pollIDStr)
+				log.Printf("Client disconnected from poll %s.", inviteID)
 			} else {
-				log.Printf("Error reading message from websocket for poll %s: %v", pollIDStr, err)
+				log.Printf("Error reading message from websocket for poll %s: %v", inviteID, err)
 			}
 			break
 		}
 
-		var msg WebSocketMessage
-		if err := json.Unmarshal(message, &msg); err != nil {
-			log.Printf("Error unmarshalling message for poll %s: %v", pollIDStr, err)
+		msg, correlationID, decodeErr := protocol.DecodeClientMessage(message)
+		if decodeErr != nil {
+			log.Printf("Invalid message for poll %s: %s", inviteID, decodeErr.Message)
+			client.send(*decodeErr)
+			continue
+		}
+		if client.role == protocol.RolePresenter {
+			client.send(protocol.NewError(correlationID, protocol.ErrReadOnly, "The presenter view is read-only."))
 			continue
 		}
 
 		// Re-fetch the poll from DB to ensure we have the latest state before modifying
 		// This is important for concurrent access and data integrity.
-		p, _ := data.GetPollWithDetails(pollIDStr)
+		p, err := data.GetPollWithDetails(inviteID)
 		if err != nil {
-			log.Printf("Error re-fetching poll %s from DB during WebSocket message processing: %v", pollIDStr, err)
-			conn.WriteJSON(WebSocketMessage{Type: "error", Message: "Internal server error: poll data unavailable."})
+			log.Printf("Error re-fetching poll %s from DB during WebSocket message processing: %v", inviteID, err)
+			client.send(protocol.NewError(correlationID, protocol.ErrInternal, "Internal server error: poll data unavailable."))
 			continue
 		}
 
+		var reply interface{}
 		p.Mu.Lock() // Lock the specific poll for modifications
-		switch msg.Type {
-		case "submit_vote":
-			if p.Status != "active" {
-				log.Printf("Vote submitted for poll %s when not active. Status: %s", pollIDStr, p.Status)
-				conn.WriteJSON(WebSocketMessage{Type: "error", Message: "Voting is not currently active."})
-				p.Mu.Unlock()
-				continue
-			}
-			if p.CurrentQuestionIndex == -1 || p.CurrentQuestionIndex >= len(p.Questions) {
-				log.Printf("Vote submitted for poll %s with no active question.", pollIDStr)
-				conn.WriteJSON(WebSocketMessage{Type: "error", Message: "No active question to vote on."})
-				p.Mu.Unlock()
-				continue
+		switch m := msg.(type) {
+		case *protocol.SubmitVoteMessage:
+			reply = handleSubmitVote(inviteID, p, m)
+		case *protocol.AdminActionMessage:
+			if !client.canControl {
+				reply = protocol.NewError(correlationID, protocol.ErrActionNotAllowed, "Only admins who may run the poll can control it.")
+			} else if errMsg := handleAdminAction(inviteID, p, m); errMsg != nil {
+				reply = *errMsg
+			} else {
+				reply = protocol.NewAck(correlationID)
 			}
+		}
+		p.Mu.Unlock() // Unlock the specific poll after modifications
 
-			currentQ := &p.Questions[p.CurrentQuestionIndex]
-			// The client sends `questionId` as a string, convert it to uint for comparison.
-			clientQID, parseErr := strconv.ParseUint(msg.QuestionID, 10, 32)
-			if parseErr != nil || uint(clientQID) != currentQ.ID {
-				log.Printf("Vote submitted for wrong question ID. Expected GORM ID %d, got %s", currentQ.ID, msg.QuestionID)
-				conn.WriteJSON(WebSocketMessage{Type: "error", Message: "Invalid question for voting."})
-				p.Mu.Unlock()
-				continue
-			}
+		client.send(reply)
+	}
 
-			// Get or generate VoterID
-			voterID := msg.VoterID
-			if voterID == "" {
-				// If client doesn't provide a VoterID, generate a simple unique string for this vote.
-				// In a real application, you would manage user sessions or persistent IDs (e.g., via cookies).
-				generatedID, err := utils.RandString(16) // 16 bytes for a decent length
-				if err != nil {
-					log.Printf("Error generating voter ID: %v", err)
-					conn.WriteJSON(WebSocketMessage{Type: "error", Message: "Failed to establish voter session."})
-					p.Mu.Unlock()
-					return // Terminate connection if ID cannot be generated
-				}
-				voterID = generatedID
-				log.Printf("Generated temporary voter ID: %s", voterID)
-			}
+	// Clean up connection on disconnect
+	globalMutex.Lock()
+	delete(connections[inviteID], conn)
+	if len(connections[inviteID]) == 0 {
+		delete(connections, inviteID) // Clean up poll entry if no connections left
+	}
+	globalMutex.Unlock()
+}
 
-			// Validate selected options against the options stored in the current question
-			validOptionsMap := make(map[uint]bool) // Use uint for option IDs
-			for _, opt := range currentQ.Options {
-				validOptionsMap[opt.ID] = true
-			}
+// sessionAdminUser returns the logged in admin of the request, nil when there is none.
+func sessionAdminUser(c *gin.Context) *data.AdminUser {
+	if _, ok := c.Get(sessions.DefaultKey); !ok {
+		return nil
+	}
+	email, _ := sessions.Default(c).Get(pages.Userkey).(string)
+	if email == "" {
+		return nil
+	}
+	adminUser, err := data.GetAdminUserByEmail(email)
+	if err != nil || !adminUser.IsAdmin() {
+		return nil
+	}
+	return adminUser
+}
 
-			var selectedOptionIDs []uint
-			for _, selectedOptIDStr := range msg.SelectedOptions {
-				optID, err := strconv.ParseUint(selectedOptIDStr, 10, 32) // Parse to uint
-				if err != nil {
-					log.Printf("Invalid option ID format received: %s, error: %v", selectedOptIDStr, err)
-					conn.WriteJSON(WebSocketMessage{Type: "error", Message: fmt.Sprintf("Invalid option ID format: %s", selectedOptIDStr)})
-					p.Mu.Unlock()
-					continue
-				}
-				if !validOptionsMap[uint(optID)] {
-					log.Printf("Invalid option ID submitted: %s", selectedOptIDStr)
-					conn.WriteJSON(WebSocketMessage{Type: "error", Message: fmt.Sprintf("Invalid option ID: %s", selectedOptIDStr)})
-					p.Mu.Unlock()
-					continue
-				}
-				selectedOptionIDs = append(selectedOptionIDs, uint(optID))
-			}
+// validPresenterToken reports whether token is the presenter token of the poll.
+func validPresenterToken(p *data.Poll, token string) bool {
+	return p.PresenterToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(p.PresenterToken)) == 1
+}
 
-			if currentQ.Type == "single-select" {
-				if len(selectedOptionIDs) > 1 {
-					log.Printf("Multiple options selected for single-select question.")
-					conn.WriteJSON(WebSocketMessage{Type: "error", Message: "Please select only one option for this question."})
-					p.Mu.Unlock()
-					return
-				}
-				// For single-select, remove previous votes by this voter for this question
-				if result := data.DB.Where("question_id = ? AND voter_id = ?", currentQ.ID, voterID).Delete(&data.Vote{}); result.Error != nil {
-					log.Printf("Error deleting previous votes for single-select: %v", result.Error)
-					conn.WriteJSON(WebSocketMessage{Type: "error", Message: "Failed to update vote."})
-					p.Mu.Unlock()
-					return
-				}
-			}
+// handleSubmitVote stores the votes of a participant for the current question and returns the
+// vote_accepted or vote_rejected reply for the voter. The caller holds p.Mu.
+func handleSubmitVote(inviteID string, p *data.Poll, msg *protocol.SubmitVoteMessage) interface{} {
+	reject := func(code protocol.ErrorCode, message string) protocol.VoteRejectedMessage {
+		return protocol.VoteRejectedMessage{
+			Type:           protocol.TypeVoteRejected,
+			CorrelationID:  msg.CorrelationID,
+			IdempotencyKey: msg.IdempotencyKey,
+			QuestionID:     msg.QuestionID,
+			Code:           code,
+			Message:        message,
+		}
+	}
+
+	if len(msg.IdempotencyKey) > data.MaxIdempotencyKeyLength {
+		return reject(protocol.ErrInvalidMessage, fmt.Sprintf("Idempotency key must be at most %d characters.", data.MaxIdempotencyKeyLength))
+	}
+	if p.Status != "active" {
+		log.Printf("Vote submitted for poll %s when not active. Status: %s", inviteID, p.Status)
+		return reject(protocol.ErrVotingClosed, "Voting is not currently active.")
+	}
+	if p.CurrentQuestionIndex == -1 || p.CurrentQuestionIndex >= len(p.Questions) {
+		log.Printf("Vote submitted for poll %s with no active question.", inviteID)
+		return reject(protocol.ErrNoActiveQuestion, "No active question to vote on.")
+	}
+
+	currentQ := &p.Questions[p.CurrentQuestionIndex]
+	if msg.QuestionID != currentQ.ID {
+		log.Printf("Vote submitted for wrong question ID. Expected GORM ID %d, got %d", currentQ.ID, msg.QuestionID)
+		return reject(protocol.ErrWrongQuestion, "Invalid question for voting.")
+	}
+
+	// Get or generate VoterID
+	voterID := msg.VoterID
+	if voterID == "" && msg.IdempotencyKey != "" {
+		// A retry of a first vote whose vote_accepted was lost: use the voter ID generated for it
+		storedID, err := data.SubmissionVoterID(currentQ.ID, msg.IdempotencyKey)
+		if err != nil {
+			log.Printf("Error looking up vote submission: %v", err)
+			return reject(protocol.ErrInternal, "Failed to establish voter session.")
+		}
+		voterID = storedID
+	}
+	if voterID == "" {
+		// If client doesn't provide a VoterID, generate one. It is sent back in vote_accepted
+		// so the client can use it for its next votes.
+		generatedID, err := utils.RandString(16) // 16 bytes for a decent length
+		if err != nil {
+			log.Printf("Error generating voter ID: %v", err)
+			return reject(protocol.ErrInternal, "Failed to establish voter session.")
+		}
+		voterID = generatedID
+		log.Printf("Generated temporary voter ID: %s", voterID)
+	} else if len(voterID) > data.MaxVoterIDLength {
+		return reject(protocol.ErrInvalidMessage, fmt.Sprintf("Voter ID must be at most %d characters.", data.MaxVoterIDLength))
+	}
+
+	// Validate selected options against the options stored in the current question
+	validOptionsMap := make(map[uint]bool)
+	for _, opt := range currentQ.Options {
+		validOptionsMap[opt.ID] = true
+	}
+	if len(msg.OptionIDs) == 0 {
+		return reject(protocol.ErrInvalidOption, "Please select at least one option.")
+	}
+	for _, optID := range msg.OptionIDs {
+		if !validOptionsMap[optID] {
+			log.Printf("Invalid option ID submitted: %d", optID)
+			return reject(protocol.ErrInvalidOption, fmt.Sprintf("Invalid option ID: %d", optID))
+		}
+	}
 
-			// Create new vote records
-			for _, selectedOptID := range selectedOptionIDs {
-				newVote := data.Vote{
-					QuestionID: currentQ.ID,
-					OptionID:   selectedOptID,
-					VoterID:    voterID,
-				}
-				if result := data.DB.Create(&newVote); result.Error != nil {
-					log.Printf("Error saving vote to DB: %v", result.Error)
-					conn.WriteJSON(WebSocketMessage{Type: "error", Message: "Failed to save vote."})
-					p.Mu.Unlock()
-					return
-				}
+	singleSelect := currentQ.Type == "single-select"
+	if singleSelect && len(msg.OptionIDs) > 1 {
+		log.Printf("Multiple options selected for single-select question.")
+		return reject(protocol.ErrTooManyOptions, "Please select only one option for this question.")
+	}
+
+	// Votes are tagged with the current run so results can be reported per run
+	var runID uint
+	if run, err := data.GetActivePollRun(p.ID); err != nil {
+		log.Printf("Error looking up active run for poll %s: %v", inviteID, err)
+	} else if run != nil {
+		runID = run.ID
+	}
+
+	// For single-select, the voter's previous vote for this question is replaced
+	duplicate, err := data.RecordVote(currentQ.ID, voterID, msg.OptionIDs, singleSelect, runID, msg.IdempotencyKey)
+	if err != nil {
+		log.Printf("Error saving vote to DB: %v", err)
+		return reject(protocol.ErrVoteFailed, "Failed to save vote.")
+	}
+	if duplicate {
+		log.Printf("Duplicate vote for poll %s, question %d by voter %s (key %s)", inviteID, currentQ.ID, voterID, msg.IdempotencyKey)
+	} else {
+		log.Printf("Vote(s) received for poll %s, question %d by voter %s", inviteID, currentQ.ID, voterID)
+		// Notify admin of real-time vote update
+		broadcastResultsUpdate(inviteID, p)
+	}
+
+	selection, err := data.GetVoterSelection(currentQ.ID, voterID)
+	if err != nil {
+		log.Printf("Error getting selection for voter %s: %v", voterID, err)
+		selection = msg.OptionIDs
+	}
+	return protocol.VoteAcceptedMessage{
+		Type:           protocol.TypeVoteAccepted,
+		CorrelationID:  msg.CorrelationID,
+		IdempotencyKey: msg.IdempotencyKey,
+		QuestionID:     currentQ.ID,
+		VoterID:        voterID,
+		OptionIDs:      selection,
+		Duplicate:      duplicate,
+	}
+}
+
+// handleAdminAction moves the poll to another state and broadcasts the new state.
+// The caller holds p.Mu.
+func handleAdminAction(inviteID string, p *data.Poll, msg *protocol.AdminActionMessage) *protocol.ErrorMessage {
+	reject := func(code protocol.ErrorCode, message string) *protocol.ErrorMessage {
+		e := protocol.NewError(msg.CorrelationID, code, message)
+		return &e
+	}
+	save := func(failMessage string) *protocol.ErrorMessage {
+		if result := data.DB.Save(p); result.Error != nil { // Save poll status and index
+			log.Printf("Error saving poll status to DB: %v", result.Error)
+			return reject(protocol.ErrActionFailed, failMessage)
+		}
+		return nil
+	}
+	finishRun := func() {
+		if err := data.FinishPollRun(p.ID); err != nil {
+			log.Printf("Error recording run finish for poll %s: %v", inviteID, err)
+		}
+		if err := data.ReleaseJoinPIN(p); err != nil { // So other polls can get the PIN
+			log.Printf("Error releasing join PIN of poll %s: %v", inviteID, err)
+		}
+	}
+
+	log.Printf("Admin action received for poll %s: %s", inviteID, msg.Action)
+	switch msg.Action {
+	case protocol.ActionStart:
+		if p.Status != "setup" || len(p.Questions) == 0 {
+			log.Printf("Admin tried to start poll %s, but status is %s or no questions.", inviteID, p.Status)
+			return reject(protocol.ErrActionNotAllowed, "Cannot start poll. Ensure questions are added and poll is in 'setup' status.")
+		}
+		p.CurrentQuestionIndex = 0
+		p.Status = "active"
+		log.Printf("Admin started poll %s. Moving to question %d.", inviteID, p.CurrentQuestionIndex+1)
+		if errMsg := save("Failed to start poll."); errMsg != nil {
+			return errMsg
+		}
+		if _, err := data.StartPollRun(p.ID); err != nil {
+			log.Printf("Error recording run start for poll %s: %v", inviteID, err)
+		}
+		if err := data.AllocateJoinPIN(p); err != nil {
+			log.Printf("Error allocating join PIN for poll %s: %v", inviteID, err) // The invite ID still works
+		}
+		broadcastPollState(inviteID, p)
+		broadcastResultsUpdate(inviteID, p) // Send initial results to admin
+		webhooks.Dispatch(webhooks.EventPollStarted, p, nil)
+	case protocol.ActionNext:
+		if p.Status != "active" && p.Status != "results" {
+			log.Printf("Admin tried to move poll %s to next, but status is %s.", inviteID, p.Status)
+			return reject(protocol.ErrActionNotAllowed, "Cannot move to next question. Poll is not active or in results mode.")
+		}
+		p.CurrentQuestionIndex++
+		if p.CurrentQuestionIndex < len(p.Questions) {
+			p.Status = "active" // Move to next question, set status back to active
+			log.Printf("Admin moved poll %s to next question %d.", inviteID, p.CurrentQuestionIndex+1)
+			if errMsg := save("Failed to move to next question."); errMsg != nil {
+				return errMsg
 			}
-			log.Printf("Vote(s) received for poll %s, question %d by voter %s", pollIDStr, currentQ.ID, voterID)
-
-			// Notify admin of real-time vote update
-			broadcastMessage(pollIDStr, getAdminResultsUpdateMessage(p))
-
-		case "admin_action":
-			log.Printf("Admin action received for poll %s: %s", pollIDStr, msg.Action)
-			switch msg.Action {
-			case "start":
-				if p.Status == "setup" && len(p.Questions) > 0 {
-					p.CurrentQuestionIndex = 0
-					p.Status = "active"
-					log.Printf("Admin started poll %s. Moving to question %d.", pollIDStr, p.CurrentQuestionIndex+1)
-					if result := data.DB.Save(p); result.Error != nil { // Save poll status and index
-						log.Printf("Error saving poll status to DB: %v", result.Error)
-						conn.WriteJSON(WebSocketMessage{Type: "error", Message: "Failed to start poll."})
-						p.Mu.Unlock()
-						continue
-					}
-					broadcastMessage(pollIDStr, getPollStateMessage(p))
-					broadcastMessage(pollIDStr, getAdminResultsUpdateMessage(p)) // Send initial results to admin
-				} else {
-					log.Printf("Admin tried to start poll %s, but status is %s or no questions.", pollIDStr, p.Status)
-					conn.WriteJSON(WebSocketMessage{Type: "error", Message: "Cannot start poll. Ensure questions are added and poll is in 'setup' status."})
-				}
-			case "next":
-				if p.Status == "active" || p.Status == "results" {
-					p.CurrentQuestionIndex++
-					if p.CurrentQuestionIndex < len(p.Questions) {
-						p.Status = "active" // Move to next question, set status back to active
-						log.Printf("Admin moved poll %s to next question %d.", pollIDStr, p.CurrentQuestionIndex+1)
-						if result := data.DB.Save(p); result.Error != nil { // Save poll status and index
-							log.Printf("Error saving poll status to DB: %v", result.Error)
-							conn.WriteJSON(WebSocketMessage{Type: "error", Message: "Failed to move to next question."})
-							p.Mu.Unlock()
-							continue
-						}
-						broadcastMessage(pollIDStr, getPollStateMessage(p))
-						broadcastMessage(pollIDStr, getAdminResultsUpdateMessage(p)) // Reset admin results for new question
-					} else {
-						p.Status = "finished"
-						log.Printf("Admin finished poll %s. All questions answered.", pollIDStr)
-						if result := data.DB.Save(p); result.Error != nil { // Save poll status
-							log.Printf("Error saving poll status to DB: %v", result.Error)
-							conn.WriteJSON(WebSocketMessage{Type: "error", Message: "Failed to finish poll."})
-							p.Mu.Unlock()
-							continue
-						}
-						broadcastMessage(pollIDStr, getPollStateMessage(p))
-					}
-				} else {
-					log.Printf("Admin tried to move poll %s to next, but status is %s.", pollIDStr, p.Status)
-					conn.WriteJSON(WebSocketMessage{Type: "error", Message: "Cannot move to next question. Poll is not active or in results mode."})
-				}
-			case "show_results":
-				if p.Status == "active" {
-					p.Status = "results"
-					log.Printf("Admin showed results for poll %s, question %d.", pollIDStr, p.CurrentQuestionIndex+1)
-					if result := data.DB.Save(p); result.Error != nil { // Save poll status
-						log.Printf("Error saving poll status to DB: %v", result.Error)
-						conn.WriteJSON(WebSocketMessage{Type: "error", Message: "Failed to show results."})
-						p.Mu.Unlock()
-						continue
-					}
-					broadcastMessage(pollIDStr, getPollStateMessage(p))
-				} else {
-					log.Printf("Admin tried to show results for poll %s, but status is %s.", pollIDStr, p.Status)
-					conn.WriteJSON(WebSocketMessage{Type: "error", Message: "Cannot show results. Poll is not active."})
-				}
-			case "done": // This action signifies the end of the entire poll
-				p.Status = "finished"
-				log.Printf("Admin marked poll %s as done. Final results displayed.", pollIDStr)
-				if result := data.DB.Save(p); result.Error != nil { // Save poll status
-					log.Printf("Error saving poll status to DB: %v", result.Error)
-					conn.WriteJSON(WebSocketMessage{Type: "error", Message: "Failed to mark poll as done."})
-					p.Mu.Unlock()
-					continue
-				}
-				broadcastMessage(pollIDStr, getPollStateMessage(p))
-			default:
-				log.Printf("Unknown admin action: %s", msg.Action)
-				conn.WriteJSON(WebSocketMessage{Type: "error", Message: "Unknown admin action."})
+			broadcastPollState(inviteID, p)
+			broadcastResultsUpdate(inviteID, p) // Reset admin results for new question
+			webhooks.Dispatch(webhooks.EventQuestionChanged, p, nil)
+		} else {
+			p.Status = "finished"
+			log.Printf("Admin finished poll %s. All questions answered.", inviteID)
+			if errMsg := save("Failed to finish poll."); errMsg != nil {
+				return errMsg
 			}
-		default:
-			log.Printf("Unknown message type received for poll %s: %s", pollIDStr, msg.Type)
-			conn.WriteJSON(WebSocketMessage{Type: "error", Message: "Unknown message type."})
+			finishRun()
+			broadcastPollState(inviteID, p)
+			webhooks.Dispatch(webhooks.EventPollFinished, p, nil)
 		}
-		p.Mu.Unlock() // Unlock the specific poll after modifications
+	case protocol.ActionShowResults:
+		if p.Status != "active" {
+			log.Printf("Admin tried to show results for poll %s, but status is %s.", inviteID, p.Status)
+			return reject(protocol.ErrActionNotAllowed, "Cannot show results. Poll is not active.")
+		}
+		p.Status = "results"
+		log.Printf("Admin showed results for poll %s, question %d.", inviteID, p.CurrentQuestionIndex+1)
+		if errMsg := save("Failed to show results."); errMsg != nil {
+			return errMsg
+		}
+		if err := p.MarkResultsShown(p.CurrentQuestionIndex); err != nil {
+			log.Printf("Error recording shown results for poll %s: %v", inviteID, err)
+		}
+		broadcastPollState(inviteID, p)
+		webhooks.Dispatch(webhooks.EventResultsShown, p, p.Questions[p.CurrentQuestionIndex].Votes)
+	case protocol.ActionDone: // This action signifies the end of the entire poll
+		p.Status = "finished"
+		log.Printf("Admin marked poll %s as done. Final results displayed.", inviteID)
+		if errMsg := save("Failed to mark poll as done."); errMsg != nil {
+			return errMsg
+		}
+		finishRun()
+		broadcastPollState(inviteID, p)
+		webhooks.Dispatch(webhooks.EventPollFinished, p, nil)
+	default:
+		log.Printf("Unknown admin action: %s", msg.Action)
+		return reject(protocol.ErrUnknownAction, "Unknown admin action.")
 	}
+	return nil
+}
 
-	// Clean up connection on disconnect
-	globalMutex.Lock()
-	delete(connections[pollIDStr], conn)
-	if len(connections[pollIDStr]) == 0 {
-		delete(connections, pollIDStr) // Clean up poll entry if no connections left
+// toProtocolQuestion converts a question for sending over the WebSocket, with or without its votes.
+func toProtocolQuestion(q *data.Question, withVotes bool) protocol.Question {
+	result := protocol.Question{ID: q.ID, Text: q.Text, Type: q.Type, Options: make([]protocol.Option, 0, len(q.Options))}
+	for _, opt := range q.Options {
+		result.Options = append(result.Options, protocol.Option{ID: opt.ID, Text: opt.Text})
 	}
-	globalMutex.Unlock()
+	if withVotes {
+		result.Votes = q.Votes
+	}
+	return result
 }
 
-func getPollStateMessage(p *data.Poll) WebSocketMessage {
-	msg := WebSocketMessage{
-		Type:   "poll_state_update",
-		PollID: fmt.Sprintf("%d", p.ID), // Convert uint ID to string for WebSocketMessage
-		Status: p.Status,
+func getPollStateMessage(p *data.Poll) protocol.PollStateMessage {
+	msg := protocol.PollStateMessage{
+		Type:     protocol.TypePollState,
+		PollID:   p.ID,
+		InviteID: p.InviteID,
+		Status:   p.Status,
+	}
+	if p.JoinPIN != nil {
+		msg.JoinPIN = *p.JoinPIN
 	}
 
 	// Load votes for all questions if status is results or finished, or for current question if active
@@ -344,7 +467,8 @@ func getPollStateMessage(p *data.Poll) WebSocketMessage {
 			} else {
 				currentQ.Votes = votes
 			}
-			msg.CurrentQuestion = currentQ
+			question := toProtocolQuestion(currentQ, true)
+			msg.CurrentQuestion = &question
 		} else {
 			log.Printf("DEBUG Go: CurrentQuestionIndex out of bounds for poll %d. Index: %d, Questions count: %d",
 				p.ID, p.CurrentQuestionIndex, len(p.Questions))
@@ -352,8 +476,7 @@ func getPollStateMessage(p *data.Poll) WebSocketMessage {
 	}
 
 	if p.Status == "results" || p.Status == "finished" {
-		allResults := make(map[string]map[string]int)
-		var allQuestionsForMsg []data.Question
+		var allQuestionsForMsg []protocol.Question
 		for i := range p.Questions { // Iterate by index to get mutable question
 			q := &p.Questions[i]
 			votes, err := getVotesForQuestion(q.ID)
@@ -363,15 +486,31 @@ func getPollStateMessage(p *data.Poll) WebSocketMessage {
 			} else {
 				q.Votes = votes // Populate the transient Votes map
 			}
+			allQuestionsForMsg = append(allQuestionsForMsg, toProtocolQuestion(q, true))
+		}
+		msg.AllQuestions = allQuestionsForMsg
+	}
+	return msg
+}
 
-			allResults[fmt.Sprintf("%d", q.ID)] = make(map[string]int)
-			for optionID, count := range q.Votes {
-				allResults[fmt.Sprintf("%d", q.ID)][optionID] = count
+// participantPollState removes the votes participants may not see from a poll_state message.
+func participantPollState(p *data.Poll, full protocol.PollStateMessage) protocol.PollStateMessage {
+	msg := full
+	if full.CurrentQuestion != nil {
+		question := *full.CurrentQuestion
+		if !p.ParticipantsSeeVotes(p.CurrentQuestionIndex) {
+			question.Votes = nil
+		}
+		msg.CurrentQuestion = &question
+	}
+	if full.AllQuestions != nil {
+		msg.AllQuestions = make([]protocol.Question, len(full.AllQuestions))
+		for i, question := range full.AllQuestions { // In the same order as p.Questions
+			if !p.ParticipantsSeeVotes(i) {
+				question.Votes = nil
 			}
-			allQuestionsForMsg = append(allQuestionsForMsg, *q) // Append a copy
+			msg.AllQuestions[i] = question
 		}
-		msg.Results = allResults
-		msg.AllQuestions = allQuestionsForMsg
 	}
 	return msg
 }
@@ -405,10 +544,11 @@ func getVotesForQuestion(questionID uint) (map[string]int, error) {
 	return voteCounts, nil
 }
 
-func getAdminResultsUpdateMessage(p *data.Poll) WebSocketMessage {
-	msg := WebSocketMessage{
-		Type:   "admin_results_update",
-		PollID: fmt.Sprintf("%d", p.ID),
+func getAdminResultsUpdateMessage(p *data.Poll) protocol.ResultsUpdateMessage {
+	msg := protocol.ResultsUpdateMessage{
+		Type:   protocol.TypeResultsUpdate,
+		PollID: p.ID,
+		Votes:  map[string]int{},
 	}
 
 	if p.CurrentQuestionIndex >= 0 && p.CurrentQuestionIndex < len(p.Questions) {
@@ -420,8 +560,10 @@ func getAdminResultsUpdateMessage(p *data.Poll) WebSocketMessage {
 			currentQ.Votes = votes
 		}
 
-		msg.QuestionID = fmt.Sprintf("%d", currentQ.ID)
-		msg.Votes = currentQ.Votes
+		msg.QuestionID = currentQ.ID
+		if currentQ.Votes != nil {
+			msg.Votes = currentQ.Votes
+		}
 		total := 0
 		for _, count := range currentQ.Votes {
 			total += count
@@ -431,27 +573,68 @@ func getAdminResultsUpdateMessage(p *data.Poll) WebSocketMessage {
 	return msg
 }
 
-func broadcastMessage(pollID string, msg WebSocketMessage) {
+// broadcastPollState sends the poll state to every client of the poll. Participants only get
+// the votes the result visibility settings allow.
+func broadcastPollState(inviteID string, p *data.Poll) {
+	msg := getPollStateMessage(p)
+	broadcastMessage(inviteID, msg, participantPollState(p, msg))
+}
+
+// broadcastResultsUpdate sends the votes of the current question to admins and presenters, and
+// to participants when the question shows its results live.
+func broadcastResultsUpdate(inviteID string, p *data.Poll) {
+	msg := getAdminResultsUpdateMessage(p)
+	var participantMsg interface{}
+	if p.ParticipantsSeeVotes(p.CurrentQuestionIndex) {
+		participantMsg = msg
+	}
+	broadcastMessage(inviteID, msg, participantMsg)
+}
+
+// broadcastMessage sends msg to the admins and presenters of the poll, and participantMsg to its
+// participants. Nil messages are not sent.
+func broadcastMessage(inviteID string, msg, participantMsg interface{}) {
 	globalMutex.Lock()
 	defer globalMutex.Unlock()
 
-	conns, ok := connections[pollID]
+	clients, ok := connections[inviteID]
 	if !ok {
-		log.Printf("No connections found for poll ID: %s", pollID)
+		log.Printf("No connections found for poll ID: %s", inviteID)
 		return
 	}
 
-	messageBytes, err := json.Marshal(msg)
+	marshal := func(m interface{}) ([]byte, error) {
+		if m == nil {
+			return nil, nil
+		}
+		return json.Marshal(m)
+	}
+	messageBytes, err := marshal(msg)
+	if err != nil {
+		log.Printf("Error marshalling message: %v", err)
+		return
+	}
+	participantBytes, err := marshal(participantMsg)
 	if err != nil {
 		log.Printf("Error marshalling message: %v", err)
 		return
 	}
 
-	for conn := range conns {
-		if err := conn.WriteMessage(websocket.TextMessage, messageBytes); err != nil {
-			log.Printf("Error writing message to websocket for poll %s: %v", pollID, err)
+	for conn, client := range clients {
+		payload := participantBytes
+		if client.seesLiveResults() {
+			payload = messageBytes
+		}
+		if payload == nil {
+			continue
+		}
+		client.mu.Lock()
+		err := conn.WriteMessage(websocket.TextMessage, payload)
+		client.mu.Unlock()
+		if err != nil {
+			log.Printf("Error writing message to websocket for poll %s: %v", inviteID, err)
 			conn.Close()
-			delete(conns, conn) // Remove broken connection
+			delete(clients, conn) // Remove broken connection
 		}
 	}
 }
@@ -0,0 +1,387 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aspcodenet/systementorlivepolls/data"
+	"github.com/aspcodenet/systementorlivepolls/pages"
+	"github.com/aspcodenet/systementorlivepolls/protocol"
+	"github.com/aspcodenet/systementorlivepolls/webhooks"
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-contrib/sessions/cookie"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+func createActivePoll(t *testing.T) *data.Poll {
+	t.Helper()
+	poll := &data.Poll{
+		Title:                "Live poll",
+		Status:               "active",
+		InviteID:             "invite",
+		CurrentQuestionIndex: 0,
+		Questions: []data.Question{
+			{Text: "Pick one", Type: "single-select", Options: []data.Option{{Text: "A"}, {Text: "B"}}},
+		},
+	}
+	if err := data.DB.Create(poll).Error; err != nil {
+		t.Fatalf("failed to create poll: %v", err)
+	}
+	return poll
+}
+
+func TestHandleSubmitVote_AcceptsAndDeduplicates(t *testing.T) {
+	setupTestDB(t)
+	poll := createActivePoll(t)
+	q := poll.Questions[0]
+	a, b := q.Options[0].ID, q.Options[1].ID
+
+	vote := &protocol.SubmitVoteMessage{Type: protocol.TypeSubmitVote, CorrelationID: "c1", IdempotencyKey: "k1", QuestionID: q.ID, OptionIDs: []uint{a}}
+	accepted, ok := handleSubmitVote("invite", poll, vote).(protocol.VoteAcceptedMessage)
+	if !assert.True(t, ok, "vote should be accepted") {
+		return
+	}
+	assert.Equal(t, "c1", accepted.CorrelationID)
+	assert.Equal(t, "k1", accepted.IdempotencyKey)
+	assert.NotEmpty(t, accepted.VoterID, "a voter ID should be generated")
+	assert.Equal(t, []uint{a}, accepted.OptionIDs)
+	assert.False(t, accepted.Duplicate)
+
+	// A retry of the same vote is accepted again but not counted
+	vote.VoterID = accepted.VoterID
+	retried := handleSubmitVote("invite", poll, vote).(protocol.VoteAcceptedMessage)
+	assert.True(t, retried.Duplicate)
+	assert.Equal(t, []uint{a}, retried.OptionIDs)
+
+	// Changing the answer echoes the new selection
+	changed := handleSubmitVote("invite", poll, &protocol.SubmitVoteMessage{IdempotencyKey: "k2", QuestionID: q.ID, OptionIDs: []uint{b}, VoterID: accepted.VoterID}).(protocol.VoteAcceptedMessage)
+	assert.False(t, changed.Duplicate)
+	assert.Equal(t, []uint{b}, changed.OptionIDs)
+
+	var count int64
+	data.DB.Model(&data.Vote{}).Where("question_id = ?", q.ID).Count(&count)
+	assert.Equal(t, int64(1), count)
+}
+
+func TestHandleSubmitVote_DeduplicatesRetriedFirstVote(t *testing.T) {
+	setupTestDB(t)
+	poll := createActivePoll(t)
+	q := poll.Questions[0]
+
+	// The client retries its first vote before vote_accepted told it its voter ID
+	vote := &protocol.SubmitVoteMessage{Type: protocol.TypeSubmitVote, CorrelationID: "c1", IdempotencyKey: "k1", QuestionID: q.ID, OptionIDs: []uint{q.Options[0].ID}}
+	first := handleSubmitVote("invite", poll, vote).(protocol.VoteAcceptedMessage)
+	retried := handleSubmitVote("invite", poll, vote).(protocol.VoteAcceptedMessage)
+	assert.False(t, first.Duplicate)
+	assert.True(t, retried.Duplicate)
+	assert.Equal(t, first.VoterID, retried.VoterID)
+
+	// Another participant's first vote gets its own voter ID
+	other := handleSubmitVote("invite", poll, &protocol.SubmitVoteMessage{IdempotencyKey: "k2", QuestionID: q.ID, OptionIDs: []uint{q.Options[1].ID}}).(protocol.VoteAcceptedMessage)
+	assert.False(t, other.Duplicate)
+	assert.NotEqual(t, first.VoterID, other.VoterID)
+
+	var count int64
+	data.DB.Model(&data.Vote{}).Where("question_id = ?", q.ID).Count(&count)
+	assert.Equal(t, int64(2), count)
+}
+
+func TestHandleSubmitVote_Rejects(t *testing.T) {
+	setupTestDB(t)
+	poll := createActivePoll(t)
+	q := poll.Questions[0]
+
+	cases := []struct {
+		name string
+		msg  protocol.SubmitVoteMessage
+		code protocol.ErrorCode
+	}{
+		{"wrong question", protocol.SubmitVoteMessage{QuestionID: q.ID + 100, OptionIDs: []uint{q.Options[0].ID}}, protocol.ErrWrongQuestion},
+		{"unknown option", protocol.SubmitVoteMessage{QuestionID: q.ID, OptionIDs: []uint{9999}}, protocol.ErrInvalidOption},
+		{"no option", protocol.SubmitVoteMessage{QuestionID: q.ID}, protocol.ErrInvalidOption},
+		{"two options", protocol.SubmitVoteMessage{QuestionID: q.ID, OptionIDs: []uint{q.Options[0].ID, q.Options[1].ID}}, protocol.ErrTooManyOptions},
+	}
+	for _, tc := range cases {
+		tc.msg.CorrelationID = tc.name
+		tc.msg.IdempotencyKey = tc.name
+		rejected, ok := handleSubmitVote("invite", poll, &tc.msg).(protocol.VoteRejectedMessage)
+		if assert.True(t, ok, tc.name) {
+			assert.Equal(t, tc.code, rejected.Code, tc.name)
+			assert.Equal(t, tc.name, rejected.CorrelationID, tc.name)
+			assert.Equal(t, tc.name, rejected.IdempotencyKey, tc.name)
+		}
+	}
+
+	poll.Status = "results"
+	rejected := handleSubmitVote("invite", poll, &protocol.SubmitVoteMessage{QuestionID: q.ID, OptionIDs: []uint{q.Options[0].ID}}).(protocol.VoteRejectedMessage)
+	assert.Equal(t, protocol.ErrVotingClosed, rejected.Code)
+}
+
+func TestHandleAdminAction_FiresWebhooks(t *testing.T) {
+	setupTestDB(t)
+	var mu sync.Mutex
+	var events []string
+	webhooks.AllowPrivateAddresses = true // The receiver is on 127.0.0.1
+	t.Cleanup(func() { webhooks.AllowPrivateAddresses = false })
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		events = append(events, r.Header.Get(webhooks.HeaderEvent))
+		mu.Unlock()
+	}))
+	defer receiver.Close()
+
+	owner := createWebSocketAdmin(t, "owner@example.com", data.RoleAdmin)
+	poll := createActivePoll(t)
+	poll.Status = "setup"
+	poll.CurrentQuestionIndex = -1
+	poll.AdminUserID = int(owner.ID)
+	_, err := data.CreateWebhook(owner.ID, poll.ID, receiver.URL, nil)
+	assert.NoError(t, err)
+
+	for _, action := range []string{protocol.ActionStart, protocol.ActionShowResults, protocol.ActionNext} {
+		errMsg := handleAdminAction("invite", poll, &protocol.AdminActionMessage{Type: protocol.TypeAdminAction, Action: action})
+		assert.Nil(t, errMsg, action)
+		webhooks.Wait()
+	}
+	assert.Equal(t, []string{webhooks.EventPollStarted, webhooks.EventResultsShown, webhooks.EventPollFinished}, events)
+}
+
+func TestHandleAdminAction_JoinPIN(t *testing.T) {
+	setupTestDB(t)
+	poll := createActivePoll(t)
+	poll.Status = "setup"
+	poll.CurrentQuestionIndex = -1
+
+	assert.Nil(t, handleAdminAction("invite", poll, &protocol.AdminActionMessage{Type: protocol.TypeAdminAction, Action: protocol.ActionStart}))
+	if assert.NotNil(t, poll.JoinPIN, "starting a poll should allocate a join PIN") {
+		assert.True(t, data.IsJoinPIN(*poll.JoinPIN))
+		assert.Equal(t, *poll.JoinPIN, getPollStateMessage(poll).JoinPIN)
+	}
+
+	assert.Nil(t, handleAdminAction("invite", poll, &protocol.AdminActionMessage{Type: protocol.TypeAdminAction, Action: protocol.ActionDone}))
+	webhooks.Wait()
+	var saved data.Poll
+	data.DB.First(&saved, poll.ID)
+	assert.Nil(t, saved.JoinPIN, "finishing a poll should release its join PIN")
+	assert.Nil(t, poll.JoinPIN)
+	assert.Empty(t, getPollStateMessage(poll).JoinPIN)
+
+	// Moving past the last question finishes the poll too
+	poll.Status, poll.CurrentQuestionIndex = "setup", -1
+	assert.Nil(t, handleAdminAction("invite", poll, &protocol.AdminActionMessage{Type: protocol.TypeAdminAction, Action: protocol.ActionStart}))
+	assert.NotNil(t, poll.JoinPIN)
+	assert.Nil(t, handleAdminAction("invite", poll, &protocol.AdminActionMessage{Type: protocol.TypeAdminAction, Action: protocol.ActionNext}))
+	webhooks.Wait()
+	assert.Equal(t, "finished", poll.Status)
+	saved = data.Poll{}
+	data.DB.First(&saved, poll.ID)
+	assert.Nil(t, saved.JoinPIN, "finishing a poll with next should release its join PIN")
+}
+
+// newWebSocketRouter serves the poll WebSocket to requests with email logged in, "" for nobody.
+func newWebSocketRouter(email string) *gin.Engine {
+	router := gin.New()
+	router.Use(sessions.Sessions("mysession", cookie.NewStore([]byte("test_secret"))))
+	router.Use(func(c *gin.Context) {
+		if email != "" {
+			sessions.Default(c).Set(pages.Userkey, email)
+		}
+		c.Next()
+	})
+	router.GET("/ws/:inviteID", handleWebSocket)
+	return router
+}
+
+func createWebSocketAdmin(t *testing.T, email, role string) *data.AdminUser {
+	t.Helper()
+	adminUser := &data.AdminUser{Email: email, Active: true, Role: role}
+	if err := data.DB.Create(adminUser).Error; err != nil {
+		t.Fatalf("failed to create admin user: %v", err)
+	}
+	return adminUser
+}
+
+// dialPoll connects to the poll WebSocket of a test server with the given query string.
+func dialPoll(t *testing.T, server *httptest.Server, inviteID, query string) *websocket.Conn {
+	t.Helper()
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws/" + inviteID + query
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("failed to connect to %s: %v", url, err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func readMessage(t *testing.T, conn *websocket.Conn) map[string]interface{} {
+	t.Helper()
+	var msg map[string]interface{}
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatalf("failed to read message: %v", err)
+	}
+	return msg
+}
+
+func TestHandleWebSocket_Presenter(t *testing.T) {
+	setupTestDB(t)
+	poll := createActivePoll(t)
+	assert.NoError(t, data.ResetPresenterToken(poll))
+	router := gin.New()
+	router.GET("/ws/:inviteID", handleWebSocket)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	conn := dialPoll(t, server, "invite", "?role=presenter&token=wrong")
+	msg := readMessage(t, conn)
+	assert.Equal(t, protocol.TypeError, msg["type"])
+	assert.Equal(t, string(protocol.ErrInvalidToken), msg["code"])
+
+	conn = dialPoll(t, server, "invite", "?role=presenter&token="+poll.PresenterToken)
+	for _, want := range []string{protocol.TypeWelcome, protocol.TypePollState, protocol.TypeResultsUpdate} {
+		assert.Equal(t, want, readMessage(t, conn)["type"])
+	}
+
+	// Presenters cannot control the poll
+	assert.NoError(t, conn.WriteJSON(protocol.AdminActionMessage{Type: protocol.TypeAdminAction, CorrelationID: "c1", Action: protocol.ActionDone}))
+	msg = readMessage(t, conn)
+	assert.Equal(t, string(protocol.ErrReadOnly), msg["code"])
+	assert.Equal(t, "c1", msg["correlationId"])
+	var saved data.Poll
+	data.DB.First(&saved, poll.ID)
+	assert.Equal(t, "active", saved.Status)
+}
+
+func TestHandleWebSocket_HidesVotesFromParticipants(t *testing.T) {
+	setupTestDB(t)
+	poll := createActivePoll(t)
+	q := poll.Questions[0]
+	router := gin.New()
+	router.GET("/ws/:inviteID", handleWebSocket)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	owner := createWebSocketAdmin(t, "owner@example.com", data.RoleAdmin)
+	poll.AdminUserID = int(owner.ID)
+	assert.NoError(t, data.DB.Save(poll).Error)
+	adminServer := httptest.NewServer(newWebSocketRouter(owner.Email))
+	defer adminServer.Close()
+
+	admin := dialPoll(t, adminServer, "invite", "?role=admin")
+	participant := dialPoll(t, server, "invite", "")
+	for _, want := range []string{protocol.TypeWelcome, protocol.TypePollState, protocol.TypeResultsUpdate} {
+		assert.Equal(t, want, readMessage(t, admin)["type"])
+	}
+	assert.Equal(t, protocol.TypeWelcome, readMessage(t, participant)["type"])
+	state := readMessage(t, participant)
+	assert.NotContains(t, state["currentQuestion"], "votes", "votes are hidden while voting by default")
+
+	// A vote updates the admin, but not the participant
+	handleSubmitVote("invite", poll, &protocol.SubmitVoteMessage{QuestionID: q.ID, OptionIDs: []uint{q.Options[0].ID}, VoterID: "v1"})
+	assert.Equal(t, protocol.TypeResultsUpdate, readMessage(t, admin)["type"])
+
+	// Showing the results reveals the votes
+	assert.Nil(t, handleAdminAction("invite", poll, &protocol.AdminActionMessage{Action: protocol.ActionShowResults}))
+	state = readMessage(t, participant)
+	assert.Equal(t, protocol.TypePollState, state["type"], "the participant should not have received the results_update")
+	assert.Contains(t, state["currentQuestion"], "votes")
+
+	// Live results are sent to participants as votes come in
+	poll.Status = "active"
+	poll.ResultsVisibility = data.ResultsLive
+	handleSubmitVote("invite", poll, &protocol.SubmitVoteMessage{QuestionID: q.ID, OptionIDs: []uint{q.Options[1].ID}, VoterID: "v2"})
+	update := readMessage(t, participant)
+	assert.Equal(t, protocol.TypeResultsUpdate, update["type"])
+	assert.Equal(t, float64(2), update["totalVotes"])
+}
+
+func TestHandleWebSocket_AdminRoleNeedsAccess(t *testing.T) {
+	setupTestDB(t)
+	poll := createActivePoll(t)
+	owner := createWebSocketAdmin(t, "owner@example.com", data.RoleAdmin)
+	createWebSocketAdmin(t, "other@example.com", data.RoleAdmin)
+	viewer := createWebSocketAdmin(t, "viewer@example.com", data.RoleViewer)
+	poll.AdminUserID = int(owner.ID)
+	assert.NoError(t, data.DB.Save(poll).Error)
+	assert.NoError(t, data.SharePoll(poll.ID, viewer.ID, data.AccessRun))
+	q := poll.Questions[0]
+	handleSubmitVote("invite", poll, &protocol.SubmitVoteMessage{QuestionID: q.ID, OptionIDs: []uint{q.Options[0].ID}, VoterID: "v1"})
+
+	// Nobody logged in and admins the poll is not shared with are participants
+	for _, email := range []string{"", "other@example.com"} {
+		server := httptest.NewServer(newWebSocketRouter(email))
+		conn := dialPoll(t, server, "invite", "?role=admin")
+		assert.Equal(t, protocol.TypeWelcome, readMessage(t, conn)["type"])
+		state := readMessage(t, conn)
+		assert.Equal(t, protocol.TypePollState, state["type"], email)
+		assert.NotContains(t, state["currentQuestion"], "votes", email)
+
+		assert.NoError(t, conn.WriteJSON(protocol.AdminActionMessage{Type: protocol.TypeAdminAction, CorrelationID: "c1", Action: protocol.ActionDone}))
+		msg := readMessage(t, conn)
+		assert.Equal(t, protocol.TypeError, msg["type"], email)
+		assert.Equal(t, string(protocol.ErrActionNotAllowed), msg["code"], email)
+		server.Close()
+	}
+
+	// A viewer the poll is shared with sees the results, but cannot control the poll
+	server := httptest.NewServer(newWebSocketRouter(viewer.Email))
+	defer server.Close()
+	conn := dialPoll(t, server, "invite", "?role=admin")
+	for _, want := range []string{protocol.TypeWelcome, protocol.TypePollState, protocol.TypeResultsUpdate} {
+		assert.Equal(t, want, readMessage(t, conn)["type"])
+	}
+	assert.NoError(t, conn.WriteJSON(protocol.AdminActionMessage{Type: protocol.TypeAdminAction, CorrelationID: "c2", Action: protocol.ActionDone}))
+	assert.Equal(t, string(protocol.ErrActionNotAllowed), readMessage(t, conn)["code"])
+
+	var saved data.Poll
+	data.DB.First(&saved, poll.ID)
+	assert.Equal(t, "active", saved.Status)
+}
+
+func TestParticipantPollState(t *testing.T) {
+	poll := &data.Poll{Status: "finished", ResultsVisibility: data.ResultsNever, CurrentQuestionIndex: 1, Questions: []data.Question{
+		{ResultsVisibility: data.ResultsAfterReveal}, {},
+	}}
+	votes := map[string]int{"1": 3}
+	full := protocol.PollStateMessage{Status: "finished", AllQuestions: []protocol.Question{{ID: 1, Votes: votes}, {ID: 2, Votes: votes}}}
+
+	msg := participantPollState(poll, full)
+	assert.Equal(t, votes, msg.AllQuestions[0].Votes)
+	assert.Nil(t, msg.AllQuestions[1].Votes)
+	assert.Equal(t, votes, full.AllQuestions[1].Votes, "the message for admins should not change")
+}
+
+func TestHandleWebSocket_OriginPolicy(t *testing.T) {
+	setupTestDB(t)
+	createActivePoll(t)
+	router := gin.New()
+	router.GET("/ws/:inviteID", handleWebSocket)
+	server := httptest.NewServer(router)
+	defer server.Close()
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws/invite"
+	dial := func(origin string) (*http.Response, error) {
+		conn, resp, err := websocket.DefaultDialer.Dial(url, http.Header{"Origin": {origin}})
+		if err == nil {
+			conn.Close()
+		}
+		return resp, err
+	}
+
+	// Only our own site by default
+	_, err := dial(server.URL)
+	assert.NoError(t, err)
+	resp, err := dial("https://lms.example.edu")
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	allowedOrigins = newOriginPolicy("https://lms.example.edu")
+	t.Cleanup(func() { allowedOrigins = originPolicy{} })
+	_, err = dial("https://lms.example.edu")
+	assert.NoError(t, err)
+	_, err = dial("https://evil.example.com")
+	assert.Error(t, err)
+}